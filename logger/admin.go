@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type adminState struct {
+	Debug       bool             `json:"debug"`
+	Level       string           `json:"level,omitempty"`
+	Components  map[string]Level `json:"components,omitempty"`
+	DebugRoutes []string         `json:"debugRoutes,omitempty"`
+	SampleEvery int              `json:"sampleEvery,omitempty"`
+	Dropped     uint64           `json:"dropped"`
+	Throttled   uint64           `json:"throttled"`
+	Queued      int              `json:"queued"`
+	QueueSize   int              `json:"queueSize"`
+	Pools       []PoolStats      `json:"pools,omitempty"`
+	Queues      []QueueStats     `json:"queues,omitempty"`
+}
+
+type adminChange struct {
+	Debug       *bool            `json:"debug"`
+	Level       *Level           `json:"level"`
+	Components  map[string]Level `json:"components"`
+	DebugRoutes []string         `json:"debugRoutes"`
+	SampleEvery *int             `json:"sampleEvery"`
+}
+
+/*
+AdminHandler serves the logger's settings and Stats as JSON
+so verbosity can be changed in production without a redeploy.
+GET returns the current state and POST applies a JSON body
+such as {"debug": true, "level": "debug", "sampleEvery": 5},
+where omitted fields are left alone, then returns the new
+state. A "components" object or "debugRoutes" array replaces
+the current ones and an empty one removes them. As with
+SetSampleEvery, "sampleEvery" only applies while an
+asynchronous logger's queue is full.
+
+Every request is passed to authorise first and is refused
+with 403 unless it returns true. A nil authorise refuses
+everything, so the handler is never exposed by accident.
+*/
+func (l *Logger) AdminHandler(authorise func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if authorise == nil || !authorise(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var c adminChange
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&c); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if c.SampleEvery != nil && *c.SampleEvery <= 0 {
+				http.Error(w, "sampleEvery must be positive", http.StatusBadRequest)
+				return
+			}
+			if c.Debug != nil {
+				l.SetDebug(*c.Debug)
+			}
+			if c.Level != nil {
+				l.SetLevel(*c.Level)
+			}
+			if c.Components != nil {
+				l.SetComponentLevels(c.Components)
+			}
+			if c.DebugRoutes != nil {
+				l.SetDebugRoutes(c.DebugRoutes...)
+			}
+			if c.SampleEvery != nil {
+				l.SetSampleEvery(*c.SampleEvery)
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.adminState())
+	})
+}
+
+func (l *Logger) adminState() adminState {
+
+	stats := l.Stats()
+	s := adminState{
+		Components:  l.ComponentLevels(),
+		DebugRoutes: l.DebugRoutes(),
+		SampleEvery: l.sampleEvery(),
+		Dropped:     stats.Dropped,
+		Throttled:   stats.Throttled,
+		Queued:      stats.Queued,
+		QueueSize:   stats.QueueSize,
+		Pools:       stats.Pools,
+		Queues:      stats.Queues,
+	}
+
+	settings := l.settings()
+	s.Debug = settings.debug
+	if settings.level != 0 {
+		s.Level = settings.level.String()
+	}
+
+	return s
+}
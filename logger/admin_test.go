@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+TestAdminHandlerWhileLogging changes settings through the
+admin handler and setters while other goroutines log. It is
+meant to be run with -race.
+*/
+func TestAdminHandlerWhileLogging(t *testing.T) {
+
+	l := &Logger{OnLog: func(Thread) error { return nil }}
+	h := l.AdminHandler(func(*http.Request) bool { return true })
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				id := l.NewId()
+				l.Debug(id, "debug").Data("k", 1)
+				l.Info(id, "info")
+				l.End(id, "", "GET", "/", 0)
+			}
+		}()
+	}
+
+	bodies := []string{
+		`{"debug": true, "level": "debug"}`,
+		`{"debug": false, "level": "error"}`,
+	}
+	fixed := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		on := i%2 == 0
+		want := LevelError
+		if on {
+			want = LevelDebug
+		}
+		got := serveAdmin(t, h, http.MethodPost, bodies[i%2])
+		if got.Debug != on || got.Level != want.String() {
+			t.Fatalf("POST %s returned debug %v and level %q", bodies[i%2], got.Debug, got.Level)
+		}
+		if s := l.settings(); s.debug != on || s.level != want {
+			t.Fatalf("POST %s left debug %v and level %v", bodies[i%2], s.debug, s.level)
+		}
+		l.SetRuntime(on)
+		l.SetNormalise(on)
+		l.SetPooling(on)
+		if on {
+			l.SetCaser(upper{})
+			l.SetCatalog(func(string) (string, bool) { return "", false })
+			l.SetClock(func() time.Time { return fixed })
+		} else {
+			l.SetCaser(nil)
+			l.SetCatalog(nil)
+			l.SetClock(nil)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	got := serveAdmin(t, h, http.MethodGet, "")
+	if got.Debug || got.Level != LevelError.String() {
+		t.Fatalf("GET returned debug %v and level %q, want false and %q", got.Debug, got.Level, LevelError)
+	}
+}
+
+/*
+serveAdmin sends a request with body to h, which must accept
+it, and decodes the state it returns.
+*/
+func serveAdmin(t *testing.T, h http.Handler, method, body string) adminState {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, "/", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s returned %d: %s", method, w.Code, w.Body)
+	}
+	var s adminState
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatalf("%s returned %q: %v", method, w.Body, err)
+	}
+	return s
+}
+
+func TestAdminHandlerState(t *testing.T) {
+
+	l := &Logger{}
+	h := l.AdminHandler(func(*http.Request) bool { return true })
+
+	got := serveAdmin(t, h, http.MethodPost, `{"components": {"db": "debug"}, "debugRoutes": ["/users/*"]}`)
+	if got.Components["db"] != LevelDebug || len(got.DebugRoutes) != 1 || got.DebugRoutes[0] != "/users/*" {
+		t.Fatalf("POST returned %+v", got)
+	}
+	if lv := l.ComponentLevels()["db"]; lv != LevelDebug {
+		t.Fatalf("component db has level %v, want %v", lv, LevelDebug)
+	}
+
+	// Omitted fields are left alone and empty ones remove
+	// what was set.
+	got = serveAdmin(t, h, http.MethodPost, `{"level": "info", "debugRoutes": []}`)
+	if got.Level != LevelInfo.String() || got.Components["db"] != LevelDebug || len(got.DebugRoutes) != 0 {
+		t.Fatalf("POST returned %+v", got)
+	}
+	if got := serveAdmin(t, h, http.MethodGet, ""); got.Level != LevelInfo.String() || len(l.DebugRoutes()) != 0 {
+		t.Fatalf("GET returned %+v", got)
+	}
+
+	for _, body := range []string{`{"level": "loud"}`, `{"sampleEvery": 0}`, `{"verbose": true}`} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("POST %s returned %d, want 400", body, w.Code)
+		}
+	}
+	if s := l.settings(); s.level != LevelInfo {
+		t.Fatalf("a refused POST changed the level to %v", s.level)
+	}
+}
+
+func TestAdminHandlerForbidden(t *testing.T) {
+
+	l := &Logger{}
+	l.SetLevel(LevelInfo)
+	for _, authorise := range []func(*http.Request) bool{nil, func(*http.Request) bool { return false }} {
+		h := l.AdminHandler(authorise)
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(method, "/", strings.NewReader(`{"debug": true, "level": "debug"}`)))
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("%s returned %d, want 403", method, w.Code)
+			}
+			if strings.Contains(w.Body.String(), "level") {
+				t.Fatalf("%s returned the state: %s", method, w.Body)
+			}
+		}
+	}
+	if s := l.settings(); s.debug || s.level != LevelInfo {
+		t.Fatalf("a refused POST left debug %v and level %v", s.debug, s.level)
+	}
+}
+
+type upper struct{}
+
+func (upper) String(s string) string {
+	return strings.ToUpper(s)
+}
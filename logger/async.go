@@ -0,0 +1,342 @@
+package logger
+
+import (
+	"sync"
+)
+
+/*
+DropPolicy decides what an asynchronous logger does with a
+thread that has ended while its queue is full.
+*/
+type DropPolicy int
+
+const (
+	// Block waits for room in the queue.
+	Block DropPolicy = iota
+
+	// DropNewest discards the thread that just ended.
+	DropNewest
+
+	// DropOldest discards the longest queued thread to make
+	// room for the one that just ended. If that thread has
+	// one of AsyncOptions.KeepTags it is passed to the hooks
+	// on the goroutine that ended the new one instead.
+	DropOldest
+
+	// Sample keeps one in every AsyncOptions.SampleEvery
+	// threads that end while the queue is full, blocking
+	// for room, and discards the rest. No threads are
+	// discarded while there is room.
+	Sample
+)
+
+type AsyncOptions struct {
+
+	// QueueSize is the number of ended threads that may be
+	// waiting to be passed to OnError and OnLog. If it is
+	// zero the logger is synchronous.
+	QueueSize int
+
+	Policy DropPolicy
+
+	// SampleEvery is used by the Sample policy, so like it
+	// only applies while the queue is full; it doesn't
+	// sample threads otherwise. It defaults to 10.
+	SampleEvery int
+
+	// Threads with any of KeepTags are never dropped. The
+	// logger waits for room in the queue for them instead.
+	KeepTags []string
+}
+
+/*
+Stats describes the logger's activity since it was created.
+*/
+type Stats struct {
+
+	// Dropped is the number of threads discarded because
+	// the asynchronous queue was full. See DropPolicy.
+	Dropped uint64
+
+	// Throttled is the number of threads suppressed by
+	// SetThrottle.
+	Throttled uint64
+
+	Queued    int
+	QueueSize int
+
+	// Pools describes the logger's open worker pools. See
+	// NewWorkerPool.
+	Pools []PoolStats
+
+	// Queues describes the queues registered with
+	// WatchQueue.
+	Queues []QueueStats
+}
+
+/*
+SetAsync makes ended threads get passed to OnError and OnLog
+on a separate goroutine so slow hooks don't hold up requests.
+Any threads queued under previous options are flushed first.
+*/
+func (l *Logger) SetAsync(opts AsyncOptions) {
+
+	l.asyncMu.Lock()
+	defer l.asyncMu.Unlock()
+
+	if l.async != nil {
+		l.async.stop()
+		l.async = nil
+	}
+	if opts.QueueSize <= 0 {
+		return
+	}
+	if opts.SampleEvery <= 0 {
+		opts.SampleEvery = 10
+	}
+
+	d := &dispatcher{
+		logger: l,
+		opts:   opts,
+		queue:  make(chan Thread, opts.QueueSize),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	d.idle = sync.NewCond(&d.mu)
+	go d.run()
+	l.async = d
+}
+
+/*
+Flush blocks until every queued thread has been passed to
+OnError and OnLog, for l and any clones of it. It returns
+immediately if they are synchronous.
+*/
+func (l *Logger) Flush() {
+	l.asyncMu.Lock()
+	d := l.async
+	l.asyncMu.Unlock()
+	if d != nil {
+		d.flush()
+	}
+	for _, c := range l.clones() {
+		c.Flush()
+	}
+}
+
+/*
+SetSampleEvery changes AsyncOptions.SampleEvery without
+flushing the queue as SetAsync would. Like SampleEvery it
+only affects threads that end while the queue is full under
+the Sample policy. It has no effect if the logger is
+synchronous and n defaults to 10 like SampleEvery.
+*/
+func (l *Logger) SetSampleEvery(n int) {
+	if n <= 0 {
+		n = 10
+	}
+	l.asyncMu.Lock()
+	d := l.async
+	l.asyncMu.Unlock()
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.opts.SampleEvery = n
+	d.mu.Unlock()
+}
+
+func (l *Logger) sampleEvery() int {
+	l.asyncMu.Lock()
+	d := l.async
+	l.asyncMu.Unlock()
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.opts.SampleEvery
+}
+
+func (l *Logger) Stats() Stats {
+
+	l.statsMu.Lock()
+	s := Stats{Dropped: l.dropped, Throttled: l.suppressed}
+	l.statsMu.Unlock()
+
+	l.asyncMu.Lock()
+	if l.async != nil {
+		s.Queued = len(l.async.queue)
+		s.QueueSize = l.async.opts.QueueSize
+	}
+	l.asyncMu.Unlock()
+
+	l.workersMu.Lock()
+	pools := l.workers
+	l.workersMu.Unlock()
+	for _, p := range pools {
+		s.Pools = append(s.Pools, p.Stats())
+	}
+	s.Queues = l.queueStats()
+
+	return s
+}
+
+/*
+drop discards t because the asynchronous queue is full.
+*/
+func (l *Logger) drop(t Thread) {
+	l.statsMu.Lock()
+	l.dropped++
+	l.statsMu.Unlock()
+	if t.pooled {
+		recycle(t.Entries)
+	}
+}
+
+func (l *Logger) dispatch(t Thread) {
+	l.asyncMu.Lock()
+	d := l.async
+	l.asyncMu.Unlock()
+	if d == nil {
+		l.emit(t)
+		return
+	}
+	d.send(t)
+}
+
+/*
+dispatcher passes threads to the hooks on its own goroutine.
+Its queue is never closed, since threads may still be sent
+to it by ends racing with stop; stop makes later sends emit
+synchronously instead, waits for those already sent, then
+closes quit to end the goroutine.
+*/
+type dispatcher struct {
+	logger  *Logger
+	opts    AsyncOptions
+	queue   chan Thread
+	quit    chan struct{}
+	done    chan struct{}
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending int
+	full    int
+	stopped bool
+}
+
+func (d *dispatcher) run() {
+	defer close(d.done)
+	for {
+		select {
+		case t := <-d.queue:
+			d.logger.emit(t)
+			d.finish()
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+func (d *dispatcher) send(t Thread) {
+
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		d.logger.emit(t)
+		return
+	}
+	d.pending++
+	d.mu.Unlock()
+
+	select {
+	case d.queue <- t:
+		return
+	default:
+	}
+
+	if d.kept(t) {
+		d.queue <- t
+		return
+	}
+
+	switch d.opts.Policy {
+
+	case Block:
+		d.queue <- t
+
+	case DropNewest:
+		d.logger.drop(t)
+		d.finish()
+
+	case DropOldest:
+		for {
+			select {
+			case old := <-d.queue:
+				// Kept threads are never dropped so one at
+				// the head of the queue is passed to the
+				// hooks here instead, making room as Block
+				// would.
+				if d.kept(old) {
+					d.logger.emit(old)
+				} else {
+					d.logger.drop(old)
+				}
+				d.finish()
+			default:
+			}
+			select {
+			case d.queue <- t:
+				return
+			default:
+			}
+		}
+
+	case Sample:
+		d.mu.Lock()
+		d.full++
+		keep := d.full%d.opts.SampleEvery == 1 || d.opts.SampleEvery == 1
+		d.mu.Unlock()
+		if keep {
+			d.queue <- t
+			return
+		}
+		d.logger.drop(t)
+		d.finish()
+	}
+}
+
+func (d *dispatcher) kept(t Thread) bool {
+	for _, tag := range d.opts.KeepTags {
+		if t.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dispatcher) finish() {
+	d.mu.Lock()
+	d.pending--
+	if d.pending == 0 {
+		d.idle.Broadcast()
+	}
+	d.mu.Unlock()
+}
+
+func (d *dispatcher) flush() {
+	d.mu.Lock()
+	for d.pending > 0 {
+		d.idle.Wait()
+	}
+	d.mu.Unlock()
+}
+
+func (d *dispatcher) stop() {
+	d.mu.Lock()
+	d.stopped = true
+	d.mu.Unlock()
+	d.flush()
+	close(d.quit)
+	<-d.done
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+TestDropOldestKeepTags fills the queue with kept threads
+under DropOldest and checks none of them is dropped to make
+room for the next.
+*/
+func TestDropOldestKeepTags(t *testing.T) {
+
+	entered := make(chan struct{})
+	gate := make(chan struct{})
+	var mu sync.Mutex
+	got := map[string]bool{}
+	l := &Logger{OnLog: func(t Thread) error {
+		if t.Id == "0" {
+			close(entered)
+			<-gate
+		}
+		mu.Lock()
+		got[t.Id] = true
+		mu.Unlock()
+		return nil
+	}}
+	l.SetAsync(AsyncOptions{QueueSize: 2, Policy: DropOldest, KeepTags: []string{"critical"}})
+	defer l.Close()
+
+	end := func(id string, tags ...string) {
+		l.Begin(id)
+		l.Tag(id, tags...)
+		l.End(id, "", "GET", "/", 0)
+	}
+
+	// The first thread holds up the hooks so the next two
+	// fill the queue.
+	end("0")
+	<-entered
+	end("1", "critical")
+	end("2", "critical")
+
+	done := make(chan struct{})
+	go func() {
+		end("3")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ending a thread with a full queue of kept threads didn't return")
+	}
+	close(gate)
+	l.Flush()
+
+	if dropped := l.Stats().Dropped; dropped != 0 {
+		t.Fatalf("dropped %d threads, want 0", dropped)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range []string{"0", "1", "2", "3"} {
+		if !got[id] {
+			t.Errorf("thread %s wasn't passed to OnLog", id)
+		}
+	}
+}
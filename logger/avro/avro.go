@@ -0,0 +1,200 @@
+/*
+Package avro encodes threads in the Avro binary encoding
+following Schema, optionally framed for a Confluent schema
+registry, so they can feed Avro based pipelines directly.
+Data and meta values are encoded as strings.
+*/
+package avro
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Schema is the Avro schema of an encoded thread. Times are
+Unix nanoseconds and durations are nanoseconds.
+*/
+const Schema = `{
+  "type": "record",
+  "name": "Thread",
+  "namespace": "com.github.jakebowkett.logger",
+  "fields": [
+    {"name": "date", "type": "long"},
+    {"name": "kind", "type": "string"},
+    {"name": "id", "type": "string"},
+    {"name": "ip", "type": "string"},
+    {"name": "method", "type": "string"},
+    {"name": "route", "type": "string"},
+    {"name": "status", "type": "int"},
+    {"name": "duration", "type": "long"},
+    {"name": "redirect", "type": "string"},
+    {"name": "correlationId", "type": "string"},
+    {"name": "outcome", "type": "string"},
+    {"name": "chunk", "type": "int"},
+    {"name": "unterminated", "type": "boolean"},
+    {"name": "timedOut", "type": "boolean"},
+    {"name": "data", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "KeyVal",
+      "fields": [
+        {"name": "key", "type": "string"},
+        {"name": "value", "type": "string"}
+      ]
+    }}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "meta", "type": {"type": "map", "values": "string"}},
+    {"name": "phases", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "Phase",
+      "fields": [
+        {"name": "name", "type": "string"},
+        {"name": "start", "type": "long"},
+        {"name": "duration", "type": "long"}
+      ]
+    }}},
+    {"name": "entries", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "Entry",
+      "fields": [
+        {"name": "level", "type": "string"},
+        {"name": "message", "type": "string"},
+        {"name": "key", "type": "string"},
+        {"name": "function", "type": "string"},
+        {"name": "file", "type": "string"},
+        {"name": "line", "type": "int"},
+        {"name": "data", "type": {"type": "array", "items": "KeyVal"}}
+      ]
+    }}}
+  ]
+}`
+
+/*
+Marshal encodes t following Schema.
+*/
+func Marshal(t logger.Thread) []byte {
+
+	var e encoder
+	var date int64
+	if !t.Date.IsZero() {
+		date = t.Date.UnixNano()
+	}
+	e.long(date)
+	e.string(t.Kind.String())
+	e.string(t.Id)
+	e.string(t.Ip)
+	e.string(t.Method)
+	e.string(t.Route)
+	e.long(int64(t.Status))
+	e.long(t.Duration)
+	e.string(t.Redirect)
+	e.string(t.CorrelationId)
+	e.string(string(t.Outcome))
+	e.long(int64(t.Chunk))
+	e.bool(t.Unterminated)
+	e.bool(t.TimedOut)
+
+	e.block(len(t.KeyVals))
+	for _, kv := range t.KeyVals {
+		e.string(kv.Key)
+		e.string(fmt.Sprint(kv.Val))
+	}
+	e.end()
+
+	e.block(len(t.Tags))
+	for _, tag := range t.Tags {
+		e.string(tag)
+	}
+	e.end()
+
+	// Sort meta keys so equal threads encode identically.
+	keys := make([]string, 0, len(t.Meta))
+	for k := range t.Meta {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	e.block(len(keys))
+	for _, k := range keys {
+		e.string(k)
+		e.string(fmt.Sprint(t.Meta[logger.MetaKey(k)]))
+	}
+	e.end()
+
+	e.block(len(t.Phases))
+	for _, p := range t.Phases {
+		var start int64
+		if !p.Start.IsZero() {
+			start = p.Start.UnixNano()
+		}
+		e.string(p.Name)
+		e.long(start)
+		e.long(int64(p.Duration))
+	}
+	e.end()
+
+	e.block(len(t.Entries))
+	for _, entry := range t.Entries {
+		e.string(entry.Level.String())
+		e.string(entry.Message)
+		e.string(entry.Key)
+		e.string(entry.Function)
+		e.string(entry.File)
+		e.long(int64(entry.Line))
+		e.block(len(entry.KeyVals))
+		for _, kv := range entry.KeyVals {
+			e.string(kv.Key)
+			e.string(fmt.Sprint(kv.Val))
+		}
+		e.end()
+	}
+	e.end()
+
+	return e.b
+}
+
+type encoder struct {
+	b []byte
+}
+
+/*
+long writes v as a zig-zag encoded varint, which is how Avro
+encodes both ints and longs.
+*/
+func (e *encoder) long(v int64) {
+	u := uint64(v<<1) ^ uint64(v>>63)
+	for u >= 0x80 {
+		e.b = append(e.b, byte(u)|0x80)
+		u >>= 7
+	}
+	e.b = append(e.b, byte(u))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.b = append(e.b, 1)
+	} else {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.long(int64(len(s)))
+	e.b = append(e.b, s...)
+}
+
+/*
+block starts an array or map of n items, which are written
+as a single block. Call end after writing them, even if
+there are none.
+*/
+func (e *encoder) block(n int) {
+	if n > 0 {
+		e.long(int64(n))
+	}
+}
+
+func (e *encoder) end() {
+	e.long(0)
+}
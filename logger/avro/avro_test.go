@@ -0,0 +1,257 @@
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+decoder reads the Avro binary encoding back, failing t if it
+runs out of bytes.
+*/
+type decoder struct {
+	t *testing.T
+	b []byte
+}
+
+func (d *decoder) long() int64 {
+	var u uint64
+	for shift := uint(0); ; shift += 7 {
+		if len(d.b) == 0 {
+			d.t.Fatal("ran out of bytes decoding a long")
+		}
+		c := d.b[0]
+		d.b = d.b[1:]
+		u |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			break
+		}
+	}
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+/*
+byte reads a boolean, which Avro writes as a single byte.
+*/
+func (d *decoder) byte() byte {
+	if len(d.b) == 0 {
+		d.t.Fatal("ran out of bytes decoding a boolean")
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) string() string {
+	n := int(d.long())
+	if n > len(d.b) {
+		d.t.Fatalf("string of %d bytes with %d left", n, len(d.b))
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+/*
+items calls fn for each item of an array or map, which may
+be written as any number of blocks.
+*/
+func (d *decoder) items(fn func()) {
+	for {
+		n := d.long()
+		if n == 0 {
+			return
+		}
+		if n < 0 {
+			n = -n
+			d.long()
+		}
+		for ; n > 0; n-- {
+			fn()
+		}
+	}
+}
+
+type decoded struct {
+	Date, Duration                 int64
+	Kind, Id, Ip, Method, Route    string
+	Redirect, Correlation, Outcome string
+	Status, Chunk                  int64
+	Unterminated, TimedOut         bool
+	Data                           [][2]string
+	Tags                           []string
+	Meta                           map[string]string
+	Phases                         []string
+	Entries                        []decodedEntry
+}
+
+type decodedEntry struct {
+	Level, Message, Key, Function, File string
+	Line                                int64
+	Data                                [][2]string
+}
+
+func decode(t *testing.T, b []byte) decoded {
+
+	d := &decoder{t: t, b: b}
+	var v decoded
+	v.Date = d.long()
+	v.Kind = d.string()
+	v.Id = d.string()
+	v.Ip = d.string()
+	v.Method = d.string()
+	v.Route = d.string()
+	v.Status = d.long()
+	v.Duration = d.long()
+	v.Redirect = d.string()
+	v.Correlation = d.string()
+	v.Outcome = d.string()
+	v.Chunk = d.long()
+	v.Unterminated = d.byte() != 0
+	v.TimedOut = d.byte() != 0
+	v.Data = d.keyVals()
+	d.items(func() { v.Tags = append(v.Tags, d.string()) })
+	v.Meta = map[string]string{}
+	d.items(func() { v.Meta[d.string()] = d.string() })
+	d.items(func() {
+		v.Phases = append(v.Phases, d.string())
+		d.long()
+		d.long()
+	})
+	d.items(func() {
+		var e decodedEntry
+		e.Level = d.string()
+		e.Message = d.string()
+		e.Key = d.string()
+		e.Function = d.string()
+		e.File = d.string()
+		e.Line = d.long()
+		e.Data = d.keyVals()
+		v.Entries = append(v.Entries, e)
+	})
+	if len(d.b) > 0 {
+		t.Fatalf("%d bytes left after decoding", len(d.b))
+	}
+	return v
+}
+
+func (d *decoder) keyVals() (kvs [][2]string) {
+	d.items(func() { kvs = append(kvs, [2]string{d.string(), d.string()}) })
+	return kvs
+}
+
+func thread(t *testing.T) logger.Thread {
+
+	l := &logger.Logger{}
+	l.SetClock(func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC) })
+	id := l.NewId()
+	l.Info(id, "handled").Data("user", 7).Data("name", "ann")
+	l.Error(id, "failed")
+	l.ThreadData(id, "tenant", "acme")
+	l.Tag(id, "audit", "slow")
+	l.SetMeta(id, logger.MetaCorrelation, "corr")
+	l.SetMeta(id, "region", "eu")
+	l.Phase(id, "db")
+	th, ok := l.End(id, "10.0.0.1", "GET", "/users/:id", 1500)
+	if !ok {
+		t.Fatal("thread wasn't open")
+	}
+	return th
+}
+
+func TestMarshal(t *testing.T) {
+
+	th := thread(t)
+	got := decode(t, Marshal(th))
+
+	if got.Date != th.Date.UnixNano() || got.Kind != th.Kind.String() || got.Status != 200 || got.Id != th.Id ||
+		got.Ip != "10.0.0.1" || got.Method != "GET" || got.Route != "/users/:id" ||
+		got.Duration != th.Duration || got.Correlation != "corr" {
+		t.Fatalf("decoded %+v from %+v", got, th)
+	}
+	if want := [][2]string{{"tenant", "acme"}}; !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("data is %v, want %v", got.Data, want)
+	}
+	if want := []string{"audit", "slow"}; !reflect.DeepEqual(got.Tags, want) {
+		t.Fatalf("tags are %v, want %v", got.Tags, want)
+	}
+	if got.Meta["region"] != "eu" {
+		t.Fatalf("meta is %v", got.Meta)
+	}
+	if want := []string{"db"}; !reflect.DeepEqual(got.Phases, want) {
+		t.Fatalf("phases are %v, want %v", got.Phases, want)
+	}
+	if len(got.Entries) != 2 {
+		t.Fatalf("decoded %d entries, want 2", len(got.Entries))
+	}
+	e := got.Entries[0]
+	if e.Level != "Info" || e.Message != th.Entries[0].Message || e.Line != int64(th.Entries[0].Line) ||
+		!reflect.DeepEqual(e.Data, [][2]string{{"user", "7"}, {"name", "ann"}}) {
+		t.Fatalf("first entry is %+v", e)
+	}
+	if e := got.Entries[1]; e.Level != "Error" || len(e.Data) != 0 {
+		t.Fatalf("second entry is %+v", e)
+	}
+}
+
+func TestMarshalEmpty(t *testing.T) {
+	got := decode(t, Marshal(logger.Thread{Kind: logger.KindSession}))
+	if got.Date != 0 || got.Kind != logger.KindSession.String() || len(got.Entries) != 0 || len(got.Meta) != 0 {
+		t.Fatalf("decoded %+v", got)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+
+	var posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/subjects/logs%2Fthreads-value/versions" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var body struct{ Schema string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Schema != Schema {
+			http.Error(w, "bad schema", http.StatusUnprocessableEntity)
+			return
+		}
+		w.Write([]byte(`{"id":258}`))
+	}))
+	defer srv.Close()
+
+	r := &Registry{URL: srv.URL + "/", Subject: "logs/threads-value"}
+	th := thread(t)
+	for i := 0; i < 2; i++ {
+		b, err := r.Marshal(th)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b[0] != 0 || binary.BigEndian.Uint32(b[1:5]) != 258 {
+			t.Fatalf("framed with % x", b[:5])
+		}
+		if got := decode(t, b[5:]); got.Id != th.Id {
+			t.Fatalf("decoded id %q, want %q", got.Id, th.Id)
+		}
+	}
+	if posts != 1 {
+		t.Fatalf("schema was registered %d times, want once", posts)
+	}
+}
+
+func TestRegistryError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "incompatible schema", http.StatusConflict)
+	}))
+	defer srv.Close()
+	r := &Registry{URL: srv.URL, Subject: "threads-value"}
+	if _, err := r.Marshal(logger.Thread{}); err == nil {
+		t.Fatal("Marshal succeeded with the registry refusing the schema")
+	}
+}
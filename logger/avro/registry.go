@@ -0,0 +1,92 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Registry registers Schema with a Confluent compatible schema
+registry and frames encoded threads with the id it assigns.
+*/
+type Registry struct {
+
+	// URL is the registry's base URL.
+	URL string
+
+	// Subject is the subject Schema is registered under,
+	// typically the topic name followed by -value.
+	Subject string
+
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+
+	mu sync.Mutex
+	id uint32
+}
+
+/*
+Register registers Schema under Subject, if it isn't
+already, and returns its id. The id is cached after the
+first success.
+*/
+func (r *Registry) Register() (uint32, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.id != 0 {
+		return r.id, nil
+	}
+
+	body, err := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{Schema})
+	if err != nil {
+		return 0, err
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := strings.TrimSuffix(r.URL, "/") + "/subjects/" + url.PathEscape(r.Subject) + "/versions"
+	resp, err := client.Post(u, "application/vnd.schemaregistry.v1+json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("avro: schema registry responded %s", resp.Status)
+	}
+
+	var res struct {
+		Id uint32 `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, fmt.Errorf("avro: decoding schema registry response: %v", err)
+	}
+	r.id = res.Id
+	return r.id, nil
+}
+
+/*
+Marshal encodes t in the Confluent wire format: a zero byte,
+the schema id as four big-endian bytes, then the Avro data.
+*/
+func (r *Registry) Marshal(t logger.Thread) ([]byte, error) {
+	id, err := r.Register()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 5, 64)
+	binary.BigEndian.PutUint32(b[1:], id)
+	return append(b, Marshal(t)...), nil
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+SetBaggageKeys sets which W3C baggage entries Middleware
+copies from incoming requests onto the request thread as
+thread data, e.g. "tenant". Baggage is supplied by callers
+so only the keys listed here are attached. Passing no keys
+stops baggage being attached.
+*/
+func (l *Logger) SetBaggageKeys(keys ...string) {
+	keys = append([]string(nil), keys...)
+	l.update("baggageKeys", func(s *settings) { s.baggageKeys = keys })
+}
+
+/*
+attachBaggage adds the whitelisted entries of the baggage
+headers in h to the thread reqId in the order they appear.
+*/
+func (l *Logger) attachBaggage(reqId string, h http.Header) {
+
+	keys := l.settings().baggageKeys
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, header := range h["Baggage"] {
+		for _, member := range strings.Split(header, ",") {
+
+			// Properties after a semicolon describe the entry
+			// rather than being part of its value.
+			if i := strings.IndexByte(member, ';'); i != -1 {
+				member = member[:i]
+			}
+			i := strings.IndexByte(member, '=')
+			if i == -1 {
+				continue
+			}
+			key := strings.TrimSpace(member[:i])
+			if !hasTag(keys, key) {
+				continue
+			}
+			val, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+			if err != nil {
+				continue
+			}
+			l.ThreadData(reqId, key, val)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+/*
+Package bigquery streams ended threads into a BigQuery table
+using the insertAll API, one row per thread with its entries
+as a repeated record, so request logs can be queried as soon
+as they end.
+*/
+package bigquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/httpclient"
+)
+
+const apiURL = "https://bigquery.googleapis.com/bigquery/v2"
+
+/*
+MaxRows is the number of rows BigQuery recommends sending in
+a single insertAll request.
+*/
+const MaxRows = 500
+
+/*
+Streamer accumulates rows and inserts them whenever MaxRows
+is reached, Flush is called or, once started, periodically.
+*/
+type Streamer struct {
+	Project string
+	Dataset string
+	Table   string
+
+	// Token returns an OAuth 2.0 access token with the
+	// bigquery.insertdata scope, or bigquery for
+	// EnsureTable, e.g. from golang.org/x/oauth2.
+	Token func() (string, error)
+
+	// MaxRows defaults to MaxRows.
+	MaxRows int
+
+	// Client defaults to http.DefaultClient, or a client
+	// using TLS if it is set.
+	Client *http.Client
+
+	// TLS configures how the server is verified, and any
+	// client certificate, when Client isn't set.
+	TLS *logger.TLSOptions
+
+	// Compression is the content encoding of requests, such
+	// as gzip or, once registered, zstd, or empty to send
+	// them as they are. See logger.RegisterCompression.
+	Compression string
+
+	// OnError is called when a periodic flush fails.
+	OnError func(error)
+
+	clients httpclient.Cache
+
+	mu   sync.Mutex
+	rows []insertRow
+	stop chan struct{}
+}
+
+type insertRow struct {
+	InsertId string `json:"insertId"`
+	Json     row    `json:"json"`
+}
+
+type row struct {
+	Date          string   `json:"date"`
+	Kind          string   `json:"kind"`
+	Id            string   `json:"id"`
+	Ip            string   `json:"ip,omitempty"`
+	Method        string   `json:"method,omitempty"`
+	Route         string   `json:"route,omitempty"`
+	Status        int      `json:"status,omitempty"`
+	Duration      int64    `json:"duration,omitempty"`
+	CorrelationId string   `json:"correlation_id,omitempty"`
+	Outcome       string   `json:"outcome,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Data          []keyVal `json:"data,omitempty"`
+	Entries       []entry  `json:"entries,omitempty"`
+}
+
+type entry struct {
+	Level    string   `json:"level"`
+	Message  string   `json:"message"`
+	Key      string   `json:"key,omitempty"`
+	Function string   `json:"function,omitempty"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Data     []keyVal `json:"data,omitempty"`
+}
+
+type keyVal struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+/*
+Hook adds t to the next insert. It has the signature of
+Logger.OnLog so it can be assigned to it.
+*/
+func (s *Streamer) Hook(t logger.Thread) error {
+
+	r := row{
+		Date:          t.Date.UTC().Format(time.RFC3339Nano),
+		Kind:          t.Kind.String(),
+		Id:            t.Id,
+		Ip:            t.Ip,
+		Method:        t.Method,
+		Route:         t.Route,
+		Status:        t.Status,
+		Duration:      t.Duration,
+		CorrelationId: t.CorrelationId,
+		Outcome:       string(t.Outcome),
+		Tags:          t.Tags,
+	}
+	for _, kv := range t.KeyVals {
+		r.Data = append(r.Data, keyVal{kv.Key, fmt.Sprint(kv.Val)})
+	}
+	for _, e := range t.Entries {
+		en := entry{
+			Level:    e.Level.String(),
+			Message:  e.Message,
+			Key:      e.Key,
+			Function: e.Function,
+			File:     e.File,
+			Line:     e.Line,
+		}
+		for _, kv := range e.KeyVals {
+			en.Data = append(en.Data, keyVal{kv.Key, fmt.Sprint(kv.Val)})
+		}
+		r.Entries = append(r.Entries, en)
+	}
+
+	// The insert id lets BigQuery drop duplicates if a
+	// flush is retried after a partial failure.
+	id := t.Id + "-" + strconv.FormatInt(t.Date.UnixNano(), 10)
+
+	s.mu.Lock()
+	s.rows = append(s.rows, insertRow{id, r})
+	full := len(s.rows) >= s.maxRows()
+	s.mu.Unlock()
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *Streamer) maxRows() int {
+	if s.MaxRows <= 0 {
+		return MaxRows
+	}
+	return s.MaxRows
+}
+
+/*
+Flush inserts the accumulated rows, in requests of at most
+MaxRows, unless there are none. Rows that weren't inserted
+are kept for the next attempt.
+*/
+func (s *Streamer) Flush() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.rows) > 0 {
+		n := s.maxRows()
+		if n > len(s.rows) {
+			n = len(s.rows)
+		}
+		if err := s.insert(s.rows[:n]); err != nil {
+			return err
+		}
+		s.rows = s.rows[n:]
+	}
+	s.rows = nil
+	return nil
+}
+
+func (s *Streamer) insert(rows []insertRow) error {
+
+	var res struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	err := s.call(http.MethodPost, s.tableURL()+"/insertAll", struct {
+		Rows []insertRow `json:"rows"`
+	}{rows}, &res)
+	if err != nil {
+		return err
+	}
+	if len(res.InsertErrors) > 0 {
+		ie := res.InsertErrors[0]
+		msg := "unknown error"
+		if len(ie.Errors) > 0 {
+			msg = ie.Errors[0].Reason + ": " + ie.Errors[0].Message
+		}
+		return fmt.Errorf("bigquery: %d rows failed, row %d: %s",
+			len(res.InsertErrors), ie.Index, msg)
+	}
+	return nil
+}
+
+func (s *Streamer) tableURL() string {
+	return apiURL + "/projects/" + s.Project + "/datasets/" + s.Dataset + "/tables/" + s.Table
+}
+
+/*
+call sends body as JSON and decodes the response into res.
+*/
+func (s *Streamer) call(method, url string, body, res interface{}) error {
+
+	var r *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		b, err = logger.Compress(s.Compression, b)
+		if err != nil {
+			return fmt.Errorf("bigquery: %v", strings.TrimPrefix(err.Error(), "logger: "))
+		}
+		r = bytes.NewReader(b)
+	} else {
+		r = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body != nil && s.Compression != "" {
+		req.Header.Set("Content-Encoding", s.Compression)
+	}
+	if s.Token != nil {
+		token, err := s.Token()
+		if err != nil {
+			return fmt.Errorf("bigquery: getting token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client, err := s.clients.Get(s.Client, s.TLS)
+	if err != nil {
+		return fmt.Errorf("bigquery: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &statusError{resp.StatusCode, resp.Status}
+	}
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "bigquery: API responded " + e.status
+}
+
+/*
+Start flushes every interval until Close is called.
+*/
+func (s *Streamer) Start(every time.Duration) {
+
+	stop := make(chan struct{})
+	s.mu.Lock()
+	old := s.stop
+	s.stop = stop
+	s.mu.Unlock()
+	if old != nil {
+		close(old)
+	}
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil && s.OnError != nil {
+					s.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+/*
+Close stops periodic flushing and flushes what remains.
+*/
+func (s *Streamer) Close() error {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return s.Flush()
+}
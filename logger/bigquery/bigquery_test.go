@@ -0,0 +1,233 @@
+package bigquery
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+redirect sends requests meant for the BigQuery API to a test
+server instead.
+*/
+type redirect struct {
+	to *url.URL
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = r.to.Scheme, r.to.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+/*
+api is the part of the BigQuery API Streamer uses. It
+reports insert errors for the first fail inserts and
+records the rows of the rest, and the table once created.
+*/
+type api struct {
+	mu     sync.Mutex
+	fail   int
+	rows   []insertRow
+	exists bool
+	tables []string
+}
+
+const tablePath = "/bigquery/v2/projects/proj/datasets/logs/tables"
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == tablePath+"/threads/insertAll":
+		var req struct{ Rows []insertRow }
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if a.fail > 0 {
+			a.fail--
+			w.Write([]byte(`{"insertErrors":[{"index":0,"errors":[{"reason":"invalid","message":"no such field"}]}]}`))
+			return
+		}
+		a.rows = append(a.rows, req.Rows...)
+		w.Write([]byte(`{}`))
+	case r.Method == http.MethodGet && r.URL.Path == tablePath+"/threads":
+		if !a.exists {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	case r.Method == http.MethodPost && r.URL.Path == tablePath:
+		b, _ := ioutil.ReadAll(body)
+		a.tables = append(a.tables, string(b))
+		if a.exists {
+			http.Error(w, "already exists", http.StatusConflict)
+		}
+		a.exists = true
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func newStreamer(t *testing.T, a *api) (*Streamer, func()) {
+	srv := httptest.NewServer(a)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Streamer{
+		Project: "proj",
+		Dataset: "logs",
+		Table:   "threads",
+		Token:   func() (string, error) { return "token", nil },
+		Client:  &http.Client{Transport: redirect{u}},
+	}, srv.Close
+}
+
+func TestStreamerInsert(t *testing.T) {
+
+	a := &api{}
+	s, done := newStreamer(t, a)
+	defer done()
+	s.MaxRows = 2
+	s.Compression = "gzip"
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	l := &logger.Logger{}
+	l.SetClock(func() time.Time { return date })
+	id := l.NewId()
+	l.Info(id, "handled").Data("user", 7)
+	l.ThreadData(id, "tenant", "acme")
+	l.Tag(id, "audit")
+	th, _ := l.End(id, "10.0.0.1", "GET", "/users/:id", 1500)
+
+	for _, th := range []logger.Thread{th, {Kind: logger.KindSession, Id: "b", Date: date}} {
+		if err := s.Hook(th); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Hook(logger.Thread{Kind: logger.KindSession, Id: "c", Date: date}); err != nil {
+		t.Fatal(err)
+	}
+	a.mu.Lock()
+	n := len(a.rows)
+	a.mu.Unlock()
+	if n != 2 {
+		t.Fatalf("inserted %d rows on reaching MaxRows, want 2", n)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.rows) != 3 || a.rows[1].Json.Id != "b" || a.rows[2].Json.Id != "c" {
+		t.Fatalf("inserted %+v", a.rows)
+	}
+	got := a.rows[0]
+	if want := id + "-1577934245000000006"; got.InsertId != want {
+		t.Fatalf("insert id is %q, want %q", got.InsertId, want)
+	}
+	want := row{
+		Date:     "2020-01-02T03:04:05.000000006Z",
+		Kind:     "request",
+		Id:       id,
+		Ip:       "10.0.0.1",
+		Method:   "GET",
+		Route:    "/users/:id",
+		Status:   200,
+		Duration: 1500,
+		Tags:     []string{"audit"},
+		Data:     []keyVal{{"tenant", "acme"}},
+		Entries: []entry{{
+			Level:    "Info",
+			Message:  "Handled.",
+			Key:      "handled",
+			Function: th.Entries[0].Function,
+			File:     th.Entries[0].File,
+			Line:     th.Entries[0].Line,
+			Data:     []keyVal{{"user", "7"}},
+		}},
+	}
+	if !reflect.DeepEqual(got.Json, want) {
+		t.Fatalf("inserted\n%+v\nwant\n%+v", got.Json, want)
+	}
+}
+
+func TestStreamerInsertErrors(t *testing.T) {
+
+	a := &api{fail: 1}
+	s, done := newStreamer(t, a)
+	defer done()
+
+	if err := s.Hook(logger.Thread{Kind: logger.KindSession, Id: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Flush()
+	if err == nil || !strings.Contains(err.Error(), "invalid: no such field") {
+		t.Fatalf("Flush returned %v, want the insert error", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.rows) != 1 || a.rows[0].Json.Id != "a" {
+		t.Fatalf("inserted %+v, want the row kept from the failed insert", a.rows)
+	}
+}
+
+func TestEnsureTable(t *testing.T) {
+
+	a := &api{}
+	s, done := newStreamer(t, a)
+	defer done()
+
+	if err := s.EnsureTable(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.tables) != 1 {
+		t.Fatalf("created %d tables, want 1", len(a.tables))
+	}
+	var table struct {
+		TableReference   struct{ ProjectId, DatasetId, TableId string }
+		Schema           struct{ Fields []Field }
+		TimePartitioning struct{ Type, Field string }
+	}
+	if err := json.Unmarshal([]byte(a.tables[0]), &table); err != nil {
+		t.Fatal(err)
+	}
+	if table.TableReference.TableId != "threads" || !reflect.DeepEqual(table.Schema.Fields, Schema) ||
+		table.TimePartitioning.Field != "date" {
+		t.Fatalf("created table %+v", table)
+	}
+
+	// An existing table is left alone.
+	if err := s.EnsureTable(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.tables) != 1 {
+		t.Fatal("created a table that already existed")
+	}
+}
@@ -0,0 +1,92 @@
+package bigquery
+
+import (
+	"net/http"
+)
+
+/*
+Field is a column in a BigQuery table schema.
+*/
+type Field struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Mode   string  `json:"mode,omitempty"`
+	Fields []Field `json:"fields,omitempty"`
+}
+
+var keyValFields = []Field{
+	{Name: "key", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "value", Type: "STRING"},
+}
+
+/*
+Schema is the schema of the rows Streamer inserts.
+*/
+var Schema = []Field{
+	{Name: "date", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "kind", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "ip", Type: "STRING"},
+	{Name: "method", Type: "STRING"},
+	{Name: "route", Type: "STRING"},
+	{Name: "status", Type: "INTEGER"},
+	{Name: "duration", Type: "INTEGER"},
+	{Name: "correlation_id", Type: "STRING"},
+	{Name: "outcome", Type: "STRING"},
+	{Name: "tags", Type: "STRING", Mode: "REPEATED"},
+	{Name: "data", Type: "RECORD", Mode: "REPEATED", Fields: keyValFields},
+	{Name: "entries", Type: "RECORD", Mode: "REPEATED", Fields: []Field{
+		{Name: "level", Type: "STRING", Mode: "REQUIRED"},
+		{Name: "message", Type: "STRING"},
+		{Name: "key", Type: "STRING"},
+		{Name: "function", Type: "STRING"},
+		{Name: "file", Type: "STRING"},
+		{Name: "line", Type: "INTEGER"},
+		{Name: "data", Type: "RECORD", Mode: "REPEATED", Fields: keyValFields},
+	}},
+}
+
+/*
+EnsureTable creates the table with Schema, partitioned by
+day on date, if it doesn't exist. An existing table is left
+as it is.
+*/
+func (s *Streamer) EnsureTable() error {
+
+	err := s.call(http.MethodGet, s.tableURL(), nil, nil)
+	if err == nil {
+		return nil
+	}
+	if se, ok := err.(*statusError); !ok || se.code != http.StatusNotFound {
+		return err
+	}
+
+	type tableReference struct {
+		ProjectId string `json:"projectId"`
+		DatasetId string `json:"datasetId"`
+		TableId   string `json:"tableId"`
+	}
+	type timePartitioning struct {
+		Type  string `json:"type"`
+		Field string `json:"field"`
+	}
+	type schema struct {
+		Fields []Field `json:"fields"`
+	}
+	table := struct {
+		TableReference   tableReference   `json:"tableReference"`
+		Schema           schema           `json:"schema"`
+		TimePartitioning timePartitioning `json:"timePartitioning"`
+	}{
+		tableReference{s.Project, s.Dataset, s.Table},
+		schema{Schema},
+		timePartitioning{"DAY", "date"},
+	}
+
+	url := apiURL + "/projects/" + s.Project + "/datasets/" + s.Dataset + "/tables"
+	err = s.call(http.MethodPost, url, table, nil)
+	if se, ok := err.(*statusError); ok && se.code == http.StatusConflict {
+		return nil
+	}
+	return err
+}
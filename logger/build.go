@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"runtime/debug"
+)
+
+/*
+BuildInfo identifies the build of the running binary so logs
+can be traced back to the code that produced them.
+*/
+type BuildInfo struct {
+	Module   string
+	Version  string
+	Revision string
+
+	// Time is the commit time of Revision in RFC 3339
+	// format.
+	Time      string
+	Modified  bool
+	GoVersion string
+}
+
+/*
+ReadBuildInfo returns the main module's path and version
+and, for binaries built with Go 1.18 or later from a VCS
+checkout, the revision, its time and whether the working
+tree was modified. Fields that aren't available are empty.
+*/
+func ReadBuildInfo() BuildInfo {
+	var b BuildInfo
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	b.Module = info.Main.Path
+	b.Version = info.Main.Version
+	readBuildSettings(info, &b)
+	return b
+}
+
+func (b BuildInfo) fields() []kv {
+	var fields []kv
+	add := func(k, v string) {
+		if v != "" {
+			fields = append(fields, kv{k, v})
+		}
+	}
+	add("module", b.Module)
+	add("version", b.Version)
+	add("revision", b.Revision)
+	add("build_time", b.Time)
+	if b.Modified {
+		fields = append(fields, kv{"modified", true})
+	}
+	add("go", b.GoVersion)
+	return fields
+}
+
+/*
+SetBuildInfo stamps the version and revision of b onto every
+thread as thread data. Passing a zero BuildInfo stops the
+stamping.
+*/
+func (l *Logger) SetBuildInfo(b BuildInfo) {
+	var fields []kv
+	if b.Version != "" {
+		fields = append(fields, kv{"version", b.Version})
+	}
+	if b.Revision != "" {
+		fields = append(fields, kv{"revision", b.Revision})
+	}
+	l.update("build", func(s *settings) { s.build = fields })
+}
+
+/*
+LogBuildInfo logs a session describing the build of the
+running binary in full, e.g. when the process starts.
+*/
+func (l *Logger) LogBuildInfo() {
+	id := l.NewId()
+	e := l.logEntry(LevelInfo, id, "build info")
+	for _, f := range ReadBuildInfo().fields() {
+		e.Data(f.Key, f.Val)
+	}
+	l.end(KindSession, id, "", "", "", 0)
+}
@@ -0,0 +1,14 @@
+//go:build !go1.18
+// +build !go1.18
+
+package logger
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build settings aren't recorded before Go 1.18.
+func readBuildSettings(info *debug.BuildInfo, b *BuildInfo) {
+	b.GoVersion = runtime.Version()
+}
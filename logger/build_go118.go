@@ -0,0 +1,22 @@
+//go:build go1.18
+// +build go1.18
+
+package logger
+
+import (
+	"runtime/debug"
+)
+
+func readBuildSettings(info *debug.BuildInfo, b *BuildInfo) {
+	b.GoVersion = info.GoVersion
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Package chilogger records chi requests as request threads of
+a logger.Logger, grouped by the route pattern they matched.
+It is a module of its own so the logger doesn't depend on
+chi.
+*/
+package chilogger
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Middleware is logger.Middleware for a chi router. Add it with
+Use and each thread's route is the pattern the request
+matched, e.g. /users/{id}, rather than its path.
+*/
+func Middleware(l *logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			// Routing happens after Use's middleware has
+			// run, so the pattern is only known now.
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if p := rctx.RoutePattern(); p != "" {
+					l.SetRoutePattern(logger.RequestId(r.Context()), p)
+				}
+			}
+		}))
+	}
+}
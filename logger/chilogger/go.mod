@@ -0,0 +1,10 @@
+module github.com/jakebowkett/go-logger/logger/chilogger
+
+go 1.20
+
+require (
+	github.com/go-chi/chi/v5 v5.0.12
+	github.com/jakebowkett/go-logger/logger v0.0.0
+)
+
+replace github.com/jakebowkett/go-logger/logger => ../
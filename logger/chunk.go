@@ -0,0 +1,45 @@
+package logger
+
+/*
+SetChunking bounds the memory held by very long threads.
+Whenever a thread has max entries they are emitted as a chunk
+and dropped from the thread. Chunks share the thread's id,
+are numbered from 1 in Thread.Chunk and are marked
+Unterminated; the thread itself is emitted as the last chunk
+when it ends. Entries can't be modified once their chunk has
+been emitted. Zero disables chunking.
+*/
+func (l *Logger) SetChunking(max int) {
+	l.update("chunkSize", func(s *settings) { s.chunkSize = max })
+}
+
+/*
+chunk emits the entries of e's thread as a chunk if there
+are enough of them.
+*/
+func (l *Logger) chunk(e *Entry) {
+
+	max := l.settings().chunkSize
+	b := e.buf
+	if max <= 0 || b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	if b.closed || len(b.entries) < max {
+		b.mu.Unlock()
+		return
+	}
+	t := l.snapshotThread(e.ThreadId, b, l.now())
+	for _, e := range b.entries {
+		e.frozen = true
+	}
+	b.base += len(b.entries)
+	b.entries = nil
+	b.reported = 0
+	b.meta.chunks++
+	t.Chunk = b.meta.chunks
+	b.mu.Unlock()
+
+	l.dispatch(t)
+}
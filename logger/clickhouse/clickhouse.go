@@ -0,0 +1,345 @@
+/*
+Package clickhouse inserts ended threads into ClickHouse over
+its HTTP interface in batches, one row per entry with the
+thread's fields repeated on each, using a table such as
+
+	CREATE TABLE logs (
+		date           DateTime64(9, 'UTC'),
+		kind           LowCardinality(String),
+		thread_id      String,
+		ip             String,
+		method         LowCardinality(String),
+		route          String,
+		status         UInt16,
+		duration       Int64,
+		correlation_id String,
+		outcome        LowCardinality(String),
+		thread_data    Map(String, String),
+		tags           Array(String),
+		entry          Int32,
+		level          LowCardinality(String),
+		message        String,
+		key            String,
+		function       String,
+		file           String,
+		line           UInt32,
+		data           Map(String, String)
+	)
+	ENGINE = MergeTree
+	PARTITION BY toDate(date)
+	ORDER BY (date, thread_id, entry)
+
+Threads without entries get a single row whose entry is -1.
+*/
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/httpclient"
+)
+
+/*
+Inserter accumulates rows and inserts them whenever MaxRows
+is reached, Flush is called or, once started, periodically.
+*/
+type Inserter struct {
+
+	// URL is the HTTP interface, e.g. http://localhost:8123.
+	URL string
+
+	// Table may be qualified with its database.
+	Table string
+
+	User     string
+	Password string
+
+	// MaxRows inserts once this many rows have accumulated.
+	// Zero leaves inserting to Flush and Start.
+	MaxRows int
+
+	// MaxBuffer caps how many rows are kept while inserts
+	// fail. Hook returns ErrFull rather than take a thread
+	// past it so the logger retries or dead-letters the
+	// thread instead. It defaults to DefaultMaxBuffer.
+	MaxBuffer int
+
+	// Client defaults to http.DefaultClient, or a client
+	// using TLS if it is set.
+	Client *http.Client
+
+	// TLS configures how the server is verified, and any
+	// client certificate, when Client isn't set.
+	TLS *logger.TLSOptions
+
+	// Token is sent as a bearer token if it is set, e.g. to
+	// a proxy in front of ClickHouse, or to ClickHouse
+	// itself when it authenticates with JWTs.
+	Token string
+
+	// Compression is the content encoding of requests, such
+	// as gzip or, once registered, zstd, or empty to send
+	// them as they are. See logger.RegisterCompression.
+	Compression string
+
+	// OnError is called when an insert started by Hook or
+	// Start fails.
+	OnError func(error)
+
+	clients httpclient.Cache
+
+	// mu guards buf and rows, the rows not yet inserted,
+	// and inflight, the rows of the insert in progress. It
+	// isn't held while inserting so Hook doesn't wait for it.
+	mu       sync.Mutex
+	buf      []byte
+	rows     int
+	inflight int
+	stop     chan struct{}
+
+	// flushMu is held while inserting so a failed insert is
+	// put back before the next one starts.
+	flushMu sync.Mutex
+}
+
+/*
+DefaultMaxBuffer is used when Inserter.MaxBuffer is zero.
+*/
+const DefaultMaxBuffer = 100000
+
+/*
+ErrFull is returned by Hook when inserts have been failing
+for long enough that Inserter.MaxBuffer has been reached.
+*/
+var ErrFull = errors.New("clickhouse: buffer is full")
+
+type row struct {
+	Date          string            `json:"date"`
+	Kind          string            `json:"kind"`
+	ThreadId      string            `json:"thread_id"`
+	Ip            string            `json:"ip"`
+	Method        string            `json:"method"`
+	Route         string            `json:"route"`
+	Status        int               `json:"status"`
+	Duration      int64             `json:"duration"`
+	CorrelationId string            `json:"correlation_id"`
+	Outcome       string            `json:"outcome"`
+	ThreadData    map[string]string `json:"thread_data"`
+	Tags          []string          `json:"tags"`
+	Entry         int               `json:"entry"`
+	Level         string            `json:"level"`
+	Message       string            `json:"message"`
+	Key           string            `json:"key"`
+	Function      string            `json:"function"`
+	File          string            `json:"file"`
+	Line          int               `json:"line"`
+	Data          map[string]string `json:"data"`
+}
+
+/*
+Hook adds t to the next insert. It has the signature of
+Logger.OnLog so it can be assigned to it. Once t's rows have
+been added Hook returns nil even if the insert it triggers
+fails, since they will be inserted by the next one and
+returning the error would have the logger pass t again. The
+failure is reported to OnError instead.
+*/
+func (in *Inserter) Hook(t logger.Thread) error {
+
+	base := row{
+		Date:          t.Date.UTC().Format("2006-01-02 15:04:05.000000000"),
+		Kind:          t.Kind.String(),
+		ThreadId:      t.Id,
+		Ip:            t.Ip,
+		Method:        t.Method,
+		Route:         t.Route,
+		Status:        t.Status,
+		Duration:      t.Duration,
+		CorrelationId: t.CorrelationId,
+		Outcome:       string(t.Outcome),
+		ThreadData:    map[string]string{},
+		Tags:          t.Tags,
+		Entry:         -1,
+		Data:          map[string]string{},
+	}
+	if base.Tags == nil {
+		base.Tags = []string{}
+	}
+	for _, kv := range t.KeyVals {
+		base.ThreadData[kv.Key] = fmt.Sprint(kv.Val)
+	}
+
+	rows := []row{base}
+	if len(t.Entries) > 0 {
+		rows = make([]row, len(t.Entries))
+		for i, e := range t.Entries {
+			r := base
+			r.Entry = i
+			r.Level = e.Level.String()
+			r.Message = e.Message
+			r.Key = e.Key
+			r.Function = e.Function
+			r.File = e.File
+			r.Line = e.Line
+			r.Data = map[string]string{}
+			for _, kv := range e.KeyVals {
+				r.Data[kv.Key] = fmt.Sprint(kv.Val)
+			}
+			rows[i] = r
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	max := in.MaxBuffer
+	if max <= 0 {
+		max = DefaultMaxBuffer
+	}
+
+	in.mu.Lock()
+	if in.rows+in.inflight+len(rows) > max {
+		in.mu.Unlock()
+		return ErrFull
+	}
+	in.buf = append(in.buf, buf.Bytes()...)
+	in.rows += len(rows)
+	full := in.MaxRows > 0 && in.rows >= in.MaxRows
+	in.mu.Unlock()
+
+	if full {
+		if err := in.Flush(); err != nil && in.OnError != nil {
+			in.OnError(err)
+		}
+	}
+	return nil
+}
+
+/*
+Flush inserts the accumulated rows, unless there are none.
+They are kept for the next attempt if the insert fails.
+*/
+func (in *Inserter) Flush() error {
+
+	in.flushMu.Lock()
+	defer in.flushMu.Unlock()
+
+	in.mu.Lock()
+	body, rows := in.buf, in.rows
+	in.buf, in.rows, in.inflight = nil, 0, rows
+	in.mu.Unlock()
+	if rows == 0 {
+		return nil
+	}
+
+	err := in.insert(body)
+
+	in.mu.Lock()
+	in.inflight = 0
+	if err != nil {
+		in.buf = append(body, in.buf...)
+		in.rows += rows
+	}
+	in.mu.Unlock()
+	return err
+}
+
+func (in *Inserter) insert(body []byte) error {
+
+	body, err := logger.Compress(in.Compression, body)
+	if err != nil {
+		return fmt.Errorf("clickhouse: %v", strings.TrimPrefix(err.Error(), "logger: "))
+	}
+
+	q := url.Values{"query": {"INSERT INTO " + in.Table + " FORMAT JSONEachRow"}}
+	req, err := http.NewRequest(http.MethodPost,
+		strings.TrimSuffix(in.URL, "/")+"/?"+q.Encode(),
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if in.Compression != "" {
+		req.Header.Set("Content-Encoding", in.Compression)
+	}
+	if in.User != "" {
+		req.Header.Set("X-ClickHouse-User", in.User)
+		req.Header.Set("X-ClickHouse-Key", in.Password)
+	}
+
+	if in.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+in.Token)
+	}
+	client, err := in.clients.Get(in.Client, in.TLS)
+	if err != nil {
+		return fmt.Errorf("clickhouse: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("clickhouse: insert responded %s: %s",
+			resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+/*
+Start flushes every interval until Close is called.
+*/
+func (in *Inserter) Start(every time.Duration) {
+
+	stop := make(chan struct{})
+	in.mu.Lock()
+	old := in.stop
+	in.stop = stop
+	in.mu.Unlock()
+	if old != nil {
+		close(old)
+	}
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := in.Flush(); err != nil && in.OnError != nil {
+					in.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+/*
+Close stops periodic flushing and flushes what remains.
+*/
+func (in *Inserter) Close() error {
+	in.mu.Lock()
+	stop := in.stop
+	in.stop = nil
+	in.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return in.Flush()
+}
@@ -0,0 +1,170 @@
+package clickhouse
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+server is a ClickHouse HTTP interface that fails the first
+fail inserts and decodes the rows of the rest.
+*/
+type server struct {
+	mu      sync.Mutex
+	fail    int
+	queries []string
+	rows    []row
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("X-ClickHouse-User") != "default" || r.Header.Get("X-ClickHouse-Key") != "secret" {
+		http.Error(w, "authentication failed", http.StatusUnauthorized)
+		return
+	}
+	if s.fail > 0 {
+		s.fail--
+		http.Error(w, "too many parts", http.StatusInternalServerError)
+		return
+	}
+	s.queries = append(s.queries, r.URL.Query().Get("query"))
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		var rw row
+		if err := json.Unmarshal(sc.Bytes(), &rw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.rows = append(s.rows, rw)
+	}
+}
+
+func newInserter(url string) *Inserter {
+	return &Inserter{
+		URL:      url,
+		Table:    "logs.threads",
+		User:     "default",
+		Password: "secret",
+	}
+}
+
+func TestInserterRows(t *testing.T) {
+
+	s := &server{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	in := newInserter(srv.URL)
+	th := logger.Thread{
+		Date:    time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		Kind:    logger.KindRequest,
+		Id:      "abc",
+		Method:  "GET",
+		Route:   "/users/:id",
+		Status:  404,
+		Entries: []*logger.Entry{{Level: logger.LevelInfo, Message: "One."}, {Level: logger.LevelError, Message: "Two."}},
+	}
+	th.Data("user", 7)
+	if err := in.Hook(th); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Hook(logger.Thread{Kind: logger.KindSession, Id: "empty"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.queries) != 1 || s.queries[0] != "INSERT INTO logs.threads FORMAT JSONEachRow" {
+		t.Fatalf("got queries %q", s.queries)
+	}
+	if len(s.rows) != 3 {
+		t.Fatalf("inserted %d rows, want 3", len(s.rows))
+	}
+	r := s.rows[1]
+	if r.Date != "2020-01-02 03:04:05.000000006" || r.ThreadId != "abc" || r.Status != 404 ||
+		r.Entry != 1 || r.Level != "Error" || r.Message != "Two." || r.ThreadData["user"] != "7" {
+		t.Fatalf("got row %+v", r)
+	}
+	if r := s.rows[2]; r.ThreadId != "empty" || r.Entry != -1 {
+		t.Fatalf("got row %+v for a thread without entries", r)
+	}
+}
+
+/*
+TestInserterRetry checks a thread's rows are inserted once
+when the insert Hook triggers fails.
+*/
+func TestInserterRetry(t *testing.T) {
+
+	s := &server{fail: 1}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	var errs []error
+	in := newInserter(srv.URL)
+	in.MaxRows = 1
+	in.OnError = func(err error) { errs = append(errs, err) }
+
+	if err := in.Hook(logger.Thread{Kind: logger.KindSession, Id: "one"}); err != nil {
+		t.Fatalf("Hook returned %v after buffering the thread", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("OnError was called %d times, want 1", len(errs))
+	}
+	if err := in.Hook(logger.Thread{Kind: logger.KindSession, Id: "two"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.rows) != 2 || s.rows[0].ThreadId != "one" || s.rows[1].ThreadId != "two" {
+		t.Fatalf("inserted %+v, want each thread once in order", s.rows)
+	}
+}
+
+func TestInserterFull(t *testing.T) {
+
+	s := &server{fail: 1 << 30}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	in := newInserter(srv.URL)
+	in.MaxBuffer = 1
+	if err := in.Hook(logger.Thread{Kind: logger.KindSession, Id: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Flush(); err == nil {
+		t.Fatal("Flush succeeded against a failing server")
+	}
+	if err := in.Hook(logger.Thread{Kind: logger.KindSession, Id: "two"}); err != ErrFull {
+		t.Fatalf("Hook returned %v past MaxBuffer, want ErrFull", err)
+	}
+}
+
+func TestInserterToken(t *testing.T) {
+
+	var auth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	in := newInserter(srv.URL)
+	in.Token = "jwt"
+	in.TLS = &logger.TLSOptions{InsecureSkipVerify: true}
+	if err := in.Hook(logger.Thread{Kind: logger.KindSession, Id: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer jwt" {
+		t.Fatalf("sent Authorization %q", auth)
+	}
+}
@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+SetTrustedProxies sets the addresses of proxies, as CIDR
+ranges or single IPs, whose forwarding headers are believed
+when Middleware records a request's client IP. Requests
+from a trusted proxy are attributed to the nearest address
+in the Forwarded header, or failing that X-Forwarded-For,
+that isn't itself a trusted proxy. X-Real-IP is used if a
+trusted proxy sent neither. Other requests, and all of them
+if no proxies are trusted, are attributed to the address
+they came from, since anyone can send the headers. Passing
+no proxies stops trusting any.
+*/
+func (l *Logger) SetTrustedProxies(proxies ...string) error {
+	nets, err := parseProxies(proxies)
+	if err != nil {
+		return err
+	}
+	l.update("proxies", func(s *settings) { s.proxies = nets })
+	return nil
+}
+
+func parseProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("logger: %q is not an IP or CIDR range", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("logger: %q is not an IP or CIDR range", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+/*
+requestIp returns the client IP of r, without a port. See
+SetTrustedProxies.
+*/
+func (l *Logger) requestIp(r *http.Request) string {
+
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	proxies := l.settings().proxies
+	if !trusted(proxies, remote) {
+		return remote
+	}
+
+	hops := forwardedFor(r.Header)
+	if len(hops) == 0 {
+		hops = splitHeader(r.Header, "X-Forwarded-For")
+	}
+	if len(hops) == 0 {
+		if ip := parseHop(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		return remote
+	}
+
+	// Each proxy appends the address it received the request
+	// from, so the client is the rightmost address that
+	// isn't a trusted proxy.
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == "" {
+			return remote
+		}
+		if !trusted(proxies, ip) || i == 0 {
+			return ip
+		}
+	}
+	return remote
+}
+
+func trusted(proxies []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+forwardedFor returns the for parameters of the RFC 7239
+Forwarded headers in h, in order.
+*/
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, elem := range splitHeader(h, "Forwarded") {
+		for _, pair := range strings.Split(elem, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				hops = append(hops, strings.Trim(kv[1], `"`))
+			}
+		}
+	}
+	return hops
+}
+
+func splitHeader(h http.Header, name string) []string {
+	var values []string
+	for _, line := range h[http.CanonicalHeaderKey(name)] {
+		for _, v := range strings.Split(line, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+/*
+parseHop returns the IP in an address from a forwarding
+header, which may have a port and, if it is IPv6, brackets.
+It returns an empty string if there isn't one, e.g. for the
+obfuscated identifiers Forwarded allows.
+*/
+func parseHop(s string) string {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if ip := net.ParseIP(s); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
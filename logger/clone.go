@@ -0,0 +1,204 @@
+package logger
+
+import "fmt"
+
+/*
+Option changes a Logger returned by Clone.
+*/
+type Option func(*Logger)
+
+/*
+WithOnLog replaces OnLog, e.g. to send a clone's threads to
+a different sink.
+*/
+func WithOnLog(fn func(Thread) error) Option {
+	return func(l *Logger) { l.OnLog = fn }
+}
+
+/*
+WithOnError replaces OnError.
+*/
+func WithOnError(fn func(Thread) error) Option {
+	return func(l *Logger) { l.OnError = fn }
+}
+
+/*
+WithLevel sets the minimum level. See SetLevel.
+*/
+func WithLevel(lv Level) Option {
+	return func(l *Logger) { l.SetLevel(lv) }
+}
+
+/*
+WithDebug enables or disables debug entries. See SetDebug.
+*/
+func WithDebug(enabled bool) Option {
+	return func(l *Logger) { l.SetDebug(enabled) }
+}
+
+/*
+WithNormalise controls message normalisation. See
+SetNormalise.
+*/
+func WithNormalise(enabled bool) Option {
+	return func(l *Logger) { l.SetNormalise(enabled) }
+}
+
+/*
+WithCatalog sets the catalog used when formatting. See
+SetCatalog.
+*/
+func WithCatalog(c Catalog) Option {
+	return func(l *Logger) { l.SetCatalog(c) }
+}
+
+/*
+WithDurationPrecision sets how durations are formatted. See
+SetDurationPrecision.
+*/
+func WithDurationPrecision(p Precision) Option {
+	return func(l *Logger) { l.SetDurationPrecision(p) }
+}
+
+/*
+WithHumanize sets how FormatPretty renders integers. See
+SetHumanize.
+*/
+func WithHumanize(h Humanize) Option {
+	return func(l *Logger) { l.SetHumanize(h) }
+}
+
+/*
+WithTreeGlyphs sets the glyphs FormatPretty draws entries
+with. See SetTreeGlyphs.
+*/
+func WithTreeGlyphs(g TreeGlyphs) Option {
+	return func(l *Logger) { l.SetTreeGlyphs(g) }
+}
+
+/*
+WithComponentLevels replaces the component levels. See
+SetComponentLevels.
+*/
+func WithComponentLevels(levels map[string]Level) Option {
+	return func(l *Logger) { l.SetComponentLevels(levels) }
+}
+
+/*
+WithField attaches k and v to every entry the clone logs,
+after any fields it inherited.
+*/
+func WithField(k string, v interface{}) Option {
+	return func(l *Logger) {
+		l.fields = append(l.fields, kv{k, l.redact(k, v)})
+	}
+}
+
+/*
+Clone returns a Logger that shares l's threads and id
+sequence and inherits l's settings, except those changed by
+opts or by calling its setters. Changes made to l later,
+e.g. by AdminHandler, reach the clone unless it has set the
+same option itself. Threads are passed to the hooks of
+whichever logger ends them, so one process can route, say,
+audit threads and request threads differently.
+
+Clones form a tree: Flush and Close on a logger cascade to
+its clones and theirs, so flushing the root at shutdown
+covers every subsystem. Clone is therefore meant for
+long-lived loggers rather than one per request.
+
+The clone is synchronous and has no write-ahead log even if
+l does, since those own a goroutine and a file respectively;
+call SetAsync or SetWAL on it as needed.
+*/
+func (l *Logger) Clone(opts ...Option) *Logger {
+
+	c := &Logger{
+		OnLog:           l.OnLog,
+		OnError:         l.OnError,
+		Enrich:          l.Enrich,
+		OnInternalError: l.OnInternalError,
+		root:            l.shared(),
+		parent:          l,
+		fields:          append([]kv(nil), l.fields...),
+	}
+
+	// Settings are never modified once stored, so the clone
+	// can start with l's and replace them as it's changed.
+	c.cfg.Store(l.settings())
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	l.childrenMu.Lock()
+	l.children = append(l.children, c)
+	l.childrenMu.Unlock()
+
+	// Pick up any change made to l before c was added to
+	// its children.
+	c.inherit()
+
+	return c
+}
+
+/*
+Close flushes l and its clones, emits any pending throttling
+summaries, stops their asynchronous dispatchers and closes
+their write-ahead logs and the files and sockets of sinks
+opened by Config.New. Threads that handlers are still
+ending while Close runs are drained rather than lost, and
+those ended afterwards are emitted synchronously and not
+written ahead. A closed clone is removed from its parent
+and no longer inherits its settings.
+*/
+func (l *Logger) Close() {
+	for _, c := range l.clones() {
+		c.Close()
+	}
+	if p := l.parent; p != nil {
+		p.childrenMu.Lock()
+		for i, c := range p.children {
+			if c == l {
+				p.children = append(p.children[:i], p.children[i+1:]...)
+				break
+			}
+		}
+		p.childrenMu.Unlock()
+	}
+	l.SetThrottle(ThrottleOptions{})
+	l.SetProgress(ProgressOptions{})
+	l.SetMaxAge(0)
+	l.SetQueueReporting(0)
+	l.SetAsync(AsyncOptions{})
+	l.SetWAL("")
+	l.SetDeadLetter("")
+
+	l.sinksMu.Lock()
+	sinks := l.sinks
+	l.sinks = nil
+	l.sinksMu.Unlock()
+	for _, c := range sinks {
+		if err := c.Close(); err != nil {
+			l.internalError(fmt.Errorf("logger: closing sink: %s", err))
+		}
+	}
+}
+
+func (l *Logger) clones() []*Logger {
+	l.childrenMu.Lock()
+	defer l.childrenMu.Unlock()
+	return append([]*Logger(nil), l.children...)
+}
+
+/*
+shared returns the logger whose threads and id sequence l
+uses, which is l itself unless it is a clone.
+*/
+func (l *Logger) shared() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
@@ -0,0 +1,134 @@
+package logger
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+/*
+TestCloseWhileEnding closes asynchronous loggers while
+goroutines are still ending threads. Every thread must be
+emitted, before or after Close, without panicking.
+*/
+func TestCloseWhileEnding(t *testing.T) {
+
+	// Run the goroutines in parallel even on one CPU so the
+	// scheduler can interleave them anywhere.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	for round := 0; round < 50; round++ {
+		closeWhileEnding(t)
+	}
+}
+
+func closeWhileEnding(t *testing.T) {
+
+	var emitted int64
+	l := &Logger{OnLog: func(Thread) error {
+		atomic.AddInt64(&emitted, 1)
+		return nil
+	}}
+	l.SetAsync(AsyncOptions{QueueSize: 4})
+
+	const goroutines, threads = 8, 500
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < threads; j++ {
+				id := l.NewId()
+				l.Info(id, "handled")
+				l.End(id, "", "GET", "/", 0)
+			}
+		}()
+	}
+
+	// Restart the dispatcher repeatedly so ends race with
+	// it being stopped, then close the logger mid-flight.
+	close(start)
+	for i := 0; i < 100; i++ {
+		l.SetAsync(AsyncOptions{QueueSize: 4})
+	}
+	l.Close()
+	wg.Wait()
+
+	if n := atomic.LoadInt64(&emitted); n != goroutines*threads {
+		t.Fatalf("emitted %d threads, want %d", n, goroutines*threads)
+	}
+}
+
+/*
+TestCloneInherits changes a logger after cloning it and
+checks its clones, and theirs, follow except where they set
+the option themselves.
+*/
+func TestCloneInherits(t *testing.T) {
+
+	root := &Logger{}
+	child := root.Clone(WithLevel(LevelError))
+	grandchild := child.Clone()
+
+	root.SetLevel(LevelInfo)
+	root.SetRedactKeys("password")
+	root.SetDebug(true)
+	for _, c := range []*Logger{child, grandchild} {
+		if s := c.settings(); s.level != LevelError || !s.debug ||
+			len(s.redactKeys) != 1 || s.redactKeys[0] != "password" {
+			t.Fatalf("clone has level %v, debug %v and redact keys %v",
+				s.level, s.debug, s.redactKeys)
+		}
+	}
+
+	grandchild.SetDebug(false)
+	root.SetDebug(true)
+	if !child.settings().debug || grandchild.settings().debug {
+		t.Fatal("parent overrode a clone's own setting")
+	}
+
+	// Setting an option again replaces the clone's earlier
+	// override rather than adding to them.
+	child.SetLevel(LevelDebug)
+	child.SetLevel(LevelInfo)
+	if n := len(child.overrides); n != 1 {
+		t.Fatalf("clone has %d overrides, want 1", n)
+	}
+	if lv := grandchild.settings().level; lv != LevelInfo {
+		t.Fatalf("grandchild has level %v, want %v", lv, LevelInfo)
+	}
+
+	// Classifiers added to a clone are consulted after its
+	// parent's, including those the parent adds later.
+	child.AddClassifier(func(error) Category { return "child" })
+	root.AddClassifier(func(error) Category { return "root" })
+	if n := len(child.settings().classifiers); n != 2 {
+		t.Fatalf("clone has %d classifiers, want 2", n)
+	}
+	if c := child.classify(errors.New("x")); c != "root" {
+		t.Fatalf("clone classified an error as %q, want root", c)
+	}
+}
+
+func TestCloneClose(t *testing.T) {
+
+	root := &Logger{}
+	a, b := root.Clone(), root.Clone()
+	a.Close()
+	if cs := root.clones(); len(cs) != 1 || cs[0] != b {
+		t.Fatalf("root has clones %v, want only the open one", cs)
+	}
+
+	root.SetLevel(LevelError)
+	if lv := a.settings().level; lv == LevelError {
+		t.Fatal("closed clone still inherits its parent's settings")
+	}
+	b.Close()
+	if cs := root.clones(); len(cs) != 0 {
+		t.Fatalf("root has %d clones after closing them", len(cs))
+	}
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+GoogleCloud formats threads as the structured logs Cloud Run,
+App Engine and GKE read from stdout, so Cloud Logging takes
+their severity, request and trace from the fields it
+expects rather than treating each thread as plain text.
+*/
+type GoogleCloud struct {
+
+	// ProjectId qualifies trace ids so Cloud Logging can
+	// link entries to Cloud Trace. Threads aren't linked if
+	// it is empty.
+	ProjectId string
+
+	// Grouped writes each entry on a line of its own after
+	// a line for the thread, like FormatJSONEntries. The
+	// lines share the thread's trace, or failing that an
+	// operation id, so Cloud Logging nests the entries
+	// under their request.
+	Grouped bool
+}
+
+/*
+DefaultGoogleCloud returns a GoogleCloud for the project
+named by the GOOGLE_CLOUD_PROJECT or GCP_PROJECT environment
+variables.
+*/
+func DefaultGoogleCloud() GoogleCloud {
+	id := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if id == "" {
+		id = os.Getenv("GCP_PROJECT")
+	}
+	return GoogleCloud{ProjectId: id}
+}
+
+/*
+Format formats t as one line of JSON with the severity of
+its highest level entry. Requests are described by an
+httpRequest object and those with a TraceContext are given
+the trace and span ids. Entries and data are kept in the
+payload as FormatJSON writes them.
+*/
+func (g GoogleCloud) Format(t Thread) string {
+
+	o := &jsonObject{}
+	o.add("severity", strings.ToUpper(t.level().String()))
+	o.add("message", t.summary())
+	o.add("time", t.Date.Format(time.RFC3339Nano))
+	if t.Kind == KindRequest {
+		o.add("httpRequest", t.httpRequest())
+	}
+	traced := g.addTrace(o, t)
+	if !g.Grouped {
+		t.addPayload(o)
+		return string(o.close()) + "\n"
+	}
+
+	entries := t.Entries
+	t.Entries = nil
+	if !traced {
+		g.addOperation(o, t, true, len(entries) == 0)
+	}
+	t.addPayload(o)
+
+	var b bytes.Buffer
+	b.Write(o.close())
+	b.WriteByte('\n')
+	for i, e := range entries {
+		eo := &jsonObject{}
+		eo.add("severity", strings.ToUpper(e.Level.String()))
+		eo.add("message", t.message(e))
+		eo.add("time", t.Date.Format(time.RFC3339Nano))
+		if !g.addTrace(eo, t) {
+			g.addOperation(eo, t, false, i == len(entries)-1)
+		}
+		eo.addIf("thread", t.Id != "", t.Id)
+		eo.add("index", e.index)
+		eo.addIf("seq", e.Seq != 0, e.Seq)
+		eo.addIf("key", e.Key != "", e.Key)
+		eo.addIf("function", e.Function != "", e.Function)
+		eo.addIf("file", e.File != "", e.File)
+		eo.addIf("line", e.Line != 0, e.Line)
+		if len(e.KeyVals) > 0 {
+			eo.add("data", jsonKVs(e.KeyVals))
+		}
+		b.Write(eo.close())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+/*
+addTrace links o to the trace of t and reports whether it
+could.
+*/
+func (g GoogleCloud) addTrace(o *jsonObject, t Thread) bool {
+	tc, ok := t.Meta[MetaTrace].(TraceContext)
+	if !ok || g.ProjectId == "" {
+		return false
+	}
+	o.add("logging.googleapis.com/trace", fmt.Sprintf("projects/%s/traces/%s", g.ProjectId, tc.TraceId))
+	o.add("logging.googleapis.com/spanId", tc.SpanId)
+	if tc.Sampled != nil {
+		o.add("logging.googleapis.com/trace_sampled", *tc.Sampled)
+	}
+	return true
+}
+
+func (g GoogleCloud) addOperation(o *jsonObject, t Thread, first, last bool) {
+	op := &jsonObject{}
+	op.add("id", t.Id)
+	op.addIf("first", first, true)
+	op.addIf("last", last, true)
+	o.add("logging.googleapis.com/operation", op.close())
+}
+
+/*
+ExtractCloudTrace reads a trace context from the
+X-Cloud-Trace-Context header Google Cloud load balancers
+add, e.g. 105445aa7843bc8bf206b12000100000/1;o=1. Its
+decimal span id is converted to hex.
+*/
+func ExtractCloudTrace(h http.Header) (TraceContext, bool) {
+
+	s := h.Get("X-Cloud-Trace-Context")
+	slash := strings.IndexByte(s, '/')
+	if slash == -1 {
+		return TraceContext{}, false
+	}
+	tc := TraceContext{TraceId: strings.ToLower(s[:slash])}
+	span, options := s[slash+1:], ""
+	if semi := strings.IndexByte(span, ';'); semi != -1 {
+		span, options = span[:semi], span[semi+1:]
+	}
+
+	id, err := strconv.ParseUint(span, 10, 64)
+	if err != nil || id == 0 || !validTraceId(tc.TraceId) {
+		return TraceContext{}, false
+	}
+	tc.SpanId = fmt.Sprintf("%016x", id)
+	switch options {
+	case "o=1":
+		tc.Sampled = sampled(true)
+	case "o=0":
+		tc.Sampled = sampled(false)
+	}
+	return tc, true
+}
+
+/*
+httpRequest describes a request thread in the form of Cloud
+Logging's HttpRequest.
+*/
+func (t Thread) httpRequest() json.RawMessage {
+	o := &jsonObject{}
+	o.addIf("requestMethod", t.Method != "", t.Method)
+	o.addIf("requestUrl", t.Route != "", t.Route)
+	o.addIf("status", t.Status != 0, t.Status)
+	o.add("latency", fmt.Sprintf("%.9fs", time.Duration(t.Duration).Seconds()))
+	if ip := clientIp(t); ip != "" {
+		o.add("remoteIp", ip)
+	}
+	return o.close()
+}
+
+/*
+FormatLambda formats t as one line in the JSON log format of
+AWS Lambda, with a timestamp and a level Lambda can filter
+on. Entries and data are kept as FormatJSON writes them.
+*/
+func (t Thread) FormatLambda() string {
+	o := &jsonObject{}
+	o.add("timestamp", t.Date.UTC().Format("2006-01-02T15:04:05.000Z"))
+	o.add("level", strings.ToUpper(t.level().String()))
+	o.add("message", t.summary())
+	t.addPayload(o)
+	return string(o.close()) + "\n"
+}
+
+/*
+addPayload adds the fields of FormatJSON that describe t
+beyond its time, severity and message.
+*/
+func (t Thread) addPayload(o *jsonObject) {
+	o.addIf("kind", t.Kind.name != "", t.Kind.name)
+	o.addIf("id", t.Id != "", t.Id)
+	o.addIf("correlationId", t.CorrelationId != "", t.CorrelationId)
+	o.addIf("outcome", t.Outcome != "", t.Outcome)
+	o.addIf("chunk", t.Chunk != 0, t.Chunk)
+	o.addIf("unterminated", t.Unterminated, true)
+	o.addIf("timedOut", t.TimedOut, true)
+	if len(t.KeyVals) > 0 {
+		o.add("data", jsonKVs(t.KeyVals))
+	}
+	o.addIf("tags", len(t.Tags) > 0, t.Tags)
+	if len(t.Entries) > 0 {
+		var entries []json.RawMessage
+		for _, e := range t.Entries {
+			entries = append(entries, t.jsonEntry(e, false).close())
+		}
+		o.add("entries", entries)
+	}
+}
+
+/*
+level returns the level of t's highest level entry, or
+LevelInfo if it has none.
+*/
+func (t Thread) level() Level {
+	if len(t.Entries) == 0 {
+		return LevelInfo
+	}
+	level := LevelDebug
+	for _, e := range t.Entries {
+		if e.Level > level {
+			level = e.Level
+		}
+	}
+	return level
+}
+
+/*
+platformFormat returns the format suited to the serverless
+platform the process is running on, if any.
+*/
+func platformFormat() string {
+	switch {
+	case os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "":
+		return "lambda"
+	case os.Getenv("K_SERVICE") != "", os.Getenv("GAE_SERVICE") != "":
+		return "googleCloud"
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+)
+
+/*
+Command is the thread of one run of a command line program,
+of KindCommand, so each invocation is logged as a whole with
+its flags, duration and exit status.
+
+With cobra it can be opened from the root command's
+PersistentPreRun and ended once Execute returns, since
+PersistentPostRun isn't run if the command fails:
+
+	var run *logger.Command
+
+	root.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		flags := map[string]string{}
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			flags[f.Name] = f.Value.String()
+		})
+		run = log.Command(cmd.CommandPath(), args, flags)
+	}
+
+	func main() {
+		err := root.Execute()
+		code := 0
+		if err != nil {
+			code = 1
+		}
+		if run != nil {
+			run.End(code, err)
+		}
+		os.Exit(code)
+	}
+*/
+type Command struct {
+
+	// Id is the id of the command's thread.
+	Id string
+
+	logger *Logger
+	path   string
+}
+
+/*
+Command opens a thread for the command path, e.g. "app db
+migrate", run with args and flags. The args are attached as
+the thread data "args" and each flag as "flag." followed by
+its name, in name order. Flags whose names are redacted by
+SetRedactKeys have their values redacted.
+*/
+func (l *Logger) Command(path string, args []string, flags map[string]string) *Command {
+
+	c := &Command{
+		Id:     l.NewId(),
+		logger: l,
+		path:   path,
+	}
+	l.Begin(c.Id)
+
+	if len(args) > 0 {
+		l.ThreadData(c.Id, "args", strings.Join(args, " "))
+	}
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l.ThreadData(c.Id, "flag."+name, l.redact(name, flags[name]))
+	}
+	return c
+}
+
+/*
+End ends the command's thread with code as its exit status,
+which is the thread's Status. If err isn't nil it is logged
+as an error first.
+*/
+func (c *Command) End(code int, err error) (Thread, bool) {
+	if err != nil {
+		c.logger.Error(c.Id, err.Error()).Err(err)
+	}
+	c.logger.setStatus(c.Id, code, false)
+	return c.logger.end(KindCommand, c.Id, "", "", c.path, 0)
+}
@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+/*
+SetComponentLevels overrides the minimum level for entries
+logged by particular components, e.g. {"db": LevelDebug}.
+A component is a package, named by its import path or the
+last element of it, or a Component's name. An override
+replaces both SetLevel and SetDebug for its component, so
+debug entries can be enabled for one package only.
+
+Finding the package of a call site costs about as much as
+SetRuntime, so while any overrides are set even suppressed
+entries are no longer free: each makes 2 allocations.
+Passing nil removes them all.
+*/
+func (l *Logger) SetComponentLevels(levels map[string]Level) {
+	c := make(map[string]Level, len(levels))
+	for k, v := range levels {
+		c[k] = v
+	}
+	if len(c) == 0 {
+		c = nil
+	}
+	l.update("compLevels", func(s *settings) { s.compLevels = c })
+}
+
+/*
+ComponentLevels returns a copy of the overrides set by
+SetComponentLevels.
+*/
+func (l *Logger) ComponentLevels() map[string]Level {
+	levels := l.components()
+	c := make(map[string]Level, len(levels))
+	for k, v := range levels {
+		c[k] = v
+	}
+	return c
+}
+
+/*
+ParseComponentLevels parses overrides for SetComponentLevels
+written like "db=debug, http=info".
+*/
+func ParseComponentLevels(s string) (map[string]Level, error) {
+	levels := map[string]Level{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i == -1 {
+			return nil, fmt.Errorf("logger: %q isn't of the form component=level", pair)
+		}
+		lv, err := ParseLevel(strings.TrimSpace(pair[i+1:]))
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(pair[:i])] = lv
+	}
+	return levels, nil
+}
+
+/*
+components returns the current overrides. The map is replaced
+rather than modified so it must not be changed.
+*/
+func (l *Logger) components() map[string]Level {
+	return l.settings().compLevels
+}
+
+/*
+mayLog reports whether an entry at level could be logged by
+some component. It lets callers skip formatting a message
+before the component is known.
+*/
+func (l *Logger) mayLog(level Level) bool {
+	return l.enabled(level) || len(l.components()) > 0 ||
+		level == LevelDebug && l.debugRoutesSet()
+}
+
+/*
+componentEnabled reports whether an entry at level would be
+logged for component, which must not be empty.
+*/
+func (l *Logger) componentEnabled(component string, level Level) bool {
+	if min, ok := componentLevel(l.components(), component); ok {
+		return level >= min
+	}
+	return l.enabled(level)
+}
+
+/*
+componentLevel looks component up in levels by its full name,
+then the last element of its import path and then, for nested
+names like "db.pool", each enclosing name in turn.
+*/
+func componentLevel(levels map[string]Level, component string) (Level, bool) {
+	if levels == nil {
+		return 0, false
+	}
+	if lv, ok := levels[component]; ok {
+		return lv, true
+	}
+	if i := strings.LastIndex(component, "/"); i != -1 {
+		component = component[i+1:]
+		if lv, ok := levels[component]; ok {
+			return lv, true
+		}
+	}
+	for i := strings.LastIndex(component, "."); i != -1; i = strings.LastIndex(component, ".") {
+		component = component[:i]
+		if lv, ok := levels[component]; ok {
+			return lv, true
+		}
+	}
+	return 0, false
+}
+
+/*
+caller returns the frame of the first function outside this
+module on the stack, i.e. the one that called the logger.
+*/
+func caller() (runtime.Frame, bool) {
+
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !inModule(frame.Function) {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+/*
+framePackage returns the import path of the package function
+belongs to, e.g. "example.com/app/db" for
+"example.com/app/db.(*Store).Get".
+*/
+func framePackage(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot != -1 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	compressionsMu sync.Mutex
+	compressions   = map[string]func(io.Writer) (io.WriteCloser, error){
+		"gzip": func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		"deflate": func(w io.Writer) (io.WriteCloser, error) {
+			return zlib.NewWriter(w), nil
+		},
+	}
+)
+
+/*
+RegisterCompression makes the content encoding name, e.g.
+"zstd", available to the Compression option of sinks, with
+fn returning a writer that compresses what is written to it
+into w. gzip and deflate are built in. zstd is registered by
+importing github.com/jakebowkett/go-logger/logger/zstd, which
+is a module of its own so this one needs nothing outside the
+standard library.
+*/
+func RegisterCompression(name string, fn func(w io.Writer) (io.WriteCloser, error)) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[name] = fn
+}
+
+/*
+Compress returns b compressed with the named content
+encoding, or b itself if name is empty. It is an error if
+the encoding hasn't been registered. See RegisterCompression.
+*/
+func Compress(name string, b []byte) ([]byte, error) {
+	if name == "" {
+		return b, nil
+	}
+	compressionsMu.Lock()
+	fn := compressions[name]
+	compressionsMu.Unlock()
+	if fn == nil {
+		return nil, fmt.Errorf("logger: unsupported compression %q", name)
+	}
+	var buf bytes.Buffer
+	w, err := fn(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
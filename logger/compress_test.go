@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+
+	want := []byte(strings.Repeat("Hello. ", 100))
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+	}
+	for name, newReader := range readers {
+		b, err := Compress(name, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := newReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: decompressed %q, %v", name, got, err)
+		}
+	}
+
+	if b, _ := Compress("", want); !bytes.Equal(b, want) {
+		t.Fatal("compressed without an encoding")
+	}
+	if _, err := Compress("lz4", want); err == nil {
+		t.Fatal("compressed with an unregistered encoding")
+	}
+}
+
+func TestSocketCompression(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		received <- b
+	}()
+
+	s := &Socket{Network: "tcp", Addr: ln.Addr().String(), Compression: "gzip"}
+	for _, line := range []string{"one\n", "two\n"} {
+		if n, err := s.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("Write returned %d, %v", n, err)
+		}
+	}
+	s.Close()
+
+	r, err := gzip.NewReader(bytes.NewReader(<-received))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil || string(got) != "one\ntwo\n" {
+		t.Fatalf("server decompressed %q, %v", got, err)
+	}
+}
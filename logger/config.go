@@ -0,0 +1,474 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/*
+Config describes a Logger so it can be built from a file
+rather than code. Its JSON form is read by FromConfig, as is
+its YAML form once the yamlconfig module is imported. The
+yaml tags give each field the same name as in JSON.
+*/
+type Config struct {
+
+	// Level is the minimum level: debug, info or error.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+
+	// Components overrides Level for particular packages,
+	// e.g. {"db": "debug"}. See SetComponentLevels.
+	Components map[string]string `json:"components,omitempty" yaml:"components,omitempty"`
+
+	// DebugRoutes enables debug entries for matching
+	// requests. See SetDebugRoutes.
+	DebugRoutes []string `json:"debugRoutes,omitempty" yaml:"debugRoutes,omitempty"`
+
+	Debug   bool `json:"debug,omitempty" yaml:"debug,omitempty"`
+	Runtime bool `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+
+	// Normalise defaults to true.
+	Normalise *bool `json:"normalise,omitempty" yaml:"normalise,omitempty"`
+
+	// DurationPrecision is ms (the default), us or adaptive.
+	// See SetDurationPrecision.
+	DurationPrecision string `json:"durationPrecision,omitempty" yaml:"durationPrecision,omitempty"`
+
+	// Humanize is none (the default), separators or compact.
+	// See SetHumanize.
+	Humanize string `json:"humanize,omitempty" yaml:"humanize,omitempty"`
+
+	// ASCIITree draws pretty output's entry tree with ASCII
+	// rather than box-drawing characters. See ASCIIGlyphs.
+	ASCIITree bool `json:"asciiTree,omitempty" yaml:"asciiTree,omitempty"`
+
+	// Sinks are where ended threads are written. With none
+	// OnLog is left for the caller to set.
+	Sinks []SinkConfig `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+
+	// Sampling keeps a fraction of the threads written to
+	// Sinks. See Sampled.
+	Sampling *SamplingConfig `json:"sampling,omitempty" yaml:"sampling,omitempty"`
+
+	Async *AsyncConfig `json:"async,omitempty" yaml:"async,omitempty"`
+
+	// WAL is the path of a write-ahead log. See SetWAL.
+	WAL string `json:"wal,omitempty" yaml:"wal,omitempty"`
+
+	// DeadLetter is the path threads are written to when
+	// the sinks fail. See SetDeadLetter.
+	DeadLetter string `json:"deadLetter,omitempty" yaml:"deadLetter,omitempty"`
+
+	// Process stamps every thread with the host, PID and
+	// instance id. See DefaultProcessInfo.
+	Process bool `json:"process,omitempty" yaml:"process,omitempty"`
+
+	// Build stamps every thread with the binary's version
+	// and revision. See ReadBuildInfo.
+	Build bool `json:"build,omitempty" yaml:"build,omitempty"`
+
+	// Kubernetes stamps every thread with the pod,
+	// namespace, node and container. See
+	// DefaultKubernetesInfo.
+	Kubernetes bool `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
+
+	// Snapshots attaches a runtime snapshot to threads with
+	// errors. See SetSnapshots.
+	Snapshots bool `json:"snapshots,omitempty" yaml:"snapshots,omitempty"`
+
+	// Baggage lists the baggage keys attached to requests.
+	// See SetBaggageKeys.
+	Baggage []string `json:"baggage,omitempty" yaml:"baggage,omitempty"`
+
+	// Redact lists keys whose values are redacted. See
+	// SetRedactKeys.
+	Redact []string `json:"redact,omitempty" yaml:"redact,omitempty"`
+
+	// TrustedProxies lists the CIDR ranges or IPs of proxies
+	// whose forwarding headers give the client IP. See
+	// SetTrustedProxies.
+	TrustedProxies []string `json:"trustedProxies,omitempty" yaml:"trustedProxies,omitempty"`
+}
+
+type SinkConfig struct {
+
+	// Type is stderr, stdout, file, unix or unixgram. See
+	// Socket for the last two.
+	Type string `json:"type" yaml:"type"`
+
+	// Path is the file appended to by file sinks or the
+	// socket written to by unix and unixgram sinks.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// Format is pretty (the default), terse, record,
+	// waterfall, msgpack, json, jsonEntries, googleCloud,
+	// googleCloudGrouped or lambda.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// Color colours levels in pretty and terse output.
+	Color bool `json:"color,omitempty" yaml:"color,omitempty"`
+
+	// Level omits entries below it from this sink's output
+	// and threads left without entries aren't written.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+type SamplingConfig struct {
+
+	// Every keeps one in every Every threads.
+	Every int `json:"every" yaml:"every"`
+
+	// KeepTags are kept whatever the sampling, as are
+	// threads with errors.
+	KeepTags []string `json:"keepTags,omitempty" yaml:"keepTags,omitempty"`
+}
+
+type AsyncConfig struct {
+	QueueSize int `json:"queueSize" yaml:"queueSize"`
+
+	// Policy is block (the default), dropNewest, dropOldest
+	// or sample. SampleEvery is used by the sample policy,
+	// which only applies while the queue is full; Sampling
+	// samples threads regardless.
+	Policy      string   `json:"policy,omitempty" yaml:"policy,omitempty"`
+	SampleEvery int      `json:"sampleEvery,omitempty" yaml:"sampleEvery,omitempty"`
+	KeepTags    []string `json:"keepTags,omitempty" yaml:"keepTags,omitempty"`
+}
+
+var dropPolicies = map[string]DropPolicy{
+	"block":      Block,
+	"dropnewest": DropNewest,
+	"dropoldest": DropOldest,
+	"sample":     Sample,
+}
+
+var (
+	configFormatsMu sync.Mutex
+	configFormats   = map[string]func(data []byte, c *Config) error{}
+)
+
+/*
+RegisterConfigFormat makes FromConfig decode files whose
+extension is ext, e.g. ".yaml", with decode, which should
+reject unknown fields and give the line of any error. It is
+meant to be called by the init function of a package such
+as yamlconfig so importing it is enough, keeping this
+package free of dependencies outside the standard library.
+*/
+func RegisterConfigFormat(ext string, decode func(data []byte, c *Config) error) {
+	configFormatsMu.Lock()
+	defer configFormatsMu.Unlock()
+	configFormats[strings.ToLower(ext)] = decode
+}
+
+/*
+FromConfig returns a Logger built from the Config in the file
+at path. It is decoded as JSON unless a format has been
+registered for the file's extension; to read .yaml and .yml
+files import github.com/jakebowkett/go-logger/logger/yamlconfig.
+Unknown fields and invalid values are errors that name the
+offending field, and syntax errors give the line and column
+they occurred at.
+*/
+func FromConfig(path string) (*Logger, error) {
+
+	ext := strings.ToLower(filepath.Ext(path))
+	configFormatsMu.Lock()
+	decode := configFormats[ext]
+	configFormatsMu.Unlock()
+	if decode == nil && (ext == ".yaml" || ext == ".yml") {
+		return nil, fmt.Errorf("logger: %s: import github.com/jakebowkett/go-logger/logger/yamlconfig to read YAML", path)
+	}
+	if decode == nil {
+		decode = decodeJSONConfig
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := decode(data, &c); err != nil {
+		return nil, fmt.Errorf("logger: %s: %s", path, err)
+	}
+
+	l, err := c.New()
+	if err != nil {
+		return nil, fmt.Errorf("logger: %s: %v", path, strings.TrimPrefix(err.Error(), "logger: "))
+	}
+	return l, nil
+}
+
+func decodeJSONConfig(data []byte, c *Config) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return errors.New(jsonError(data, err))
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after config")
+	}
+	return nil
+}
+
+/*
+jsonError adds the line and column to errors from
+encoding/json that only give an offset.
+*/
+func jsonError(data []byte, err error) string {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+		if e.Field != "" {
+			return fmt.Sprintf("%s: expected %s, found %s", e.Field, e.Type, e.Value)
+		}
+	default:
+		return strings.TrimPrefix(err.Error(), "json: ")
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n') - 1
+	return fmt.Sprintf("line %d, column %d: %v", line, col, err)
+}
+
+/*
+Validate reports the first invalid field of c.
+*/
+func (c Config) Validate() error {
+	if c.Level != "" {
+		if _, err := ParseLevel(c.Level); err != nil {
+			return fmt.Errorf("logger: level: %q is not debug, info or error", c.Level)
+		}
+	}
+	if c.DurationPrecision != "" {
+		if _, err := ParsePrecision(c.DurationPrecision); err != nil {
+			return fmt.Errorf("logger: durationPrecision: %q is not ms, us or adaptive", c.DurationPrecision)
+		}
+	}
+	if c.Humanize != "" {
+		if _, err := ParseHumanize(c.Humanize); err != nil {
+			return fmt.Errorf("logger: humanize: %q is not none, separators or compact", c.Humanize)
+		}
+	}
+	for name, lv := range c.Components {
+		if _, err := ParseLevel(lv); err != nil {
+			return fmt.Errorf("logger: components.%s: %q is not debug, info or error", name, lv)
+		}
+	}
+	for i, p := range c.DebugRoutes {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("logger: debugRoutes[%d]: %q is not a valid pattern", i, p)
+		}
+	}
+	for i, p := range c.TrustedProxies {
+		if _, err := parseProxies([]string{p}); err != nil {
+			return fmt.Errorf("logger: trustedProxies[%d]: %q is not an IP or CIDR range", i, p)
+		}
+	}
+	for i, s := range c.Sinks {
+		field := fmt.Sprintf("logger: sinks[%d]", i)
+		switch s.Type {
+		case "stderr", "stdout":
+		case "file":
+			if s.Path == "" {
+				return fmt.Errorf("%s.path: required for file sinks", field)
+			}
+		case "unix", "unixgram":
+			if s.Path == "" {
+				return fmt.Errorf("%s.path: required for socket sinks", field)
+			}
+		case "":
+			return fmt.Errorf("%s.type: required", field)
+		default:
+			return fmt.Errorf("%s.type: %q is not stderr, stdout, file, unix or unixgram", field, s.Type)
+		}
+		if _, err := formatter(s.Format, false); err != nil || strings.EqualFold(s.Format, "none") {
+			return fmt.Errorf("%s.format: %q is not pretty, terse, record, waterfall, msgpack, json, jsonEntries, googleCloud, googleCloudGrouped or lambda", field, s.Format)
+		}
+		if s.Level != "" {
+			if _, err := ParseLevel(s.Level); err != nil {
+				return fmt.Errorf("%s.level: %q is not debug, info or error", field, s.Level)
+			}
+		}
+	}
+	if s := c.Sampling; s != nil {
+		if s.Every <= 0 {
+			return fmt.Errorf("logger: sampling.every: must be positive")
+		}
+		if len(c.Sinks) == 0 {
+			return fmt.Errorf("logger: sampling: requires sinks")
+		}
+	}
+	if a := c.Async; a != nil {
+		if a.QueueSize <= 0 {
+			return fmt.Errorf("logger: async.queueSize: must be positive")
+		}
+		if _, ok := dropPolicies[strings.ToLower(a.Policy)]; !ok && a.Policy != "" {
+			return fmt.Errorf("logger: async.policy: %q is not block, dropNewest, dropOldest or sample", a.Policy)
+		}
+		if a.SampleEvery < 0 {
+			return fmt.Errorf("logger: async.sampleEvery: must not be negative")
+		}
+	}
+	return nil
+}
+
+/*
+New validates c and returns a Logger configured by it.
+*/
+func (c Config) New() (*Logger, error) {
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{}
+	if c.Level != "" {
+		lv, _ := ParseLevel(c.Level)
+		l.SetLevel(lv)
+		if lv == LevelDebug {
+			c.Debug = true
+		}
+	}
+	l.SetDebug(c.Debug)
+	if len(c.Components) > 0 {
+		levels := map[string]Level{}
+		for name, lv := range c.Components {
+			levels[name], _ = ParseLevel(lv)
+		}
+		l.SetComponentLevels(levels)
+	}
+	l.SetRuntime(c.Runtime)
+	if c.Normalise != nil {
+		l.SetNormalise(*c.Normalise)
+	}
+	if c.DurationPrecision != "" {
+		p, _ := ParsePrecision(c.DurationPrecision)
+		l.SetDurationPrecision(p)
+	}
+	if c.Humanize != "" {
+		h, _ := ParseHumanize(c.Humanize)
+		l.SetHumanize(h)
+	}
+	if c.ASCIITree {
+		l.SetTreeGlyphs(ASCIIGlyphs)
+	}
+	l.SetDebugRoutes(c.DebugRoutes...)
+	if c.Process {
+		l.SetProcessInfo(DefaultProcessInfo())
+	}
+	if c.Build {
+		l.SetBuildInfo(ReadBuildInfo())
+	}
+	if c.Kubernetes {
+		l.SetKubernetesInfo(DefaultKubernetesInfo())
+	}
+	l.SetSnapshots(c.Snapshots)
+	l.SetBaggageKeys(c.Baggage...)
+	l.SetRedactKeys(c.Redact...)
+	l.SetTrustedProxies(c.TrustedProxies...)
+
+	var sinks []func(Thread) error
+	for i, s := range c.Sinks {
+		sink, closer, err := s.open()
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("logger: sinks[%d]: %v", i, err)
+		}
+		sinks = append(sinks, sink)
+		if closer != nil {
+			l.sinks = append(l.sinks, closer)
+		}
+	}
+	if len(sinks) > 0 {
+		l.OnLog = Tee(sinks...)
+	}
+	if s := c.Sampling; s != nil {
+		l.OnLog = Sampled(l.OnLog, SampleOptions{Every: s.Every, KeepTags: s.KeepTags})
+	}
+
+	if a := c.Async; a != nil {
+		l.SetAsync(AsyncOptions{
+			QueueSize:   a.QueueSize,
+			Policy:      dropPolicies[strings.ToLower(a.Policy)],
+			SampleEvery: a.SampleEvery,
+			KeepTags:    a.KeepTags,
+		})
+	}
+
+	if c.WAL != "" {
+		if err := l.SetWAL(c.WAL); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("logger: wal: %v", err)
+		}
+	}
+
+	if c.DeadLetter != "" {
+		if err := l.SetDeadLetter(c.DeadLetter); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("logger: deadLetter: %v", err)
+		}
+	}
+
+	return l, nil
+}
+
+/*
+open returns the hook that writes to s and, unless s writes
+to stderr or stdout, what closes the file or socket it
+writes to.
+*/
+func (s SinkConfig) open() (func(Thread) error, io.Closer, error) {
+
+	var w io.Writer
+	var closer io.Closer
+	switch s.Type {
+	case "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	case "file":
+		f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = f, f
+	case "unix", "unixgram":
+		sock := &Socket{Network: s.Type, Addr: s.Path}
+		w, closer = sock, sock
+	}
+
+	format, _ := formatter(s.Format, s.Color)
+	sink := writeTo(w, format)
+	if s.Level == "" {
+		return sink, closer, nil
+	}
+
+	min, _ := ParseLevel(s.Level)
+	return func(t Thread) error {
+		var entries []*Entry
+		for _, e := range t.Entries {
+			if e.Level >= min {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		t.Entries = entries
+		return sink(t)
+	}, closer, nil
+}
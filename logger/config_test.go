@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigCloseSinks(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "threads.log")
+	l, err := Config{
+		Sinks: []SinkConfig{{Type: "file", Path: path, Format: "record"}},
+		Async: &AsyncConfig{QueueSize: 16},
+	}.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := l.sinks[0].(*os.File)
+
+	l.Once("hello")
+	l.Close()
+
+	// The queued thread is written before the file closes.
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) == 0 {
+		t.Fatal("thread wasn't written before the sink was closed")
+	}
+	if err := f.Close(); err == nil {
+		t.Fatal("sink's file was still open after Close")
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logger.yaml")
+	if err := ioutil.WriteFile(path, []byte("level: info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromConfig(path); err == nil || !strings.Contains(err.Error(), "yamlconfig") {
+		t.Fatalf("got error %v reading YAML with no format registered, want one naming yamlconfig", err)
+	}
+
+	path = filepath.Join(dir, "logger.conf")
+	if err := ioutil.WriteFile(path, []byte("level=error"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RegisterConfigFormat(".CONF", func(data []byte, c *Config) error {
+		c.Level = strings.TrimPrefix(string(data), "level=")
+		return nil
+	})
+	defer RegisterConfigFormat(".conf", nil)
+	l, err := FromConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if lv := l.settings().level; lv != LevelError {
+		t.Fatalf("level is %s, want Error", lv)
+	}
+}
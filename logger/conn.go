@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Conn is a net.Conn that counts the bytes read from and
+written to it and logs the connection as a thread of
+KindConnection when it is closed, for servers of protocols
+other than HTTP such as SMTP. Protocol-specific data can be
+attached to the thread with Logger.ThreadData and entries
+logged to it with Id.
+*/
+type Conn struct {
+
+	// These are first so they're 64-bit aligned for the
+	// atomic operations on 32-bit platforms.
+	in  uint64
+	out uint64
+
+	net.Conn
+
+	// Id is the id of the connection's thread.
+	Id string
+
+	logger   *Logger
+	protocol string
+	once     sync.Once
+}
+
+/*
+Conn wraps c in a Conn whose thread's route is protocol,
+e.g. "smtp", and whose IP is c's remote address.
+*/
+func (l *Logger) Conn(c net.Conn, protocol string) *Conn {
+	conn := &Conn{
+		Conn:     c,
+		Id:       l.NewId(),
+		logger:   l,
+		protocol: protocol,
+	}
+	l.Begin(conn.Id)
+	return conn
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.in, uint64(n))
+	return n, err
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.out, uint64(n))
+	return n, err
+}
+
+func (c *Conn) BytesIn() uint64 {
+	return atomic.LoadUint64(&c.in)
+}
+
+func (c *Conn) BytesOut() uint64 {
+	return atomic.LoadUint64(&c.out)
+}
+
+/*
+Close closes the connection and ends its thread with the
+byte counts attached as "bytes_in" and "bytes_out". An error
+closing the connection is logged to the thread too. Only
+the first call ends the thread.
+*/
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		if err != nil {
+			c.logger.Error(c.Id, "closing connection").Err(err)
+		}
+		c.logger.ThreadData(c.Id, "bytes_in", c.BytesIn())
+		c.logger.ThreadData(c.Id, "bytes_out", c.BytesOut())
+		var remote string
+		if addr := c.Conn.RemoteAddr(); addr != nil {
+			remote = addr.String()
+		}
+		c.logger.end(KindConnection, c.Id, remote, "", c.protocol, 0)
+	})
+	return err
+}
+
+/*
+Listener wraps ln so the connections it accepts are Conns
+for protocol. Their threads end when they are closed.
+*/
+func (l *Logger) Listener(ln net.Listener, protocol string) net.Listener {
+	return &listener{Listener: ln, logger: l, protocol: protocol}
+}
+
+type listener struct {
+	net.Listener
+	logger   *Logger
+	protocol string
+}
+
+func (ln *listener) Accept() (net.Conn, error) {
+	c, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return ln.logger.Conn(c, ln.protocol), nil
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+Job is run by a scheduler. It matches robfig/cron's Job so
+the result of ScheduledJob can be passed to Cron.Schedule.
+*/
+type Job interface {
+	Run()
+}
+
+/*
+Schedule gives the next time a job should run after t. It
+matches robfig/cron's Schedule, such as the result of
+cron.ParseStandard.
+*/
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+/*
+ScheduledJob returns a Job that runs fn in a session of its
+own named name each time it is run by the scheduler, with
+spec, the schedule's expression, attached as "schedule".
+How late the run started compared with when sched said it
+should is attached as "drift". If fn returns an error the
+session fails, otherwise it succeeds; a panic is logged and
+fails the session before fn panics again.
+
+A run due while the previous one is still going is skipped,
+as with cron.SkipIfStillRunning. The skip is logged to the
+running session and the number of runs skipped is attached
+to the next one as "skipped".
+
+	sched, _ := cron.ParseStandard(spec)
+	c.Schedule(sched, log.ScheduledJob("reindex", spec, sched, reindex))
+*/
+func (l *Logger) ScheduledJob(name, spec string, sched Schedule, fn func(s *Session) error) Job {
+	return &scheduledJob{
+		logger: l,
+		name:   name,
+		spec:   spec,
+		sched:  sched,
+		fn:     fn,
+		due:    sched.Next(time.Now()),
+	}
+}
+
+type scheduledJob struct {
+	logger *Logger
+	name   string
+	spec   string
+	sched  Schedule
+	fn     func(s *Session) error
+
+	mu      sync.Mutex
+	due     time.Time
+	running *Session
+	skipped int
+}
+
+func (j *scheduledJob) Run() {
+
+	start := time.Now()
+
+	j.mu.Lock()
+	due := j.due
+	j.due = j.sched.Next(start)
+	if running := j.running; running != nil {
+		j.skipped++
+		j.mu.Unlock()
+		running.Info("skipped a run while still running").
+			Data("due", due)
+		return
+	}
+	s := j.logger.Sess(j.name)
+	j.running = s
+	skipped := j.skipped
+	j.skipped = 0
+	j.mu.Unlock()
+
+	s.ThreadData("schedule", j.spec)
+	drift := start.Sub(due)
+	if drift < 0 {
+		drift = 0
+	}
+	s.ThreadData("drift", drift)
+	if skipped > 0 {
+		s.ThreadData("skipped", skipped)
+	}
+
+	defer func() {
+		p := recover()
+		if p != nil {
+			s.Fail(fmt.Errorf("panic: %v", p))
+		}
+		j.mu.Lock()
+		j.running = nil
+		j.mu.Unlock()
+		s.End()
+		if p != nil {
+			panic(p)
+		}
+	}()
+
+	if err := j.fn(s); err != nil {
+		s.Fail(err)
+		return
+	}
+	s.Succeed()
+}
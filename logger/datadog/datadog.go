@@ -0,0 +1,359 @@
+/*
+Package datadog sends ended threads to the Datadog logs
+intake API in batches, one log per thread, correlated with
+Datadog traces when the thread carries a trace context.
+*/
+package datadog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/httpclient"
+)
+
+/*
+DefaultURL is the intake for the US1 site.
+*/
+const DefaultURL = "https://http-intake.logs.datadoghq.com/api/v2/logs"
+
+// Limits of a single request to the intake API.
+const (
+	maxLogs  = 1000
+	maxBytes = 5 << 20
+)
+
+/*
+Sink accumulates logs and sends them whenever a request's
+worth has accumulated, Flush is called or, once started,
+periodically.
+*/
+type Sink struct {
+
+	// URL defaults to DefaultURL. Other sites have their
+	// own, e.g. https://http-intake.logs.datadoghq.eu/api/v2/logs.
+	URL string
+
+	APIKey string
+
+	// Service is reported as service and Source as
+	// ddsource, which defaults to go.
+	Service string
+	Source  string
+
+	// Tags are sent as ddtags, e.g. "env:prod".
+	Tags []string
+
+	// Hostname defaults to os.Hostname.
+	Hostname string
+
+	// Client defaults to http.DefaultClient, or a client
+	// using TLS if it is set.
+	Client *http.Client
+
+	// TLS configures how the server is verified, and any
+	// client certificate, when Client isn't set.
+	TLS *logger.TLSOptions
+
+	// Token is sent as a bearer token if it is set, e.g. to
+	// a proxy in front of the intake.
+	Token string
+
+	// Compression is the content encoding of requests. It
+	// defaults to gzip and the intake also accepts deflate.
+	// Set it to none to send requests as they are.
+	Compression string
+
+	// OnError is called when a periodic flush fails.
+	OnError func(error)
+
+	clients httpclient.Cache
+
+	mu   sync.Mutex
+	logs []json.RawMessage
+	size int
+	stop chan struct{}
+}
+
+type log struct {
+	Source   string     `json:"ddsource"`
+	Service  string     `json:"service,omitempty"`
+	Hostname string     `json:"hostname,omitempty"`
+	Tags     string     `json:"ddtags,omitempty"`
+	Status   string     `json:"status"`
+	Message  string     `json:"message"`
+	Date     int64      `json:"date"`
+	Thread   thread     `json:"thread"`
+	Http     *httpAttrs `json:"http,omitempty"`
+	Duration int64      `json:"duration,omitempty"`
+	Trace    string     `json:"dd.trace_id,omitempty"`
+	Span     string     `json:"dd.span_id,omitempty"`
+	Entries  []entry    `json:"entries,omitempty"`
+}
+
+type thread struct {
+	Id            string            `json:"id"`
+	Kind          string            `json:"kind"`
+	CorrelationId string            `json:"correlation_id,omitempty"`
+	Outcome       string            `json:"outcome,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Data          map[string]string `json:"data,omitempty"`
+}
+
+type httpAttrs struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	ClientIp   string `json:"client_ip,omitempty"`
+}
+
+type entry struct {
+	Level    string            `json:"level"`
+	Message  string            `json:"message"`
+	Function string            `json:"function,omitempty"`
+	File     string            `json:"file,omitempty"`
+	Line     int               `json:"line,omitempty"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+/*
+Hook adds t to the next request. It has the signature of
+Logger.OnLog so it can be assigned to it.
+*/
+func (s *Sink) Hook(t logger.Thread) error {
+
+	b, err := json.Marshal(s.log(t))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if len(s.logs) == maxLogs || s.size+len(b) > maxBytes {
+		if err := s.flush(); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.logs = append(s.logs, b)
+	s.size += len(b) + 1
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Sink) log(t logger.Thread) log {
+
+	source := s.Source
+	if source == "" {
+		source = "go"
+	}
+	host := s.Hostname
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	l := log{
+		Source:   source,
+		Service:  s.Service,
+		Hostname: host,
+		Tags:     strings.Join(s.Tags, ","),
+		Status:   "info",
+		Date:     t.Date.UnixNano() / int64(time.Millisecond),
+		Thread: thread{
+			Id:            t.Id,
+			Kind:          t.Kind.String(),
+			CorrelationId: t.CorrelationId,
+			Outcome:       string(t.Outcome),
+			Tags:          t.Tags,
+		},
+	}
+
+	if len(t.KeyVals) > 0 {
+		l.Thread.Data = map[string]string{}
+		for _, kv := range t.KeyVals {
+			l.Thread.Data[kv.Key] = fmt.Sprint(kv.Val)
+		}
+	}
+
+	if t.Kind == logger.KindRequest {
+		l.Http = &httpAttrs{
+			Method:     t.Method,
+			URL:        t.Route,
+			StatusCode: t.Status,
+			ClientIp:   t.Ip,
+		}
+		l.Duration = t.Duration
+		l.Message = fmt.Sprintf("%s %s %d", t.Method, t.Route, t.Status)
+	} else {
+		l.Message = t.Route
+	}
+
+	for _, e := range t.Entries {
+		en := entry{
+			Level:    e.Level.String(),
+			Message:  e.Message,
+			Function: e.Function,
+			File:     e.File,
+			Line:     e.Line,
+		}
+		if len(e.KeyVals) > 0 {
+			en.Data = map[string]string{}
+			for _, kv := range e.KeyVals {
+				en.Data[kv.Key] = fmt.Sprint(kv.Val)
+			}
+		}
+		l.Entries = append(l.Entries, en)
+
+		// The log takes the status and message of its
+		// first error.
+		if e.Level == logger.LevelError && l.Status != "error" {
+			l.Status = "error"
+			l.Message = e.Message
+		}
+	}
+
+	if tc, ok := t.Meta[logger.MetaTrace].(logger.TraceContext); ok {
+		l.Trace = ddId(tc.TraceId)
+		l.Span = ddId(tc.SpanId)
+	}
+
+	return l
+}
+
+/*
+ddId converts a hex trace or span id to the decimal form
+Datadog correlates on, which is the low 64 bits.
+*/
+func ddId(hex string) string {
+	if len(hex) > 16 {
+		hex = hex[len(hex)-16:]
+	}
+	id, err := strconv.ParseUint(hex, 16, 64)
+	if err != nil || id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+/*
+Flush sends the accumulated logs, unless there are none.
+They are kept for the next attempt if sending fails.
+*/
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *Sink) flush() error {
+
+	if len(s.logs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, l := range s.logs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(l)
+	}
+	buf.WriteByte(']')
+	encoding := s.Compression
+	switch encoding {
+	case "":
+		encoding = "gzip"
+	case "none":
+		encoding = ""
+	}
+	body, err := logger.Compress(encoding, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("datadog: %v", strings.TrimPrefix(err.Error(), "logger: "))
+	}
+
+	url := s.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	req.Header.Set("DD-API-KEY", s.APIKey)
+
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client, err := s.clients.Get(s.Client, s.TLS)
+	if err != nil {
+		return fmt.Errorf("datadog: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("datadog: intake responded %s", resp.Status)
+	}
+
+	s.logs = nil
+	s.size = 0
+	return nil
+}
+
+/*
+Start flushes every interval until Close is called.
+*/
+func (s *Sink) Start(every time.Duration) {
+
+	stop := make(chan struct{})
+	s.mu.Lock()
+	old := s.stop
+	s.stop = stop
+	s.mu.Unlock()
+	if old != nil {
+		close(old)
+	}
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil && s.OnError != nil {
+					s.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+/*
+Close stops periodic flushing and flushes what remains.
+*/
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return s.Flush()
+}
@@ -0,0 +1,194 @@
+package datadog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+intake is a logs intake that fails the first fail requests
+and decodes the logs of the rest, one batch per request.
+*/
+type intake struct {
+	mu       sync.Mutex
+	fail     int
+	encoding []string
+	batches  [][]log
+}
+
+func (in *intake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if r.Header.Get("DD-API-KEY") != "key" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if in.fail > 0 {
+		in.fail--
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	enc := r.Header.Get("Content-Encoding")
+	in.encoding = append(in.encoding, enc)
+	var body io.Reader = r.Body
+	if enc == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	var logs []log
+	if err := json.NewDecoder(body).Decode(&logs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.batches = append(in.batches, logs)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (in *intake) sizes() []int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	var n []int
+	for _, b := range in.batches {
+		n = append(n, len(b))
+	}
+	return n
+}
+
+func TestSinkLog(t *testing.T) {
+
+	in := &intake{}
+	srv := httptest.NewServer(in)
+	defer srv.Close()
+	s := &Sink{URL: srv.URL, APIKey: "key", Service: "api", Hostname: "web-1", Tags: []string{"env:prod", "team:a"}}
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	l := &logger.Logger{}
+	l.SetClock(func() time.Time { return date })
+	id := l.NewId()
+	l.Info(id, "handled").Data("user", 7)
+	l.Error(id, "failed")
+	l.ThreadData(id, "tenant", "acme")
+	l.SetMeta(id, logger.MetaTrace, logger.TraceContext{
+		TraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanId:  "00f067aa0ba902b7",
+	})
+	th, _ := l.End(id, "10.0.0.1", "GET", "/users/:id", int64(time.Millisecond))
+
+	if err := s.Hook(th); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Hook(logger.Thread{Kind: logger.KindSession, Id: "s", Route: "startup"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(in.batches) != 1 || len(in.batches[0]) != 2 || in.encoding[0] != "gzip" {
+		t.Fatalf("intake received %d batches encoded %q, want 1 of 2 logs gzipped", len(in.batches), in.encoding)
+	}
+	got := in.batches[0][0]
+	want := log{
+		Source:   "go",
+		Service:  "api",
+		Hostname: "web-1",
+		Tags:     "env:prod,team:a",
+		Status:   "error",
+		Message:  "Failed.",
+		Date:     date.UnixNano() / int64(time.Millisecond),
+		Thread:   thread{Id: id, Kind: "request", Data: map[string]string{"tenant": "acme"}},
+		Http:     &httpAttrs{Method: "GET", URL: "/users/:id", StatusCode: 200, ClientIp: "10.0.0.1"},
+		Duration: int64(time.Millisecond),
+		Trace:    "11803532876627986230",
+		Span:     "67667974448284343",
+		Entries: []entry{
+			{Level: "Info", Message: "Handled.", Function: th.Entries[0].Function, File: th.Entries[0].File,
+				Line: th.Entries[0].Line, Data: map[string]string{"user": "7"}},
+			{Level: "Error", Message: "Failed.", Function: th.Entries[1].Function, File: th.Entries[1].File,
+				Line: th.Entries[1].Line},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("intake received\n%+v\nwant\n%+v", got, want)
+	}
+	if got := in.batches[0][1]; got.Status != "info" || got.Message != "startup" || got.Http != nil {
+		t.Fatalf("intake received %+v for a session", got)
+	}
+}
+
+func TestSinkBatches(t *testing.T) {
+
+	in := &intake{}
+	srv := httptest.NewServer(in)
+	defer srv.Close()
+	s := &Sink{URL: srv.URL, APIKey: "key", Hostname: "web-1", Compression: "none"}
+
+	for i := 0; i < maxLogs+1; i++ {
+		if err := s.Hook(logger.Thread{Kind: logger.KindSession}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := in.sizes(); !reflect.DeepEqual(got, []int{maxLogs}) {
+		t.Fatalf("intake received batches of %v logs, want [%d]", got, maxLogs)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Five logs of a little over a megabyte each fill a
+	// request, so the fifth is sent with the next.
+	big := logger.Thread{Kind: logger.KindSession, Route: strings.Repeat("x", maxBytes/5)}
+	for i := 0; i < 5; i++ {
+		if err := s.Hook(big); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := in.sizes(); !reflect.DeepEqual(got, []int{maxLogs, 1, 4, 1}) {
+		t.Fatalf("intake received batches of %v logs, want [%d 1 4 1]", got, maxLogs)
+	}
+	for _, enc := range in.encoding {
+		if enc != "" {
+			t.Fatalf("requests were encoded %q with Compression none", enc)
+		}
+	}
+}
+
+func TestSinkRetry(t *testing.T) {
+
+	in := &intake{fail: 1}
+	srv := httptest.NewServer(in)
+	defer srv.Close()
+	s := &Sink{URL: srv.URL, APIKey: "key"}
+
+	if err := s.Hook(logger.Thread{Kind: logger.KindSession, Id: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err == nil {
+		t.Fatal("Flush succeeded with the intake unavailable")
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(in.batches) != 1 || len(in.batches[0]) != 1 || in.batches[0][0].Thread.Id != "a" {
+		t.Fatalf("intake received %+v, want the log kept from the failed request", in.batches)
+	}
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+/*
+deadLetterRecord is a single line of the dead-letter file.
+*/
+type deadLetterRecord struct {
+	Time   time.Time `json:"time"`
+	Hook   string    `json:"hook"`
+	Error  string    `json:"error"`
+	Thread Thread    `json:"thread"`
+}
+
+/*
+SetDeadLetter makes the logger append threads that OnLog or
+OnError failed to accept after every attempt to the file at
+path, one JSON object per line giving the hook, its error
+and the thread, so they can be replayed once the sink has
+recovered. Data values are written as strings. They are
+still reported to OnInternalError. An empty path disables it.
+*/
+func (l *Logger) SetDeadLetter(path string) error {
+
+	var f *os.File
+	if path != "" {
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.deadMu.Lock()
+	old := l.deadLetter
+	l.deadLetter = f
+	l.deadMu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (l *Logger) writeDeadLetter(e *DeliveryError) {
+
+	l.deadMu.Lock()
+	defer l.deadMu.Unlock()
+	if l.deadLetter == nil {
+		return
+	}
+
+	b, err := json.Marshal(deadLetterRecord{
+		Time:   l.now(),
+		Hook:   e.Hook,
+		Error:  e.Err.Error(),
+		Thread: printable(e.Thread),
+	})
+	if err != nil {
+		l.internalError(fmt.Errorf("logger: encoding dead letter: %s", err))
+		return
+	}
+	b = append(b, '\n')
+	if _, err := l.deadLetter.Write(b); err != nil {
+		l.internalError(fmt.Errorf("logger: writing dead letter: %s", err))
+	}
+}
+
+/*
+printable returns a copy of t whose data and meta values are
+strings so it can always be encoded.
+*/
+func printable(t Thread) Thread {
+	t.KeyVals = printableKVs(t.KeyVals)
+	if t.Meta != nil {
+		m := make(map[MetaKey]interface{}, len(t.Meta))
+		for k, v := range t.Meta {
+			m[k] = fmt.Sprint(v)
+		}
+		t.Meta = m
+	}
+	ee := make([]*Entry, len(t.Entries))
+	for i, e := range t.Entries {
+		c := *e
+		c.KeyVals = printableKVs(e.KeyVals)
+		ee[i] = &c
+	}
+	t.Entries = ee
+	return t
+}
+
+func printableKVs(kvs []kv) []kv {
+	if kvs == nil {
+		return nil
+	}
+	out := make([]kv, len(kvs))
+	for i, kv := range kvs {
+		out[i] = kv
+		out[i].Val = fmt.Sprint(kv.Val)
+	}
+	return out
+}
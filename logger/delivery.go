@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+DeliveryError is passed to OnInternalError when OnLog or
+OnError fails to accept a thread after every attempt, so the
+thread can be saved elsewhere rather than lost. If pooling is
+enabled its entries must not be retained after returning.
+*/
+type DeliveryError struct {
+	Hook     string
+	Thread   Thread
+	Attempts int
+	Err      error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("logger: %s failed for thread %s after %d attempts: %s",
+		e.Hook, e.Thread.Id, e.Attempts, e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+/*
+SetDeliveryAttempts sets how many times a thread is passed
+to OnLog or OnError before giving up, waiting between them
+as set by SetRetryBackoff. The default is 1, i.e. failures
+aren't retried. Retries hold up End unless the logger is
+asynchronous. See SetAsync.
+*/
+func (l *Logger) SetDeliveryAttempts(n int) {
+	l.update("deliveries", func(s *settings) { s.deliveries = n })
+}
+
+func (l *Logger) deliveryAttempts() int {
+	if n := l.settings().deliveries; n > 1 {
+		return n
+	}
+	return 1
+}
+
+func (l *Logger) deliver(hook string, fn func(Thread) error, t Thread) {
+
+	attempts := l.deliveryAttempts()
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(t); err == nil {
+			return
+		}
+		if attempt < attempts {
+			time.Sleep(l.retryBackoff(attempt))
+		}
+	}
+
+	e := &DeliveryError{
+		Hook:     hook,
+		Thread:   t,
+		Attempts: attempts,
+		Err:      err,
+	}
+	l.writeDeadLetter(e)
+	l.internalError(e)
+}
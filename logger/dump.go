@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+Open returns a snapshot of every thread that hasn't ended,
+marked Unterminated. Their kind isn't known until they end
+so they are given the session kind.
+*/
+func (l *Logger) Open() []Thread {
+
+	var threads []Thread
+	now := l.now()
+
+	l.shared().threads.each(func(id string, b *buffer) {
+		b.mu.Lock()
+		t := l.snapshotThread(id, b, now)
+		b.mu.Unlock()
+		threads = append(threads, t)
+	})
+
+	// Ids are numerical when generated by NewId so sort
+	// them that way, falling back to lexical order.
+	sort.Slice(threads, func(i, j int) bool {
+		a, errA := strconv.ParseInt(threads[i].Id, 10, 64)
+		b, errB := strconv.ParseInt(threads[j].Id, 10, 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return threads[i].Id < threads[j].Id
+	})
+
+	return threads
+}
+
+/*
+snapshotThread returns the open thread id, whose buffer b must be
+locked, as it stands at now. Entries are copied so the
+snapshot can be formatted while the thread carries on.
+*/
+func (l *Logger) snapshotThread(id string, b *buffer, now time.Time) Thread {
+
+	ee := make([]*Entry, len(b.entries))
+	for i, e := range b.entries {
+		ee[i] = e.copy()
+	}
+	m := b.meta
+	phases := append([]Phase(nil), m.phases...)
+
+	// Show how long the current phase has taken so far.
+	if m.phaseOpen {
+		p := &phases[len(phases)-1]
+		p.Duration = now.Sub(p.Start)
+	}
+
+	return Thread{
+		Date:         now,
+		Id:           id,
+		Kind:         KindSession,
+		Status:       m.status,
+		Redirect:     m.redirect,
+		Meta:         copyMeta(m.values),
+		KeyVals:      append([]kv(nil), b.data...),
+		Tags:         append([]string(nil), b.tags...),
+		Phases:       phases,
+		Entries:      ee,
+		Unterminated: true,
+		catalog:      l.settings().catalog,
+
+		CorrelationId: m.correlation,
+		Outcome:       m.outcome,
+	}
+}
+
+/*
+copy returns a copy of e, whose buffer must be locked, that
+isn't part of its thread so it can't be modified through it.
+*/
+func (e *Entry) copy() *Entry {
+	c := *e
+	c.buf = nil
+	c.KeyVals = append([]kv(nil), e.KeyVals...)
+	return &c
+}
+
+/*
+DumpOpen writes every thread that hasn't ended to w using
+FormatPretty. Fatal calls it with os.Stderr before exiting.
+*/
+func (l *Logger) DumpOpen(w io.Writer) error {
+	for _, t := range l.Open() {
+		if _, err := io.WriteString(w, t.FormatPretty()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+DumpOnPanic writes every open thread to w if the goroutine
+is panicking and then continues panicking. It must be called
+directly by defer, e.g.
+
+	defer l.DumpOnPanic(os.Stderr)
+*/
+func (l *Logger) DumpOnPanic(w io.Writer) {
+	if r := recover(); r != nil {
+		l.DumpOpen(w)
+		panic(r)
+	}
+}
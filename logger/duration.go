@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Precision is how FormatPretty, FormatTerse and FormatWaterfall
+render thread durations. FormatRecord always writes whole
+milliseconds so stored logs keep a stable format.
+*/
+type Precision int
+
+const (
+	// PrecisionMillisecond renders whole milliseconds, e.g.
+	// 12ms. It is the default.
+	PrecisionMillisecond Precision = iota
+
+	// PrecisionMicrosecond renders whole microseconds, e.g.
+	// 840µs or 12345µs.
+	PrecisionMicrosecond
+
+	// PrecisionAdaptive renders three significant figures
+	// in the largest unit the duration has one of, e.g.
+	// 840µs, 12.3ms or 1.25s.
+	PrecisionAdaptive
+)
+
+var precisionNames = map[Precision]string{
+	PrecisionMillisecond: "ms",
+	PrecisionMicrosecond: "us",
+	PrecisionAdaptive:    "adaptive",
+}
+
+func (p Precision) String() string {
+	if name, ok := precisionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Precision(%d)", int(p))
+}
+
+/*
+ParsePrecision returns the Precision named by s, ignoring
+case: ms, us (or µs) or adaptive.
+*/
+func ParsePrecision(s string) (Precision, error) {
+	if s == "µs" {
+		return PrecisionMicrosecond, nil
+	}
+	for p, name := range precisionNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("logger: unknown precision %q", s)
+}
+
+/*
+SetDurationPrecision sets how durations are rendered by the
+formatters that honour Precision. Endpoints that finish in
+well under a millisecond all read 0ms by default.
+*/
+func (l *Logger) SetDurationPrecision(p Precision) {
+	l.update("precision", func(s *settings) { s.precision = p })
+}
+
+/*
+format renders the duration d, in nanoseconds, at p.
+*/
+func (p Precision) format(d int64) string {
+	switch p {
+	case PrecisionMicrosecond:
+		return fmt.Sprintf("%dµs", d/int64(time.Microsecond))
+	case PrecisionAdaptive:
+		return adaptiveDuration(time.Duration(d))
+	}
+	return fmt.Sprintf("%dms", d/int64(time.Millisecond))
+}
+
+func adaptiveDuration(d time.Duration) string {
+
+	unit, suffix := time.Second, "s"
+	switch {
+	case d < time.Microsecond:
+		return fmt.Sprintf("%dns", d)
+	case d < time.Millisecond:
+		unit, suffix = time.Microsecond, "µs"
+	case d < time.Second:
+		unit, suffix = time.Millisecond, "ms"
+	}
+
+	v := float64(d) / float64(unit)
+	decimals := 0
+	if v < 10 {
+		decimals = 2
+	} else if v < 100 {
+		decimals = 1
+	}
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if decimals > 0 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s + suffix
+}
+
+/*
+duration renders t's duration at the precision of the
+logger that ended it.
+*/
+func (t Thread) duration() string {
+	return t.precision.format(t.Duration)
+}
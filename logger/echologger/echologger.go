@@ -0,0 +1,34 @@
+/*
+Package echologger records Echo requests as request threads
+of a logger.Logger. It is a module of its own so the logger
+doesn't depend on Echo.
+*/
+package echologger
+
+import (
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/labstack/echo/v4"
+)
+
+/*
+Middleware opens a request thread for every request and
+ends it once the handler has run, as logger.Middleware does
+for net/http. The thread's route is the pattern the request
+matched, e.g. /users/:id. An error returned by the handler
+is passed to Echo's error handler first so the thread has
+the status it responds with. Handlers find the thread's id
+with logger.RequestId(c.Request().Context()).
+*/
+func Middleware(l *logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rs := l.BeginRequest(c.Request())
+			c.SetRequest(c.Request().WithContext(rs.Context))
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			rs.End(c.Path(), c.Response().Status)
+			return nil
+		}
+	}
+}
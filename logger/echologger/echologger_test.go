@@ -0,0 +1,42 @@
+package echologger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	threads := make(chan logger.Thread, 1)
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		threads <- t
+		return nil
+	}}
+
+	e := echo.New()
+	e.Use(Middleware(l))
+	e.GET("/users/:id", func(c echo.Context) error {
+		l.Info(logger.RequestId(c.Request().Context()), "missing")
+		return echo.NewHTTPError(http.StatusNotFound)
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/users/1", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("responded %d, want 404", w.Code)
+	}
+	thread := <-threads
+	if thread.Route != "/users/:id" {
+		t.Errorf("route is %q, want /users/:id", thread.Route)
+	}
+	if thread.Status != http.StatusNotFound {
+		t.Errorf("status is %d, want 404", thread.Status)
+	}
+	if len(thread.Entries) != 1 {
+		t.Errorf("thread has %d entries, want 1", len(thread.Entries))
+	}
+}
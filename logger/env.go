@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+FromEnv returns a Logger configured from these environment
+variables, any of which may be unset:
+
+	LOG_LEVEL      minimum level: debug, info or error
+	LOG_COMPONENTS per-component levels, e.g. db=debug,http=info
+	LOG_DEBUG      enable debug entries (true/false)
+	LOG_RUNTIME    record call sites (true/false)
+	LOG_NORMALISE  capitalise and punctuate messages (true/false)
+	LOG_FORMAT     pretty, terse, record, waterfall, msgpack, json,
+	               jsonEntries, googleCloud, googleCloudGrouped,
+	               lambda or none
+	LOG_COLOR      colour levels in pretty and terse output (true/false)
+	LOG_PROCESS    stamp threads with host, PID and instance (true/false)
+	LOG_BUILD      stamp threads with version and revision (true/false)
+	LOG_KUBERNETES stamp threads with pod, namespace and node (true/false)
+	LOG_WAL        path of a write-ahead log; see SetWAL
+	LOG_DEADLETTER path for threads the sinks fail to accept; see SetDeadLetter
+
+Threads are written to stderr in LOG_FORMAT unless it is
+none, in which case OnLog is left for the caller to set. If
+LOG_FORMAT is unset threads are written in pretty format,
+or to stdout as JSON if InContainer reports true so that
+container log drivers can read them. On Cloud Run, App
+Engine and AWS Lambda the JSON is in the platform's own
+format. An invalid value is
+reported as an error naming the variable.
+*/
+func FromEnv() (*Logger, error) {
+
+	c := Config{Level: os.Getenv("LOG_LEVEL")}
+	if c.Level != "" {
+		if _, err := ParseLevel(c.Level); err != nil {
+			return nil, fmt.Errorf("logger: LOG_LEVEL: %v", err)
+		}
+	}
+
+	if s := os.Getenv("LOG_COMPONENTS"); s != "" {
+		levels, err := ParseComponentLevels(s)
+		if err != nil {
+			return nil, fmt.Errorf("logger: LOG_COMPONENTS: %v", strings.TrimPrefix(err.Error(), "logger: "))
+		}
+		c.Components = map[string]string{}
+		for name, lv := range levels {
+			c.Components[name] = lv.String()
+		}
+	}
+
+	sink := SinkConfig{
+		Type:   "stderr",
+		Format: os.Getenv("LOG_FORMAT"),
+	}
+	if sink.Format == "" {
+		if f := platformFormat(); f != "" {
+			sink = SinkConfig{Type: "stdout", Format: f}
+		} else if InContainer() {
+			sink = SinkConfig{Type: "stdout", Format: "json"}
+		}
+	}
+	if _, err := formatter(sink.Format, false); err != nil {
+		return nil, fmt.Errorf("logger: LOG_FORMAT: %v", err)
+	}
+	if !strings.EqualFold(sink.Format, "none") {
+		c.Sinks = []SinkConfig{sink}
+	}
+
+	bools := []struct {
+		name string
+		set  func(bool)
+	}{
+		{"LOG_DEBUG", func(b bool) { c.Debug = b }},
+		{"LOG_RUNTIME", func(b bool) { c.Runtime = b }},
+		{"LOG_NORMALISE", func(b bool) { c.Normalise = &b }},
+		{"LOG_PROCESS", func(b bool) { c.Process = b }},
+		{"LOG_BUILD", func(b bool) { c.Build = b }},
+		{"LOG_KUBERNETES", func(b bool) { c.Kubernetes = b }},
+		{"LOG_COLOR", func(b bool) {
+			for i := range c.Sinks {
+				c.Sinks[i].Color = b
+			}
+		}},
+	}
+	for _, b := range bools {
+		if err := envBool(b.name, b.set); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := c.New()
+	if err != nil {
+		return nil, err
+	}
+	if path := os.Getenv("LOG_WAL"); path != "" {
+		if err := l.SetWAL(path); err != nil {
+			return nil, fmt.Errorf("logger: LOG_WAL: %v", err)
+		}
+	}
+	if path := os.Getenv("LOG_DEADLETTER"); path != "" {
+		if err := l.SetDeadLetter(path); err != nil {
+			return nil, fmt.Errorf("logger: LOG_DEADLETTER: %v", err)
+		}
+	}
+	return l, nil
+}
+
+func envBool(name string, set func(bool)) error {
+	s := os.Getenv(name)
+	if s == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("logger: %s: %q is not a boolean", name, s)
+	}
+	set(b)
+	return nil
+}
+
+/*
+formatter returns the function that renders threads in the
+named format, or nil for none.
+*/
+func formatter(name string, colour bool) (func(Thread) string, error) {
+	switch strings.ToLower(name) {
+	case "", "pretty":
+		return func(t Thread) string { return t.formatPretty(colour) }, nil
+	case "terse":
+		return func(t Thread) string { return t.formatTerse(colour) }, nil
+	case "record":
+		return Thread.FormatRecord, nil
+	case "waterfall":
+		return Thread.FormatWaterfall, nil
+	case "msgpack":
+		return func(t Thread) string { return string(t.MarshalMsgpack()) }, nil
+	case "json":
+		return Thread.FormatJSON, nil
+	case "jsonentries":
+		return Thread.FormatJSONEntries, nil
+	case "googlecloud":
+		return DefaultGoogleCloud().Format, nil
+	case "googlecloudgrouped":
+		g := DefaultGoogleCloud()
+		g.Grouped = true
+		return g.Format, nil
+	case "lambda":
+		return Thread.FormatLambda, nil
+	case "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", name)
+}
+
+func writeTo(w io.Writer, format func(Thread) string) func(Thread) error {
+	return func(t Thread) error {
+		_, err := io.WriteString(w, format(t))
+		return err
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"fmt"
+)
+
+/*
+Coder is implemented by application errors that carry a
+code for grouping, such as "payment_declined".
+*/
+type Coder interface {
+	Code() string
+}
+
+/*
+Category groups errors by how they should be handled, e.g.
+so alerts never page for user errors.
+*/
+type Category string
+
+const (
+	CategoryTransient Category = "transient"
+	CategoryUser      Category = "user"
+	CategoryInternal  Category = "internal"
+)
+
+/*
+Classifier returns the category of err, or an empty string
+if it doesn't recognise it.
+*/
+type Classifier func(err error) Category
+
+/*
+AddClassifier registers c to categorise errors attached with
+Entry.Err. Classifiers are consulted in the order they were
+added and the first category returned is recorded.
+*/
+func (l *Logger) AddClassifier(c Classifier) {
+	l.update("", func(s *settings) {
+		s.classifiers = append(s.classifiers[:len(s.classifiers):len(s.classifiers)], c)
+	})
+}
+
+func (l *Logger) classify(err error) Category {
+
+	// Entries built outside a logger, e.g. when decoding,
+	// have none and so no classifiers.
+	if l == nil {
+		return ""
+	}
+	for _, c := range l.settings().classifiers {
+		if cat := c(err); cat != "" {
+			return cat
+		}
+	}
+	return ""
+}
+
+/*
+Err attaches err to e as "error" along with its concrete type
+as "error_type", e.g. "*net.OpError", its code as "error_code"
+if it implements Coder and its category as "category" if a
+classifier recognises it, so errors can be grouped and routed
+downstream. A nil err is ignored.
+*/
+func (e *Entry) Err(err error) *Entry {
+	if err == nil || e == discard {
+		return e
+	}
+	e.Data("error", err)
+	e.Data("error_type", fmt.Sprintf("%T", err))
+	if c, ok := err.(Coder); ok {
+		e.Data("error_code", c.Code())
+	}
+	if cat := e.logger.classify(err); cat != "" {
+		e.Data("category", cat)
+	}
+	return e
+}
+
+/*
+Category returns the category recorded by Err, if any.
+*/
+func (e *Entry) Category() Category {
+	for _, kv := range e.KeyVals {
+		if kv.Key == "category" {
+			if cat, ok := kv.Val.(Category); ok {
+				return cat
+			}
+		}
+	}
+	return ""
+}
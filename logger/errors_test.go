@@ -0,0 +1,16 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrWithoutLogger(t *testing.T) {
+	e := (&Entry{}).Err(errors.New("boom"))
+	if len(e.KeyVals) != 2 || e.KeyVals[0].Key != "error" || e.KeyVals[1].Key != "error_type" {
+		t.Errorf("got %v, want error and error_type", e.KeyVals)
+	}
+	if cat := e.Category(); cat != "" {
+		t.Errorf("got category %q, want none", cat)
+	}
+}
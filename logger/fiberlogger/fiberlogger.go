@@ -0,0 +1,52 @@
+/*
+Package fiberlogger records Fiber requests as request
+threads of a logger.Logger. It is a module of its own so the
+logger doesn't depend on Fiber.
+*/
+package fiberlogger
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+/*
+Middleware opens a request thread for every request and
+ends it once the handlers have run, as logger.Middleware
+does for net/http. Fiber isn't built on net/http so each
+request is converted to an *http.Request first, which is
+what client IPs, trace contexts and baggage are read from.
+The thread's route is the pattern the request matched, e.g.
+/users/:id. An error returned by the handlers is passed to
+the app's error handler first so the thread has the status
+it responds with. Handlers find the thread's id with
+RequestId.
+*/
+func Middleware(l *logger.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var r http.Request
+		if err := fasthttpadaptor.ConvertRequest(c.Context(), &r, true); err != nil {
+			return err
+		}
+		rs := l.BeginRequest(&r)
+		c.SetUserContext(rs.Context)
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				c.Status(fiber.StatusInternalServerError)
+			}
+		}
+		rs.End(c.Route().Path, c.Response().StatusCode())
+		return nil
+	}
+}
+
+/*
+RequestId returns the id of the thread Middleware opened
+for c.
+*/
+func RequestId(c *fiber.Ctx) string {
+	return logger.RequestId(c.UserContext())
+}
@@ -0,0 +1,45 @@
+package fiberlogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	threads := make(chan logger.Thread, 1)
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		threads <- t
+		return nil
+	}}
+
+	app := fiber.New()
+	app.Use(Middleware(l))
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		l.Info(RequestId(c), "missing")
+		return fiber.ErrNotFound
+	})
+	res, err := app.Test(httptest.NewRequest("GET", "/users/1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("responded %d, want 404", res.StatusCode)
+	}
+	thread := <-threads
+	if thread.Route != "/users/:id" {
+		t.Errorf("route is %q, want /users/:id", thread.Route)
+	}
+	if thread.Status != http.StatusNotFound {
+		t.Errorf("status is %d, want 404", thread.Status)
+	}
+	if len(thread.Entries) != 1 {
+		t.Errorf("thread has %d entries, want 1", len(thread.Entries))
+	}
+}
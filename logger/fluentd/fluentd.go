@@ -0,0 +1,279 @@
+/*
+Package fluentd sends ended threads to Fluentd or Fluent Bit
+using the forward protocol, so existing aggregation layers
+can receive them without tailing files.
+*/
+package fluentd
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/msgpack"
+)
+
+/*
+Sink sends each thread as a single event whose record is
+Thread.MarshalMsgpack. It connects on first use and
+reconnects after a failure, returning the error so the
+logger can retry.
+*/
+type Sink struct {
+
+	// Addr is the server's host:port, typically
+	// localhost:24224.
+	Addr string
+
+	// Tag is the event tag, e.g. app.requests.
+	Tag string
+
+	// SharedKey enables the handshake of a server with
+	// <security> configured. Username and Password are
+	// needed if it also requires user authentication.
+	SharedKey string
+	Username  string
+	Password  string
+
+	// Hostname identifies this client during the handshake.
+	// It defaults to os.Hostname.
+	Hostname string
+
+	// RequireAck waits for the server to acknowledge each
+	// event so none are lost if the connection drops.
+	RequireAck bool
+
+	// Timeout bounds connecting, the handshake and each
+	// event. It defaults to 5 seconds.
+	Timeout time.Duration
+
+	// TLS makes the sink connect over TLS if it is set, as
+	// a server with <transport tls> requires.
+	TLS *logger.TLSOptions
+
+	// Compression is gzip to send each event compressed, in
+	// CompressedPackedForward mode, or empty to send it as
+	// it is. The forward protocol has no other encodings.
+	Compression string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+/*
+Hook sends t. It has the signature of Logger.OnLog so it can
+be assigned to it.
+*/
+func (s *Sink) Hook(t logger.Thread) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	if err := s.send(t); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *Sink) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return s.Timeout
+}
+
+func (s *Sink) hostname() string {
+	if s.Hostname != "" {
+		return s.Hostname
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+func (s *Sink) connect() error {
+
+	conn, err := s.TLS.Dial("tcp", s.Addr, s.timeout())
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.r = bufio.NewReader(conn)
+
+	if s.SharedKey != "" {
+		conn.SetDeadline(time.Now().Add(s.timeout()))
+		if err := s.handshake(); err != nil {
+			conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+handshake answers the server's HELO with a PING proving
+knowledge of the shared key and checks its PONG does too.
+*/
+func (s *Sink) handshake() error {
+
+	v, err := decode(s.r)
+	if err != nil {
+		return fmt.Errorf("fluentd: reading HELO: %v", err)
+	}
+	helo, ok := v.([]interface{})
+	if !ok || len(helo) < 2 || helo[0] != "HELO" {
+		return errors.New("fluentd: expected HELO")
+	}
+	opts, _ := helo[1].(map[string]interface{})
+	nonce, _ := opts["nonce"].(string)
+	authSalt, _ := opts["auth"].(string)
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	host := s.hostname()
+
+	var password string
+	if authSalt != "" {
+		password = digest(authSalt, s.Username, s.Password)
+	}
+
+	b := msgpack.AppendArray(nil, 6)
+	b = msgpack.AppendString(b, "PING")
+	b = msgpack.AppendString(b, host)
+	b = msgpack.AppendString(b, string(salt))
+	b = msgpack.AppendString(b, digest(string(salt), host, nonce, s.SharedKey))
+	b = msgpack.AppendString(b, s.Username)
+	b = msgpack.AppendString(b, password)
+	if _, err := s.conn.Write(b); err != nil {
+		return err
+	}
+
+	v, err = decode(s.r)
+	if err != nil {
+		return fmt.Errorf("fluentd: reading PONG: %v", err)
+	}
+	pong, ok := v.([]interface{})
+	if !ok || len(pong) < 5 || pong[0] != "PONG" {
+		return errors.New("fluentd: expected PONG")
+	}
+	if ok, _ := pong[1].(bool); !ok {
+		return fmt.Errorf("fluentd: authentication failed: %v", pong[2])
+	}
+	server, _ := pong[3].(string)
+	if pong[4] != digest(string(salt), server, nonce, s.SharedKey) {
+		return errors.New("fluentd: server failed to prove the shared key")
+	}
+	return nil
+}
+
+func digest(parts ...string) string {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+/*
+send writes t in message mode: [tag, time, record, option].
+*/
+func (s *Sink) send(t logger.Thread) error {
+
+	s.conn.SetDeadline(time.Now().Add(s.timeout()))
+
+	var chunk string
+	if s.RequireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+	}
+
+	date := t.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var b []byte
+	var option []string
+	switch s.Compression {
+	case "":
+		b = msgpack.AppendArray(nil, 4)
+		b = msgpack.AppendString(b, s.Tag)
+		b = appendEventTime(b, date)
+		b = append(b, t.MarshalMsgpack()...)
+	case "gzip":
+		// Compressed events are sent in CompressedPackedForward
+		// mode, whose entries are compressed [time, record]
+		// pairs, here just the one.
+		entry := msgpack.AppendArray(nil, 2)
+		entry = appendEventTime(entry, date)
+		entry = append(entry, t.MarshalMsgpack()...)
+		zipped, err := logger.Compress("gzip", entry)
+		if err != nil {
+			return err
+		}
+		b = msgpack.AppendArray(nil, 3)
+		b = msgpack.AppendString(b, s.Tag)
+		b = msgpack.AppendBinary(b, zipped)
+		option = append(option, "compressed", "gzip")
+	default:
+		return fmt.Errorf("fluentd: unsupported compression %q; the forward protocol only has gzip", s.Compression)
+	}
+	if chunk != "" {
+		option = append(option, "chunk", chunk)
+	}
+	b = msgpack.AppendMap(b, len(option)/2)
+	for _, kv := range option {
+		b = msgpack.AppendString(b, kv)
+	}
+	if _, err := s.conn.Write(b); err != nil {
+		return err
+	}
+
+	if chunk == "" {
+		return nil
+	}
+	v, err := decode(s.r)
+	if err != nil {
+		return fmt.Errorf("fluentd: reading ack: %v", err)
+	}
+	if m, ok := v.(map[string]interface{}); !ok || m["ack"] != chunk {
+		return errors.New("fluentd: event wasn't acknowledged")
+	}
+	return nil
+}
+
+/*
+Close closes the connection to the server.
+*/
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,324 @@
+package fluentd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/msgpack"
+	"github.com/jakebowkett/go-logger/logger/internal/testcert"
+)
+
+func TestSinkTLS(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "fluentd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, _, cert, err := testcert.Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	events := make(chan interface{}, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		v, err := decode(bufio.NewReader(conn))
+		if err != nil {
+			events <- err
+			return
+		}
+		events <- v
+	}()
+
+	s := &Sink{
+		Addr: ln.Addr().String(),
+		Tag:  "app.requests",
+		TLS:  &logger.TLSOptions{CAFile: certFile},
+	}
+	if err := s.Hook(logger.Thread{Kind: logger.KindRequest, Id: "abc", Date: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	ev, ok := (<-events).([]interface{})
+	if !ok || len(ev) != 4 || ev[0] != "app.requests" {
+		t.Fatalf("server received %#v, want a message mode event", ev)
+	}
+}
+
+func TestSinkGzip(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	events := make(chan interface{}, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		v, err := decode(bufio.NewReader(conn))
+		if err != nil {
+			events <- err
+			return
+		}
+		events <- v
+	}()
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	s := &Sink{Addr: ln.Addr().String(), Tag: "app", Compression: "gzip"}
+	if err := s.Hook(logger.Thread{Kind: logger.KindRequest, Id: "abc", Date: date}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, ok := (<-events).([]interface{})
+	if !ok || len(ev) != 3 || ev[0] != "app" {
+		t.Fatalf("server received %#v, want a CompressedPackedForward event", ev)
+	}
+	if opt, _ := ev[2].(map[string]interface{}); opt["compressed"] != "gzip" {
+		t.Fatalf("option is %#v", ev[2])
+	}
+	entries, _ := ev[1].(string)
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(entries)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := decode(bufio.NewReader(zr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, _ := entry.([]interface{})
+	if len(pair) != 2 || !date.Equal(pair[0].(time.Time)) {
+		t.Fatalf("entry is %#v", entry)
+	}
+	if record, _ := pair[1].(map[string]interface{}); record["id"] != "abc" {
+		t.Fatalf("record is %#v", pair[1])
+	}
+}
+
+func TestSinkUnsupportedCompression(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	s := &Sink{Addr: ln.Addr().String(), Tag: "app", Compression: "zstd"}
+	if err := s.Hook(logger.Thread{Kind: logger.KindRequest}); err == nil {
+		t.Fatal("sent an event with an encoding the forward protocol doesn't have")
+	}
+}
+
+/*
+server is a Fluentd forward input with <security> and user
+authentication configured and require_ack_response on.
+*/
+type server struct {
+	ln     net.Listener
+	key    string
+	users  map[string]string
+	events chan interface{}
+}
+
+func newServer(t *testing.T) *server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server{
+		ln:     ln,
+		key:    "secret",
+		users:  map[string]string{"app": "hunter2"},
+		events: make(chan interface{}, 10),
+	}
+}
+
+/*
+serve serves one connection, sending each event it receives
+to events, and closes it without acknowledging event drop,
+if it isn't zero.
+*/
+func (s *server) serve(drop int) {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err := s.session(conn, drop); err != nil {
+		s.events <- err
+	}
+}
+
+func (s *server) session(conn net.Conn, drop int) error {
+
+	r := bufio.NewReader(conn)
+	nonce, authSalt := "nonce", "auth-salt"
+	b := msgpack.AppendArray(nil, 2)
+	b = msgpack.AppendString(b, "HELO")
+	b = msgpack.AppendMap(b, 3)
+	b = msgpack.AppendString(b, "nonce")
+	b = msgpack.AppendString(b, nonce)
+	b = msgpack.AppendString(b, "auth")
+	b = msgpack.AppendString(b, authSalt)
+	b = msgpack.AppendString(b, "keepalive")
+	b = msgpack.AppendBool(b, true)
+	if _, err := conn.Write(b); err != nil {
+		return err
+	}
+
+	v, err := decode(r)
+	if err != nil {
+		return err
+	}
+	ping, _ := v.([]interface{})
+	if len(ping) != 6 || ping[0] != "PING" {
+		return errors.New("expected PING")
+	}
+	host, _ := ping[1].(string)
+	salt, _ := ping[2].(string)
+	user, _ := ping[4].(string)
+	reason := ""
+	if ping[3] != digest(salt, host, nonce, s.key) {
+		reason = "shared key mismatch"
+	} else if pass, ok := s.users[user]; !ok || ping[5] != digest(authSalt, user, pass) {
+		reason = "username/password mismatch"
+	}
+	b = msgpack.AppendArray(nil, 5)
+	b = msgpack.AppendString(b, "PONG")
+	b = msgpack.AppendBool(b, reason == "")
+	b = msgpack.AppendString(b, reason)
+	b = msgpack.AppendString(b, "aggregator")
+	b = msgpack.AppendString(b, digest(salt, "aggregator", nonce, s.key))
+	if _, err := conn.Write(b); err != nil {
+		return err
+	}
+	if reason != "" {
+		return errors.New(reason)
+	}
+
+	for n := 1; ; n++ {
+		v, err := decode(r)
+		if err != nil {
+			return nil
+		}
+		s.events <- v
+		if n == drop {
+			return nil
+		}
+		ev, _ := v.([]interface{})
+		opt, _ := ev[len(ev)-1].(map[string]interface{})
+		b = msgpack.AppendMap(nil, 1)
+		b = msgpack.AppendString(b, "ack")
+		b = msgpack.AppendString(b, opt["chunk"].(string))
+		if _, err := conn.Write(b); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) sink() *Sink {
+	return &Sink{
+		Addr:       s.ln.Addr().String(),
+		Tag:        "app.requests",
+		SharedKey:  s.key,
+		Username:   "app",
+		Password:   "hunter2",
+		Hostname:   "web-1",
+		RequireAck: true,
+		Timeout:    5 * time.Second,
+	}
+}
+
+func TestSinkHandshake(t *testing.T) {
+
+	srv := newServer(t)
+	defer srv.ln.Close()
+	go srv.serve(0)
+
+	s := srv.sink()
+	defer s.Close()
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	th := logger.Thread{Kind: logger.KindRequest, Id: "abc", Date: date, Route: "/users/:id", Status: 200}
+	for i := 0; i < 2; i++ {
+		if err := s.Hook(th); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		ev, ok := (<-srv.events).([]interface{})
+		if !ok || len(ev) != 4 || ev[0] != "app.requests" {
+			t.Fatalf("server received %#v, want a message mode event", ev)
+		}
+		if at, ok := ev[1].(time.Time); !ok || !at.Equal(date) {
+			t.Fatalf("event time is %#v, want %v", ev[1], date)
+		}
+		if record, _ := ev[2].(map[string]interface{}); record["id"] != "abc" || record["route"] != "/users/:id" {
+			t.Fatalf("record is %#v", ev[2])
+		}
+		if opt, _ := ev[3].(map[string]interface{}); opt["chunk"] == "" {
+			t.Fatalf("option is %#v, want a chunk to acknowledge", ev[3])
+		}
+	}
+}
+
+func TestSinkAuthFailed(t *testing.T) {
+
+	srv := newServer(t)
+	defer srv.ln.Close()
+	go srv.serve(0)
+
+	s := srv.sink()
+	s.Password = "wrong"
+	err := s.Hook(logger.Thread{Kind: logger.KindRequest})
+	if err == nil || err.Error() != "fluentd: authentication failed: username/password mismatch" {
+		t.Fatalf("Hook returned %v, want the server's reason", err)
+	}
+}
+
+/*
+TestSinkUnacknowledged has the server drop the connection
+without acknowledging an event and checks Hook fails, so the
+logger retries it, and the next Hook reconnects.
+*/
+func TestSinkUnacknowledged(t *testing.T) {
+
+	srv := newServer(t)
+	defer srv.ln.Close()
+	go srv.serve(1)
+
+	s := srv.sink()
+	defer s.Close()
+	if err := s.Hook(logger.Thread{Kind: logger.KindRequest, Id: "1"}); err == nil {
+		t.Fatal("Hook succeeded without the event being acknowledged")
+	}
+	<-srv.events
+
+	go srv.serve(0)
+	if err := s.Hook(logger.Thread{Kind: logger.KindRequest, Id: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	ev, _ := (<-srv.events).([]interface{})
+	if record, _ := ev[2].(map[string]interface{}); record["id"] != "2" {
+		t.Fatalf("server received %#v after reconnecting", ev)
+	}
+}
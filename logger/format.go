@@ -2,80 +2,187 @@ package logger
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const timeFormat string = "MST 2006-01-02 15:04:05"
 
 type Thread struct {
 	Date     time.Time
-	Kind     threadKind
+	Kind     ThreadKind
 	Id       string
 	Ip       string
 	Method   string
 	Route    string
 	Status   int
 	Duration int64
-	Entries  []*Entry
+
+	// Entries are in the order they were logged. Entries
+	// logged concurrently are ordered by when they were
+	// added to the thread, which happens under its lock, so
+	// every hook and format sees the same order, as does a
+	// thread recovered from a write-ahead log.
+	Entries []*Entry
+
+	Redirect string
+	Meta     map[MetaKey]interface{}
+	KeyVals  []kv
+	Tags     []string
+	Phases   []Phase
+
+	// Chunk numbers the parts of a thread emitted in chunks
+	// from 1. It is zero for threads that weren't chunked.
+	// See SetChunking.
+	Chunk int
+
+	// CorrelationId is shared by related threads, such as
+	// a request and the sessions it started.
+	CorrelationId string
+
+	// Outcome is how the thread said it finished, if it
+	// did. See Session.Fail and Session.Succeed.
+	Outcome Outcome
+
+	// Unterminated is true for threads that were emitted
+	// without being ended, such as those recovered from
+	// a write-ahead log after a crash.
+	Unterminated bool
+
+	// TimedOut is true for threads emitted because they
+	// were open for too long. See SetMaxAge.
+	TimedOut bool
+
+	catalog   Catalog
+	precision Precision
+	humanize  Humanize
+	glyphs    TreeGlyphs
+
+	// pooled is whether the entries are recycled once the
+	// thread has been emitted. See SetPooling.
+	pooled bool
 }
 
+/*
+FormatRecord formats t as exactly one line. Entries are
+separated by an escaped newline (a backslash followed by n)
+and any newlines, control characters or backslashes within
+them are escaped too so the record can be split on newlines
+and each entry recovered unambiguously. Each entry begins
+with its sequence number, e.g. #42, if it has one.
+*/
 func (t Thread) FormatRecord() string {
 
-	msg := ""
+	var entries []string
 	for _, e := range t.Entries {
+		entry := ""
+		if e.Seq != 0 {
+			entry += fmt.Sprintf("#%d ", e.Seq)
+		}
 		if e.Message != "" {
-			msg += e.Message + " "
+			entry += e.Message + " "
 		}
 		if e.File != "" {
 			fileParts := strings.SplitAfterN(e.File, "/storydevs", 2)
 			file := fileParts[len(fileParts)-1]
-			msg += fmt.Sprintf("%s:%d (%s)", file, e.Line, e.Function)
+			entry += fmt.Sprintf("%s:%d (%s)", file, e.Line, e.Function)
 		}
-		msg += "\n"
+		entries = append(entries, escapeRecord(entry))
+	}
+	msg := strings.Join(entries, `\n`)
+	if data := t.headerData(false); data != "" {
+		msg = escapeRecord(strings.TrimPrefix(data, " ")) + " " + msg
 	}
-	msg = strings.TrimSuffix(msg, "\n")
 
 	s := ""
 	switch t.Kind {
-	case kindRequest:
+	case KindRequest:
 		s = fmt.Sprintf(
 			"%d %d %dms %s %s %s\n",
 			t.Date.UnixNano(),
 			t.Status,
 			t.Duration/1000000,
-			t.Method,
-			t.Route,
+			escapeRecord(t.Method),
+			escapeRecord(t.Route),
 			msg,
 		)
-	case kindSession:
+	case KindSession:
 		s = fmt.Sprintf(
 			"%d %s\n",
 			t.Date.UnixNano(),
 			msg,
 		)
+	case KindConnection:
+		s = fmt.Sprintf(
+			"%d %dms %s %s %s\n",
+			t.Date.UnixNano(),
+			t.Duration/1000000,
+			escapeRecord(t.Route),
+			escapeRecord(t.Ip),
+			msg,
+		)
+	case KindCommand:
+		s = fmt.Sprintf(
+			"%d %d %dms %s %s\n",
+			t.Date.UnixNano(),
+			t.Status,
+			t.Duration/1000000,
+			escapeRecord(t.Route),
+			msg,
+		)
 	}
 
 	return s
 }
 
 func (thread Thread) FormatTerse() string {
+	return thread.formatTerse(false)
+}
+
+func (thread Thread) formatTerse(colour bool) string {
 
 	var output string
 
-	if thread.Kind == kindRequest {
-		duration := fmt.Sprintf("%dms", thread.Duration/1000000)
+	if thread.Kind == KindRequest {
 		output = fmt.Sprintf(
 			"%s %d %s %s\n",
-			thread.Date.Format(time.Kitchen), thread.Status, duration, thread.Route)
+			thread.Date.Format(time.Kitchen), thread.Status, thread.duration(), escape(thread.Route, false))
 	}
 
-	if thread.Kind == kindSession {
+	if thread.Kind == KindSession {
 		output = fmt.Sprintf(
 			"%s Session: %s\n",
-			thread.Date.Format(time.Kitchen), thread.Route)
+			thread.Date.Format(time.Kitchen), escape(thread.Route, false))
 	}
 
+	if thread.Kind == KindConnection {
+		output = fmt.Sprintf(
+			"%s Connection: %s %s %s\n",
+			thread.Date.Format(time.Kitchen), escape(thread.Route, false),
+			thread.duration(), escape(thread.Ip, false))
+	}
+
+	if thread.Kind == KindCommand {
+		output = fmt.Sprintf(
+			"%s Command: %s exit %d %s\n",
+			thread.Date.Format(time.Kitchen), escape(thread.Route, false),
+			thread.Status, thread.duration())
+	}
+
+	output = strings.TrimSuffix(output, "\n") + thread.headerData(false)
+	if thread.Chunk > 0 {
+		output += fmt.Sprintf(" (chunk %d)", thread.Chunk)
+	}
+	if thread.TimedOut {
+		output += " (timed out)"
+	} else if thread.Unterminated {
+		output += " (unterminated)"
+	}
+	output += "\n"
+
 	for _, e := range thread.Entries {
 
 		var kvs string
@@ -84,27 +191,26 @@ func (thread Thread) FormatTerse() string {
 		}
 
 		output += fmt.Sprintf(
-			"[%s] %s %s\n",
-			e.Level, e.Message, kvs)
+			"%s %s %s\n",
+			levelLabel(e.Level, colour), escape(thread.message(e), false), kvs)
 	}
 
 	return output
 }
 
 func (thread Thread) FormatPretty() string {
+	return thread.formatPretty(false)
+}
+
+func (thread Thread) formatPretty(colour bool) string {
 
 	var output string
 
-	if thread.Kind == kindRequest {
+	if thread.Kind == KindRequest {
 
-		duration := fmt.Sprintf("%dms", thread.Duration/1000000)
-		duration = pad(duration, 10)
+		duration := pad(thread.duration(), 10)
 
-		lastColon := strings.LastIndex(thread.Ip, ":")
-		if lastColon == -1 {
-			lastColon = 0
-		}
-		ip := thread.Ip[0:lastColon]
+		ip := clientIp(thread)
 
 		output = fmt.Sprintf(
 			// "\nRequest: %s, IPs: %s"+
@@ -112,13 +218,13 @@ func (thread Thread) FormatPretty() string {
 			// thread.Id,
 			thread.Date.Format(time.Kitchen),
 			thread.Status,
-			pad(ip, 20),
+			pad(escape(ip, false), 20),
 			duration,
-			thread.Method,
-			thread.Route)
+			escape(thread.Method, false),
+			escape(thread.Route, false))
 	}
 
-	if thread.Kind == kindSession {
+	if thread.Kind == KindSession {
 		if thread.Route == "" {
 			output = "\n" + thread.Date.Format(time.Kitchen) + "\n"
 		} else {
@@ -127,17 +233,52 @@ func (thread Thread) FormatPretty() string {
 				"\n%s Session: %s\n",
 				// thread.Id,
 				thread.Date.Format(time.Kitchen),
-				thread.Route)
+				escape(thread.Route, false))
 		}
 	}
 
+	if thread.Kind == KindConnection {
+		output = fmt.Sprintf(
+			"\n%s Connection %s %s %s\n",
+			thread.Date.Format(time.Kitchen),
+			pad(escape(thread.Ip, false), 26),
+			pad(thread.duration(), 10),
+			escape(thread.Route, false))
+	}
+
+	if thread.Kind == KindCommand {
+		output = fmt.Sprintf(
+			"\n%s Command exit %d %s %s\n",
+			thread.Date.Format(time.Kitchen),
+			thread.Status,
+			pad(thread.duration(), 10),
+			escape(thread.Route, false))
+	}
+
+	output = strings.TrimSuffix(output, "\n") + thread.headerData(true)
+	if thread.Chunk > 0 {
+		output += fmt.Sprintf(" (chunk %d)", thread.Chunk)
+	}
+	if thread.TimedOut {
+		output += " (timed out)"
+	} else if thread.Unterminated {
+		output += " (unterminated)"
+	}
+	output += "\n"
+	if len(thread.Phases) > 0 {
+		output += " " + thread.phaseSummary() + "\n"
+	}
+
+	glyphs := thread.glyphs
+	if glyphs == (TreeGlyphs{}) {
+		glyphs = UnicodeGlyphs
+	}
+
 	for i, e := range thread.Entries {
 
-		lnStart := "├─"
-		fStart := "│ "
+		lnStart, fStart := glyphs.Branch, glyphs.indent(true)
 		if i == len(thread.Entries)-1 {
-			lnStart = "└─"
-			fStart = "  "
+			lnStart, fStart = glyphs.Last, glyphs.indent(false)
 		}
 
 		fileParts := strings.SplitAfterN(e.File, "/storydevs", 2)
@@ -147,17 +288,19 @@ func (thread Thread) FormatPretty() string {
 		var kvs string
 		for _, kv := range e.KeyVals {
 
-			var val string
+			val, ok := thread.humanize.value(kv.Key, kv.Val)
 			switch kv.Val.(type) {
 			case error:
-				val = fmt.Sprintf("\"%v\"", kv.Val.(error).Error())
+				val = fmt.Sprintf("\"%v\"", escape(kv.Val.(error).Error(), false))
 			case string:
-				val = fmt.Sprintf("\"%v\"", kv.Val)
+				val = fmt.Sprintf("\"%v\"", escape(kv.Val.(string), false))
 			default:
-				val = fmt.Sprintf("%v", kv.Val)
+				if !ok {
+					val = escape(fmt.Sprintf("%v", kv.Val), false)
+				}
 			}
 
-			kvs += fmt.Sprintf(" %s    %s = %s\n", fStart, kv.Key, val)
+			kvs += fmt.Sprintf(" %s    %s = %s\n", fStart, escape(kv.Key, false), val)
 		}
 
 		var runtimeInfo string
@@ -167,7 +310,7 @@ func (thread Thread) FormatPretty() string {
 				fStart, file, e.Line, e.Function)
 		}
 
-		msgParts := strings.Split(e.Message, "\n")
+		msgParts := strings.Split(escape(thread.message(e), true), "\n")
 		for i := range msgParts {
 			if i == 0 {
 				continue
@@ -176,16 +319,90 @@ func (thread Thread) FormatPretty() string {
 		}
 
 		output += fmt.Sprintf(
-			" │\n"+
-				" %s [%s] %s\n"+
+			" %s\n"+
+				" %s %s %s\n"+
 				"%s"+
 				"%s",
-			lnStart, e.Level, strings.Join(msgParts, "\n"), kvs, runtimeInfo)
+			glyphs.Pipe, lnStart, levelLabel(e.Level, colour), strings.Join(msgParts, "\n"), kvs, runtimeInfo)
 	}
 
 	return output
 }
 
+/*
+Data attaches k and v to t like ThreadData does to an open
+thread. It is meant for Logger.Enrich.
+*/
+func (t *Thread) Data(k string, v interface{}) {
+	t.KeyVals = append(t.KeyVals, kv{k, v})
+}
+
+func (t Thread) HasTag(tag string) bool {
+	return hasTag(t.Tags, tag)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+headerData renders the thread's key/vals for the end of
+the header line, with a leading space if there are any.
+Integers are humanized if humanize is true. See SetHumanize.
+*/
+func (t Thread) headerData(humanize bool) string {
+	var s string
+	if t.CorrelationId != "" {
+		s += " correlation=" + strconv.Quote(t.CorrelationId)
+	}
+	if t.Outcome != "" {
+		s += " outcome=" + escape(string(t.Outcome), false)
+	}
+	for _, kv := range t.KeyVals {
+		var val string
+		switch v := kv.Val.(type) {
+		case error:
+			val = strconv.Quote(v.Error())
+		case string:
+			val = strconv.Quote(v)
+		default:
+			val = escape(fmt.Sprint(v), false)
+			if h, ok := t.humanize.value(kv.Key, v); ok && humanize {
+				// Sizes have a space between the number
+				// and the unit.
+				val = h
+				if strings.Contains(h, " ") {
+					val = strconv.Quote(h)
+				}
+			}
+		}
+		s += fmt.Sprintf(" %s=%s", escape(kv.Key, false), val)
+	}
+	return s
+}
+
+var levelColours = map[Level]string{
+	LevelDebug: "\x1b[90m",
+	LevelError: "\x1b[31m",
+}
+
+/*
+levelLabel renders lv in brackets, coloured with ANSI escape
+sequences if colour is true.
+*/
+func levelLabel(lv Level, colour bool) string {
+	label := "[" + lv.String() + "]"
+	if c, ok := levelColours[lv]; ok && colour {
+		return c + label + "\x1b[0m"
+	}
+	return label
+}
+
 func pad(s string, length int) string {
 	diff := length - len([]rune(s))
 	if diff <= 0 {
@@ -193,3 +410,51 @@ func pad(s string, length int) string {
 	}
 	return strings.Repeat("_", diff) + s
 }
+
+/*
+escape makes control characters, including the escape
+character that begins ANSI sequences, and invalid UTF-8
+visible as Go escape sequences so that logged input can't
+forge lines or manipulate the terminal. Tabs are left alone
+and so are newlines if keepNewlines is true.
+*/
+func escape(s string, keepNewlines bool) string {
+
+	clean := true
+	for _, r := range s {
+		if mustEscape(r, keepNewlines) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, "\\x%02x", s[0])
+		case mustEscape(r, keepNewlines):
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	return b.String()
+}
+
+func escapeRecord(s string) string {
+	return escape(strings.Replace(s, `\`, `\\`, -1), false)
+}
+
+func mustEscape(r rune, keepNewlines bool) bool {
+	if r == '\t' || (r == '\n' && keepNewlines) {
+		return false
+	}
+	return r == utf8.RuneError || unicode.IsControl(r)
+}
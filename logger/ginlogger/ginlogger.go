@@ -0,0 +1,28 @@
+/*
+Package ginlogger records Gin requests as request threads of
+a logger.Logger. It is a module of its own so the logger
+doesn't depend on Gin.
+*/
+package ginlogger
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Middleware opens a request thread for every request and
+ends it once the handlers have run, as logger.Middleware
+does for net/http. The thread's route is the pattern the
+request matched, e.g. /users/:id, and its status is the one
+Gin wrote. Handlers find the thread's id with
+logger.RequestId(c.Request.Context()).
+*/
+func Middleware(l *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rs := l.BeginRequest(c.Request)
+		c.Request = c.Request.WithContext(rs.Context)
+		c.Next()
+		rs.End(c.FullPath(), c.Writer.Status())
+	}
+}
@@ -0,0 +1,44 @@
+package ginlogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	threads := make(chan logger.Thread, 1)
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		threads <- t
+		return nil
+	}}
+
+	r := gin.New()
+	r.Use(Middleware(l))
+	r.GET("/users/:id", func(c *gin.Context) {
+		l.Info(logger.RequestId(c.Request.Context()), "found")
+		c.Status(http.StatusNoContent)
+	})
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	thread := <-threads
+	if thread.Route != "/users/:id" {
+		t.Errorf("route is %q, want /users/:id", thread.Route)
+	}
+	if thread.Status != http.StatusNoContent {
+		t.Errorf("status is %d, want 204", thread.Status)
+	}
+	if thread.Ip != "203.0.113.1" {
+		t.Errorf("ip is %q, want 203.0.113.1", thread.Ip)
+	}
+	if len(thread.Entries) != 1 {
+		t.Errorf("thread has %d entries, want 1", len(thread.Entries))
+	}
+}
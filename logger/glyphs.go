@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+TreeGlyphs are the characters FormatPretty draws the tree of
+a thread's entries with. Branch begins each entry but the
+last, which begins with Last, and Pipe joins them.
+*/
+type TreeGlyphs struct {
+	Branch string
+	Last   string
+	Pipe   string
+}
+
+var (
+	// UnicodeGlyphs are box-drawing characters. They are
+	// the default.
+	UnicodeGlyphs = TreeGlyphs{Branch: "├─", Last: "└─", Pipe: "│"}
+
+	// ASCIIGlyphs are for terminals, log viewers and email
+	// clients that mangle box-drawing characters.
+	ASCIIGlyphs = TreeGlyphs{Branch: "|-", Last: "`-", Pipe: "|"}
+)
+
+/*
+SetTreeGlyphs sets the glyphs FormatPretty draws entries
+with. The zero TreeGlyphs restores UnicodeGlyphs.
+*/
+func (l *Logger) SetTreeGlyphs(g TreeGlyphs) {
+	l.update("glyphs", func(s *settings) { s.glyphs = g })
+}
+
+/*
+indent is what lines continuing an entry begin with, as wide
+as Branch so they line up with its text. It includes Pipe if
+more entries follow.
+*/
+func (g TreeGlyphs) indent(more bool) string {
+	width := utf8.RuneCountInString(g.Branch)
+	if !more {
+		return strings.Repeat(" ", width)
+	}
+	pad := width - utf8.RuneCountInString(g.Pipe)
+	if pad < 0 {
+		pad = 0
+	}
+	return g.Pipe + strings.Repeat(" ", pad)
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"time"
+)
+
+/*
+Heartbeat logs that s is still alive, with the current time
+attached as "time", so if it never ends the thread shows how
+far it got and when it stopped. See also SetMaxAge.
+*/
+func (s *Session) Heartbeat() *Entry {
+	if s.ended {
+		return discard
+	}
+	return s.logger.heartbeat(s.id)
+}
+
+/*
+HeartbeatEvery calls Heartbeat on its own goroutine every d
+until s ends. Calling it again replaces the previous interval
+and a d of zero stops it.
+*/
+func (s *Session) HeartbeatEvery(d time.Duration) {
+	if s.ended {
+		return
+	}
+	s.stopHeartbeat()
+	if d <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	s.beat = stop
+	go func() {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.logger.heartbeat(s.id)
+			}
+		}
+	}()
+}
+
+func (s *Session) stopHeartbeat() {
+	if s.beat != nil {
+		close(s.beat)
+		s.beat = nil
+	}
+}
+
+func (l *Logger) heartbeat(threadId string) *Entry {
+	return l.logEntry(LevelInfo, threadId, "heartbeat").Data("time", l.now())
+}
@@ -0,0 +1,319 @@
+/*
+Package honeycomb sends ended threads to Honeycomb as wide
+events, one per thread, using the batch events API.
+*/
+package honeycomb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/httpclient"
+)
+
+/*
+DefaultURL is Honeycomb's US API.
+*/
+const DefaultURL = "https://api.honeycomb.io"
+
+const maxBytes = 5 << 20
+
+/*
+Sink accumulates events and sends them whenever a request's
+worth has accumulated, Flush is called or, once started,
+periodically.
+
+Each event has the thread's data as top-level fields, then
+thread.id, kind, duration_ms, status, method, route, ip,
+error (whether any entry was an error), error.count and
+entry.count. Entries are flattened with the prefix entry.N.,
+e.g. entry.0.level, entry.0.message and entry.0.data.key,
+and thread-level fields with the same names as data win.
+Threads carrying a trace context have trace.trace_id and
+trace.span_id.
+*/
+type Sink struct {
+
+	// URL defaults to DefaultURL.
+	URL string
+
+	APIKey  string
+	Dataset string
+
+	// Client defaults to http.DefaultClient, or a client
+	// using TLS if it is set.
+	Client *http.Client
+
+	// TLS configures how the server is verified, and any
+	// client certificate, when Client isn't set.
+	TLS *logger.TLSOptions
+
+	// Token is sent as a bearer token if it is set, e.g. to
+	// a proxy in front of the API.
+	Token string
+
+	// Compression is the content encoding of requests, such
+	// as gzip or, once registered, zstd, or empty to send
+	// them as they are. See logger.RegisterCompression.
+	Compression string
+
+	// OnError is called when a periodic flush fails.
+	OnError func(error)
+
+	clients httpclient.Cache
+
+	mu     sync.Mutex
+	events []json.RawMessage
+	size   int
+	stop   chan struct{}
+}
+
+type event struct {
+	Time string                 `json:"time"`
+	Data map[string]interface{} `json:"data"`
+}
+
+/*
+Hook adds t to the next request. It has the signature of
+Logger.OnLog so it can be assigned to it.
+*/
+func (s *Sink) Hook(t logger.Thread) error {
+
+	b, err := json.Marshal(event{
+		Time: t.Date.UTC().Format(time.RFC3339Nano),
+		Data: fields(t),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size+len(b) > maxBytes {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	s.events = append(s.events, b)
+	s.size += len(b) + 1
+	return nil
+}
+
+func fields(t logger.Thread) map[string]interface{} {
+
+	f := map[string]interface{}{}
+	for _, kv := range t.KeyVals {
+		f[kv.Key] = value(kv.Val)
+	}
+
+	errs := 0
+	for i, e := range t.Entries {
+		prefix := "entry." + strconv.Itoa(i) + "."
+		f[prefix+"level"] = e.Level.String()
+		f[prefix+"message"] = e.Message
+		if e.File != "" {
+			f[prefix+"file"] = e.File
+			f[prefix+"line"] = e.Line
+			f[prefix+"function"] = e.Function
+		}
+		for _, kv := range e.KeyVals {
+			f[prefix+"data."+kv.Key] = value(kv.Val)
+		}
+		if e.Level == logger.LevelError {
+			errs++
+		}
+	}
+
+	f["thread.id"] = t.Id
+	f["kind"] = t.Kind.String()
+	f["error"] = errs > 0
+	f["error.count"] = errs
+	f["entry.count"] = len(t.Entries)
+	if t.Kind == logger.KindRequest {
+		f["duration_ms"] = float64(t.Duration) / 1e6
+		f["status"] = t.Status
+		f["method"] = t.Method
+		f["route"] = t.Route
+		f["ip"] = t.Ip
+	} else if t.Route != "" {
+		f["name"] = t.Route
+	}
+	if t.CorrelationId != "" {
+		f["correlation_id"] = t.CorrelationId
+	}
+	if t.Outcome != "" {
+		f["outcome"] = string(t.Outcome)
+	}
+	if len(t.Tags) > 0 {
+		f["tags"] = strings.Join(t.Tags, ",")
+	}
+	if tc, ok := t.Meta[logger.MetaTrace].(logger.TraceContext); ok {
+		f["trace.trace_id"] = tc.TraceId
+		f["trace.span_id"] = tc.SpanId
+		if tc.ParentSpanId != "" {
+			f["trace.parent_id"] = tc.ParentSpanId
+		}
+	}
+	return f
+}
+
+/*
+value keeps basic types so Honeycomb can treat them as
+numbers or booleans and formats anything else.
+*/
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return v
+	case error:
+		return v.Error()
+	case time.Duration:
+		return float64(v) / 1e6
+	}
+	return fmt.Sprint(v)
+}
+
+/*
+Flush sends the accumulated events, unless there are none.
+They are kept for the next attempt if sending fails.
+*/
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *Sink) flush() error {
+
+	if len(s.events) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, e := range s.events {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(e)
+	}
+	body.WriteByte(']')
+	compressed, err := logger.Compress(s.Compression, body.Bytes())
+	if err != nil {
+		return fmt.Errorf("honeycomb: %v", strings.TrimPrefix(err.Error(), "logger: "))
+	}
+
+	base := s.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	req, err := http.NewRequest(http.MethodPost,
+		strings.TrimSuffix(base, "/")+"/1/batch/"+url.PathEscape(s.Dataset), bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Compression != "" {
+		req.Header.Set("Content-Encoding", s.Compression)
+	}
+	req.Header.Set("X-Honeycomb-Team", s.APIKey)
+
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client, err := s.clients.Get(s.Client, s.TLS)
+	if err != nil {
+		return fmt.Errorf("honeycomb: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("honeycomb: batch responded %s", resp.Status)
+	}
+
+	// Each event has its own status. Those that failed
+	// are dropped since resending the batch would
+	// duplicate the rest.
+	var statuses []struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	n := len(s.events)
+	s.events = nil
+	s.size = 0
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, st := range statuses {
+		if st.Status/100 != 2 {
+			if failed == 0 {
+				first = st.Error
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("honeycomb: %d of %d events rejected: %s", failed, n, first)
+	}
+	return nil
+}
+
+/*
+Start flushes every interval until Close is called.
+*/
+func (s *Sink) Start(every time.Duration) {
+
+	stop := make(chan struct{})
+	s.mu.Lock()
+	old := s.stop
+	s.stop = stop
+	s.mu.Unlock()
+	if old != nil {
+		close(old)
+	}
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil && s.OnError != nil {
+					s.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+/*
+Close stops periodic flushing and flushes what remains.
+*/
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return s.Flush()
+}
@@ -0,0 +1,192 @@
+package honeycomb
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+type received struct {
+	Time string
+	Data map[string]interface{}
+}
+
+/*
+api is the batch events API. It rejects events whose reject
+field is true and records the rest, one batch per request.
+*/
+type api struct {
+	mu      sync.Mutex
+	paths   []string
+	batches [][]received
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.Header.Get("X-Honeycomb-Team") != "key" {
+		http.Error(w, "unknown API key", http.StatusUnauthorized)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	var events []received
+	if err := json.NewDecoder(body).Decode(&events); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.paths = append(a.paths, r.URL.EscapedPath())
+	a.batches = append(a.batches, events)
+
+	var statuses []map[string]interface{}
+	for _, e := range events {
+		if e.Data["reject"] == true {
+			statuses = append(statuses, map[string]interface{}{"status": 400, "error": "bad event"})
+		} else {
+			statuses = append(statuses, map[string]interface{}{"status": 202})
+		}
+	}
+	json.NewEncoder(w).Encode(statuses)
+}
+
+func (a *api) sizes() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var n []int
+	for _, b := range a.batches {
+		n = append(n, len(b))
+	}
+	return n
+}
+
+func TestSinkEvent(t *testing.T) {
+
+	a := &api{}
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	s := &Sink{URL: srv.URL + "/", APIKey: "key", Dataset: "web logs", Compression: "gzip"}
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	l := &logger.Logger{}
+	l.SetClock(func() time.Time { return date })
+	id := l.NewId()
+	l.Info(id, "handled").Data("user", 7).Data("took", 1500*time.Microsecond)
+	l.Error(id, "failed")
+	l.ThreadData(id, "tenant", "acme")
+	l.ThreadData(id, "kind", "overridden")
+	l.Tag(id, "audit")
+	l.SetMeta(id, logger.MetaTrace, logger.TraceContext{TraceId: "trace", SpanId: "span", ParentSpanId: "parent"})
+	th, _ := l.End(id, "10.0.0.1", "GET", "/users/:id", int64(1500*time.Microsecond))
+
+	if err := s.Hook(th); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.batches) != 1 || a.paths[0] != "/1/batch/web%20logs" {
+		t.Fatalf("API received %d batches at %q", len(a.batches), a.paths)
+	}
+	ev := a.batches[0][0]
+	if ev.Time != "2020-01-02T03:04:05.000000006Z" {
+		t.Fatalf("event time is %s", ev.Time)
+	}
+	want := map[string]interface{}{
+		"tenant":            "acme",
+		"kind":              "request",
+		"thread.id":         id,
+		"duration_ms":       1.5,
+		"status":            float64(200),
+		"method":            "GET",
+		"route":             "/users/:id",
+		"ip":                "10.0.0.1",
+		"error":             true,
+		"error.count":       float64(1),
+		"entry.count":       float64(2),
+		"tags":              "audit",
+		"entry.0.level":     "Info",
+		"entry.0.message":   "Handled.",
+		"entry.0.data.user": float64(7),
+		"entry.0.data.took": 1.5,
+		"entry.1.level":     "Error",
+		"trace.trace_id":    "trace",
+		"trace.span_id":     "span",
+		"trace.parent_id":   "parent",
+	}
+	for k, v := range want {
+		if ev.Data[k] != v {
+			t.Errorf("%s is %#v, want %#v", k, ev.Data[k], v)
+		}
+	}
+}
+
+func TestSinkBatches(t *testing.T) {
+
+	a := &api{}
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	s := &Sink{URL: srv.URL, APIKey: "key", Dataset: "logs"}
+
+	// Five events of a little over a megabyte each fill a
+	// request, so the fifth is sent with the next.
+	big := logger.Thread{Kind: logger.KindSession, Route: strings.Repeat("x", maxBytes/5)}
+	for i := 0; i < 5; i++ {
+		if err := s.Hook(big); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.sizes(); len(got) != 2 || got[0] != 4 || got[1] != 1 {
+		t.Fatalf("API received batches of %v events, want [4 1]", got)
+	}
+}
+
+func TestSinkRejected(t *testing.T) {
+
+	a := &api{}
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	s := &Sink{URL: srv.URL, APIKey: "key", Dataset: "logs"}
+
+	rejected := logger.Thread{Kind: logger.KindSession}
+	rejected.Data("reject", true)
+	for _, th := range []logger.Thread{{Kind: logger.KindSession}, rejected} {
+		if err := s.Hook(th); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Flush()
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 events rejected: bad event") {
+		t.Fatalf("Flush returned %v, want the rejected event reported", err)
+	}
+
+	// Rejected events aren't resent with the next batch.
+	if err := s.Hook(logger.Thread{Kind: logger.KindSession}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.sizes(); len(got) != 2 || got[1] != 1 {
+		t.Fatalf("API received batches of %v events, want [2 1]", got)
+	}
+}
@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Humanize is how FormatPretty renders integer data values so
+large numbers can be read at a glance. Whatever the setting,
+other formats write numbers as they are.
+*/
+type Humanize int
+
+const (
+	// HumanizeNone renders numbers as they are, e.g.
+	// 1234567. It is the default.
+	HumanizeNone Humanize = iota
+
+	// HumanizeSeparators groups digits in thousands, e.g.
+	// 1,234,567.
+	HumanizeSeparators
+
+	// HumanizeCompact abbreviates numbers of a thousand or
+	// more with an SI suffix, e.g. 3.4k or 1.2M.
+	HumanizeCompact
+)
+
+var humanizeNames = map[Humanize]string{
+	HumanizeNone:       "none",
+	HumanizeSeparators: "separators",
+	HumanizeCompact:    "compact",
+}
+
+func (h Humanize) String() string {
+	if name, ok := humanizeNames[h]; ok {
+		return name
+	}
+	return fmt.Sprintf("Humanize(%d)", int(h))
+}
+
+/*
+ParseHumanize returns the Humanize named by s, ignoring case:
+none, separators or compact.
+*/
+func ParseHumanize(s string) (Humanize, error) {
+	for h, name := range humanizeNames {
+		if strings.EqualFold(s, name) {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("logger: unknown humanize setting %q", s)
+}
+
+/*
+SetHumanize sets how FormatPretty renders integer data
+values. With anything but HumanizeNone, values whose keys
+name a size in bytes, such as "bytes_in" or "body_size", are
+rendered in binary units, e.g. 1.2 MiB.
+*/
+func (l *Logger) SetHumanize(h Humanize) {
+	l.update("humanize", func(s *settings) { s.humanize = h })
+}
+
+/*
+value renders v, the value of the data key k, at h. It
+reports false if v isn't an integer or h is HumanizeNone.
+*/
+func (h Humanize) value(k string, v interface{}) (string, bool) {
+
+	if h == HumanizeNone {
+		return "", false
+	}
+
+	var n int64
+	var u uint64
+	var unsigned bool
+	switch v := v.(type) {
+	case int:
+		n = int64(v)
+	case int8:
+		n = int64(v)
+	case int16:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint:
+		u, unsigned = uint64(v), true
+	case uint8:
+		u, unsigned = uint64(v), true
+	case uint16:
+		u, unsigned = uint64(v), true
+	case uint32:
+		u, unsigned = uint64(v), true
+	case uint64:
+		u, unsigned = v, true
+	default:
+		return "", false
+	}
+
+	neg := !unsigned && n < 0
+	if !unsigned {
+		u = uint64(n)
+		if neg {
+			u = uint64(-n)
+		}
+	}
+
+	var s string
+	switch {
+	case isByteKey(k):
+		s = byteSize(u)
+	case h == HumanizeCompact:
+		s = compactCount(u)
+	default:
+		s = groupThousands(strconv.FormatUint(u, 10))
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s, true
+}
+
+/*
+isByteKey reports whether k names a size in bytes.
+*/
+func isByteKey(k string) bool {
+	k = strings.ToLower(k)
+	return strings.Contains(k, "bytes") || strings.HasSuffix(k, "size")
+}
+
+func byteSize(n uint64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	v := float64(n) / 1024
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", v, units[i])
+}
+
+func compactCount(n uint64) string {
+	if n < 1000 {
+		return strconv.FormatUint(n, 10)
+	}
+	suffixes := []string{"k", "M", "G", "T", "P", "E"}
+	v := float64(n) / 1000
+	i := 0
+	for v >= 999.5 && i < len(suffixes)-1 {
+		v /= 1000
+		i++
+	}
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	if v >= 100 {
+		s = strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strings.TrimSuffix(s, ".0") + suffixes[i]
+}
+
+func groupThousands(digits string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	lead := len(digits) % 3
+	if lead > 0 {
+		b.WriteString(digits[:lead])
+	}
+	for i := lead; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
@@ -0,0 +1,47 @@
+/*
+Package httpclient makes the HTTP clients of the sinks that
+have TLS options.
+*/
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Cache holds the client made for a sink's TLS options so its
+connections are reused. The zero value is ready to use.
+*/
+type Cache struct {
+	once   sync.Once
+	client *http.Client
+	err    error
+}
+
+/*
+Get returns client if it isn't nil. Otherwise it returns
+http.DefaultClient if o is nil or a client using o, which
+is only made the first time.
+*/
+func (c *Cache) Get(client *http.Client, o *logger.TLSOptions) (*http.Client, error) {
+	if client != nil {
+		return client, nil
+	}
+	if o == nil {
+		return http.DefaultClient, nil
+	}
+	c.once.Do(func() {
+		conf, err := o.Config()
+		if err != nil {
+			c.err = err
+			return
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = conf
+		c.client = &http.Client{Transport: t}
+	})
+	return c.client, c.err
+}
@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/testcert"
+)
+
+func TestCacheMutualTLS(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, cert, err := testcert.Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	var c Cache
+	opts := &logger.TLSOptions{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}
+	client, err := c.Get(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Get(nil, opts); again != client {
+		t.Fatal("Get made a second client")
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("server responded %s", resp.Status)
+	}
+
+	if _, err := http.DefaultClient.Get(srv.URL); err == nil {
+		t.Fatal("the default client was trusted without a client certificate")
+	}
+}
+
+func TestCacheDefaults(t *testing.T) {
+	var c Cache
+	if client, _ := c.Get(nil, nil); client != http.DefaultClient {
+		t.Fatal("Get didn't return http.DefaultClient without TLS options")
+	}
+	own := &http.Client{}
+	if client, _ := c.Get(own, &logger.TLSOptions{}); client != own {
+		t.Fatal("Get didn't return the sink's own client")
+	}
+	if _, err := new(Cache).Get(nil, &logger.TLSOptions{CertFile: "cert.pem"}); err == nil {
+		t.Fatal("Get accepted a certificate without a key")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Package msgpack appends MessagePack values to byte slices. It
+is shared by the logger's own encoding and the sinks that
+speak MessagePack, and each function uses the smallest
+format that fits its value.
+*/
+package msgpack
+
+import "math"
+
+/*
+AppendNil appends nil.
+*/
+func AppendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+/*
+AppendBool appends v.
+*/
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+/*
+AppendInt appends v, as a positive fixint or uint if
+it isn't negative.
+*/
+func AppendInt(b []byte, v int64) []byte {
+	if v >= 0 {
+		return AppendUint(b, uint64(v))
+	}
+	if v >= -32 {
+		return append(b, byte(v))
+	}
+	return appendBE(append(b, 0xd3), uint64(v), 8)
+}
+
+/*
+AppendUint appends v.
+*/
+func AppendUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return appendBE(append(b, 0xcd), v, 2)
+	case v <= math.MaxUint32:
+		return appendBE(append(b, 0xce), v, 4)
+	}
+	return appendBE(append(b, 0xcf), v, 8)
+}
+
+/*
+AppendFloat appends v as a float 64.
+*/
+func AppendFloat(b []byte, v float64) []byte {
+	return appendBE(append(b, 0xcb), math.Float64bits(v), 8)
+}
+
+/*
+AppendString appends s as a str.
+*/
+func AppendString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendBE(append(b, 0xda), uint64(n), 2)
+	default:
+		b = appendBE(append(b, 0xdb), uint64(n), 4)
+	}
+	return append(b, s...)
+}
+
+/*
+AppendBinary appends b as a bin.
+*/
+func AppendBinary(b []byte, data []byte) []byte {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendBE(append(b, 0xc5), uint64(n), 2)
+	default:
+		b = appendBE(append(b, 0xc6), uint64(n), 4)
+	}
+	return append(b, data...)
+}
+
+/*
+AppendArray appends the header of an array of n elements,
+which the caller appends after it.
+*/
+func AppendArray(b []byte, n int) []byte {
+	return appendHeader(b, n, 0x90, 0xdc, 0xdd)
+}
+
+/*
+AppendMap appends the header of a map of n pairs, which the
+caller appends after it as alternating keys and values.
+*/
+func AppendMap(b []byte, n int) []byte {
+	return appendHeader(b, n, 0x80, 0xde, 0xdf)
+}
+
+/*
+AppendExt appends data as an extension of type typ.
+*/
+func AppendExt(b []byte, typ int8, data []byte) []byte {
+	n := len(data)
+	switch n {
+	case 1:
+		b = append(b, 0xd4)
+	case 2:
+		b = append(b, 0xd5)
+	case 4:
+		b = append(b, 0xd6)
+	case 8:
+		b = append(b, 0xd7)
+	case 16:
+		b = append(b, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			b = appendBE(append(b, 0xc8), uint64(n), 2)
+		default:
+			b = appendBE(append(b, 0xc9), uint64(n), 4)
+		}
+	}
+	b = append(b, byte(typ))
+	return append(b, data...)
+}
+
+func appendHeader(b []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendBE(append(b, b16), uint64(n), 2)
+	}
+	return appendBE(append(b, b32), uint64(n), 4)
+}
+
+/*
+appendBE appends the low size bytes of v in big-endian
+order.
+*/
+func appendBE(b []byte, v uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*uint(i))))
+	}
+	return b
+}
@@ -0,0 +1,44 @@
+package msgpack
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"fixarray", AppendArray(nil, 15), []byte{0x9f}},
+		{"array16", AppendArray(nil, 16), []byte{0xdc, 0x00, 0x10}},
+		{"array16 max", AppendArray(nil, 65535), []byte{0xdc, 0xff, 0xff}},
+		{"array32", AppendArray(nil, 65536), []byte{0xdd, 0x00, 0x01, 0x00, 0x00}},
+		{"fixmap", AppendMap(nil, 15), []byte{0x8f}},
+		{"map16", AppendMap(nil, 16), []byte{0xde, 0x00, 0x10}},
+		{"map32", AppendMap(nil, 70000), []byte{0xdf, 0x00, 0x01, 0x11, 0x70}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s: got % x, want % x", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestAppendExt(t *testing.T) {
+	tests := []struct {
+		size int
+		want []byte
+	}{
+		{8, []byte{0xd7, 0x00}},
+		{3, []byte{0xc7, 0x03, 0x00}},
+		{300, []byte{0xc8, 0x01, 0x2c, 0x00}},
+	}
+	for _, tt := range tests {
+		got := AppendExt(nil, 0, make([]byte, tt.size))
+		if !bytes.HasPrefix(got, tt.want) || len(got) != len(tt.want)+tt.size {
+			t.Errorf("size %d: got % x..., want % x...", tt.size, got[:len(tt.want)], tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+FormatJSON formats t as one line of JSON for container log
+drivers and the agents that read them. The object has the
+time, a severity (DEBUG, INFO or ERROR, from the highest
+level entry) and a message summarising the thread, followed
+by the thread's fields and its entries as an array. Keys
+otherwise match MarshalMsgpack.
+*/
+func (t Thread) FormatJSON() string {
+	o := t.jsonHeader()
+	if len(t.Entries) > 0 {
+		var entries []json.RawMessage
+		for _, e := range t.Entries {
+			entries = append(entries, t.jsonEntry(e, false).close())
+		}
+		o.add("entries", entries)
+	}
+	return string(o.close()) + "\n"
+}
+
+/*
+FormatJSONEntries is like FormatJSON but writes the thread's
+entries as lines of their own after it. Each has the time
+and id of its thread so they can be grouped again, which
+suits viewers that show one line per message.
+*/
+func (t Thread) FormatJSONEntries() string {
+	var b bytes.Buffer
+	b.Write(t.jsonHeader().close())
+	b.WriteByte('\n')
+	for _, e := range t.Entries {
+		b.Write(t.jsonEntry(e, true).close())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (t Thread) jsonHeader() *jsonObject {
+
+	o := &jsonObject{}
+	o.add("time", t.Date.Format(time.RFC3339Nano))
+	o.add("severity", strings.ToUpper(t.level().String()))
+	o.add("message", t.summary())
+
+	o.addIf("kind", t.Kind.name != "", t.Kind.name)
+	o.addIf("id", t.Id != "", t.Id)
+	o.addIf("ip", t.Ip != "", t.Ip)
+	o.addIf("method", t.Method != "", t.Method)
+	o.addIf("route", t.Route != "", t.Route)
+	o.addIf("status", t.Status != 0, t.Status)
+	o.addIf("duration", t.Duration != 0, t.Duration)
+	o.addIf("redirect", t.Redirect != "", t.Redirect)
+	o.addIf("correlationId", t.CorrelationId != "", t.CorrelationId)
+	o.addIf("outcome", t.Outcome != "", t.Outcome)
+	o.addIf("chunk", t.Chunk != 0, t.Chunk)
+	o.addIf("unterminated", t.Unterminated, true)
+	o.addIf("timedOut", t.TimedOut, true)
+	if len(t.KeyVals) > 0 {
+		o.add("data", jsonKVs(t.KeyVals))
+	}
+	o.addIf("tags", len(t.Tags) > 0, t.Tags)
+	if len(t.Meta) > 0 {
+		meta := map[string]interface{}{}
+		for k, v := range t.Meta {
+			meta[string(k)] = jsonValue(v)
+		}
+		o.add("meta", meta)
+	}
+	if len(t.Phases) > 0 {
+		var phases []json.RawMessage
+		for _, p := range t.Phases {
+			po := &jsonObject{}
+			po.add("name", p.Name)
+			po.add("start", p.Start.Format(time.RFC3339Nano))
+			po.add("duration", int64(p.Duration))
+			phases = append(phases, po.close())
+		}
+		o.add("phases", phases)
+	}
+	return o
+}
+
+/*
+jsonEntry encodes e. Entries written on lines of their own
+carry the time and id of their thread as well.
+*/
+func (t Thread) jsonEntry(e *Entry, alone bool) *jsonObject {
+	o := &jsonObject{}
+	if alone {
+		o.add("time", t.Date.Format(time.RFC3339Nano))
+	}
+	o.add("severity", strings.ToUpper(e.Level.String()))
+	o.add("message", t.message(e))
+	if alone {
+		o.addIf("thread", t.Id != "", t.Id)
+	}
+	o.add("index", e.index)
+	o.addIf("seq", e.Seq != 0, e.Seq)
+	o.addIf("key", e.Key != "", e.Key)
+	o.addIf("function", e.Function != "", e.Function)
+	o.addIf("file", e.File != "", e.File)
+	o.addIf("line", e.Line != 0, e.Line)
+	if len(e.KeyVals) > 0 {
+		o.add("data", jsonKVs(e.KeyVals))
+	}
+	return o
+}
+
+/*
+summary describes t in a few words, e.g. "GET /users 200".
+*/
+func (t Thread) summary() string {
+	if t.Kind == KindRequest && t.Method != "" {
+		return fmt.Sprintf("%s %s %d", t.Method, t.Route, t.Status)
+	}
+	return t.Route
+}
+
+/*
+jsonObject writes the members of a JSON object in the order
+they're added.
+*/
+type jsonObject struct {
+	b bytes.Buffer
+}
+
+func (o *jsonObject) add(key string, v interface{}) {
+	if o.b.Len() == 0 {
+		o.b.WriteByte('{')
+	} else {
+		o.b.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	o.b.Write(k)
+	o.b.WriteByte(':')
+	val, err := json.Marshal(v)
+	if err != nil {
+		val, _ = json.Marshal(fmt.Sprint(v))
+	}
+	o.b.Write(val)
+}
+
+func (o *jsonObject) addIf(key string, present bool, v interface{}) {
+	if present {
+		o.add(key, v)
+	}
+}
+
+func (o *jsonObject) close() json.RawMessage {
+	if o.b.Len() == 0 {
+		return json.RawMessage("{}")
+	}
+	o.b.WriteByte('}')
+	return o.b.Bytes()
+}
+
+func jsonKVs(kvs []kv) json.RawMessage {
+	o := &jsonObject{}
+	for _, kv := range kvs {
+		o.add(kv.Key, jsonValue(kv.Val))
+	}
+	return o.close()
+}
+
+/*
+jsonValue returns v if it encodes as a plain JSON value,
+like msgpackEncoder.value, and a string describing it otherwise.
+*/
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return v
+	case float32:
+		return jsonValue(float64(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Sprint(v)
+		}
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+/*
+InContainer reports whether the process appears to be
+running in a container, i.e. under Kubernetes, Docker or
+Podman. FromEnv uses it to choose the default output.
+*/
+func InContainer() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, path := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
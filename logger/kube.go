@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+/*
+KubernetesInfo identifies the pod a process runs in so logs
+from a large cluster are attributable without relying on
+the collection agent to add it.
+*/
+type KubernetesInfo struct {
+	Pod       string
+	Namespace string
+	Node      string
+	Container string
+}
+
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+/*
+DefaultKubernetesInfo reads KubernetesInfo from the
+environment variables POD_NAME, POD_NAMESPACE, NODE_NAME and
+CONTAINER_NAME, which a pod spec can set from the downward
+API:
+
+	env:
+	- name: POD_NAME
+	  valueFrom: {fieldRef: {fieldPath: metadata.name}}
+	- name: POD_NAMESPACE
+	  valueFrom: {fieldRef: {fieldPath: metadata.namespace}}
+	- name: NODE_NAME
+	  valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+	- name: CONTAINER_NAME
+	  value: app
+
+Outside Kubernetes it returns a zero KubernetesInfo.
+Otherwise the pod defaults to the hostname and the namespace
+to that of the pod's service account.
+*/
+func DefaultKubernetesInfo() KubernetesInfo {
+	k := KubernetesInfo{
+		Pod:       os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		Node:      os.Getenv("NODE_NAME"),
+		Container: os.Getenv("CONTAINER_NAME"),
+	}
+	if k == (KubernetesInfo{}) && os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return k
+	}
+	if k.Pod == "" {
+		k.Pod, _ = os.Hostname()
+	}
+	if k.Namespace == "" {
+		if b, err := ioutil.ReadFile(namespaceFile); err == nil {
+			k.Namespace = strings.TrimSpace(string(b))
+		}
+	}
+	return k
+}
+
+/*
+SetKubernetesInfo stamps k onto every thread as the thread
+data "k8s.pod", "k8s.namespace", "k8s.node" and
+"k8s.container", omitting any that are empty. Passing a zero
+KubernetesInfo stops the stamping.
+*/
+func (l *Logger) SetKubernetesInfo(k KubernetesInfo) {
+	var fields []kv
+	add := func(k, v string) {
+		if v != "" {
+			fields = append(fields, kv{k, v})
+		}
+	}
+	add("k8s.pod", k.Pod)
+	add("k8s.namespace", k.Namespace)
+	add("k8s.node", k.Node)
+	add("k8s.container", k.Container)
+	l.update("kube", func(s *settings) { s.kube = fields })
+}
@@ -0,0 +1,156 @@
+/*
+Package lambda logs AWS Lambda invocations, opening a
+thread for each and writing it out before the handler
+returns since the runtime freezes the process afterwards.
+
+It doesn't import the AWS SDK. Handler has the same method
+as aws-lambda-go's lambda.Handler so a wrapped handler can
+be passed to lambda.StartHandler:
+
+	h := lambda.NewHandler(handle)
+	lambda.StartHandler(loglambda.Wrap(log, h, func(ctx context.Context) string {
+		lc, _ := lambdacontext.FromContext(ctx)
+		return lc.AwsRequestID
+	}))
+*/
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+type Handler interface {
+	Invoke(ctx context.Context, payload []byte) ([]byte, error)
+}
+
+var (
+	started = time.Now()
+
+	coldMu sync.Mutex
+	cold   = true
+)
+
+/*
+Wrap returns a Handler that opens a thread for every
+invocation of h and ends and flushes it before returning.
+The thread's id is the one returned by requestId, or a new
+one if requestId is nil or returns an empty string, and
+handlers can get it from their context with
+logger.RequestId. Its route is the function's name.
+
+The thread records whether the invocation was a cold start
+and, if so, the time since the process started. It also
+records the memory obtained from the OS by the Go runtime
+and the function's memory limit, both in MB, and the trace
+context from the X-Ray trace header if there is one. If h
+returns an error it is logged and the thread's outcome is
+failed, otherwise it is succeeded. Panics are logged and
+flushed before h panics again.
+*/
+func Wrap(l *logger.Logger, h Handler, requestId func(context.Context) string) Handler {
+	return &handler{logger: l, next: h, requestId: requestId}
+}
+
+type handler struct {
+	logger    *logger.Logger
+	next      Handler
+	requestId func(context.Context) string
+}
+
+func (h *handler) Invoke(ctx context.Context, payload []byte) (out []byte, err error) {
+
+	start := time.Now()
+	l := h.logger
+
+	var id string
+	if h.requestId != nil {
+		id = h.requestId(ctx)
+	}
+	if id == "" {
+		id = l.NewId()
+	}
+	l.Begin(id)
+	ctx = logger.WithRequestId(ctx, id)
+
+	coldMu.Lock()
+	coldStart := cold
+	cold = false
+	coldMu.Unlock()
+	l.ThreadData(id, "cold_start", coldStart)
+	if coldStart {
+		l.ThreadData(id, "init_ms", start.Sub(started).Milliseconds())
+	}
+	if tc, ok := xrayTrace(os.Getenv("_X_AMZN_TRACE_ID")); ok {
+		l.SetMeta(id, logger.MetaTrace, tc.Child())
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			l.Error(id, fmt.Sprintf("panic: %v", p))
+			h.end(id, logger.OutcomeFailed)
+			panic(p)
+		}
+	}()
+
+	out, err = h.next.Invoke(ctx, payload)
+	outcome := logger.OutcomeSucceeded
+	if err != nil {
+		l.Error(id, err.Error()).Err(err)
+		outcome = logger.OutcomeFailed
+	}
+	h.end(id, outcome)
+	return out, err
+}
+
+func (h *handler) end(id string, outcome logger.Outcome) {
+	l := h.logger
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	l.ThreadData(id, "memory_mb", m.Sys>>20)
+	if limit := os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"); limit != "" {
+		l.ThreadData(id, "memory_limit_mb", limit)
+	}
+	l.SetMeta(id, logger.MetaOutcome, outcome)
+	l.End(id, "", "", os.Getenv("AWS_LAMBDA_FUNCTION_NAME"), 0)
+	l.Flush()
+}
+
+/*
+xrayTrace reads the Root and Parent of an X-Ray trace
+header, e.g. Root=1-5759e988-bd862e3fe1be46a994272793;
+Parent=53995c3f42cd8ad8;Sampled=1.
+*/
+func xrayTrace(header string) (logger.TraceContext, bool) {
+	var tc logger.TraceContext
+	for _, field := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			parts := strings.Split(kv[1], "-")
+			if len(parts) != 3 || parts[0] != "1" {
+				return tc, false
+			}
+			tc.TraceId = strings.ToLower(parts[1] + parts[2])
+		case "Parent":
+			tc.SpanId = strings.ToLower(kv[1])
+		case "Sampled":
+			sampled := kv[1] == "1"
+			tc.Sampled = &sampled
+		}
+	}
+	if len(tc.TraceId) != 32 || len(tc.SpanId) != 16 {
+		return logger.TraceContext{}, false
+	}
+	return tc, true
+}
@@ -0,0 +1,184 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+type handlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+func (f handlerFunc) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return f(ctx, payload)
+}
+
+/*
+setenv sets the environment the Lambda runtime would and
+returns a function restoring it.
+*/
+func setenv(vars map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range vars {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+/*
+newLogger returns a logger and a function returning the
+threads it has ended so far.
+*/
+func newLogger() (*logger.Logger, func() []logger.Thread) {
+	var mu sync.Mutex
+	var threads []logger.Thread
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		mu.Lock()
+		threads = append(threads, t)
+		mu.Unlock()
+		return nil
+	}}
+	return l, func() []logger.Thread {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]logger.Thread(nil), threads...)
+	}
+}
+
+func data(t logger.Thread, k string) interface{} {
+	for _, kv := range t.KeyVals {
+		if kv.Key == k {
+			return kv.Val
+		}
+	}
+	return nil
+}
+
+func TestWrap(t *testing.T) {
+
+	defer setenv(map[string]string{
+		"AWS_LAMBDA_FUNCTION_NAME":        "orders",
+		"AWS_LAMBDA_FUNCTION_MEMORY_SIZE": "512",
+		"_X_AMZN_TRACE_ID":                "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+	})()
+	coldMu.Lock()
+	cold = true
+	coldMu.Unlock()
+
+	l, threads := newLogger()
+	var ids []string
+	h := Wrap(l, handlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		id := logger.RequestId(ctx)
+		ids = append(ids, id)
+		l.Info(id, "handling "+string(payload))
+		return []byte("ok"), nil
+	}), func(context.Context) string { return "aws-req-1" })
+
+	out, err := h.Invoke(context.Background(), []byte("order"))
+	if err != nil || string(out) != "ok" {
+		t.Fatalf("Invoke returned %q, %v", out, err)
+	}
+	if len(ids) != 1 || ids[0] != "aws-req-1" {
+		t.Fatalf("handler's context had request ids %v, want [aws-req-1]", ids)
+	}
+
+	// The thread has been written out by the time Invoke
+	// returns.
+	got := threads()
+	if len(got) != 1 {
+		t.Fatalf("logger ended %d threads, want 1", len(got))
+	}
+	th := got[0]
+	if th.Id != "aws-req-1" || th.Route != "orders" || th.Outcome != logger.OutcomeSucceeded ||
+		len(th.Entries) != 1 || th.Entries[0].Message != "Handling order." {
+		t.Fatalf("thread is %+v", th)
+	}
+	if data(th, "cold_start") != true || data(th, "init_ms") == nil ||
+		data(th, "memory_limit_mb") != "512" || data(th, "memory_mb") == nil {
+		t.Fatalf("thread data is %v", th.KeyVals)
+	}
+	tc, ok := th.Meta[logger.MetaTrace].(logger.TraceContext)
+	if !ok || tc.TraceId != "5759e988bd862e3fe1be46a994272793" || tc.ParentSpanId != "53995c3f42cd8ad8" ||
+		tc.Sampled == nil || !*tc.Sampled {
+		t.Fatalf("thread has trace context %+v", th.Meta[logger.MetaTrace])
+	}
+
+	if _, err := h.Invoke(context.Background(), []byte("order")); err != nil {
+		t.Fatal(err)
+	}
+	if th := threads()[1]; data(th, "cold_start") != false || data(th, "init_ms") != nil {
+		t.Fatalf("second invocation has thread data %v", th.KeyVals)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+
+	l, threads := newLogger()
+	fail := errors.New("no such order")
+	h := Wrap(l, handlerFunc(func(context.Context, []byte) ([]byte, error) {
+		return nil, fail
+	}), nil)
+
+	if _, err := h.Invoke(context.Background(), nil); err != fail {
+		t.Fatalf("Invoke returned %v, want the handler's error", err)
+	}
+	got := threads()
+	if len(got) != 1 || got[0].Id == "" || got[0].Outcome != logger.OutcomeFailed {
+		t.Fatalf("logger ended %+v", got)
+	}
+	if e := got[0].Entries; len(e) != 1 || e[0].Level != logger.LevelError {
+		t.Fatalf("thread has entries %+v, want the error", e)
+	}
+}
+
+func TestWrapPanic(t *testing.T) {
+
+	l, threads := newLogger()
+	h := Wrap(l, handlerFunc(func(context.Context, []byte) ([]byte, error) {
+		panic("nil map")
+	}), nil)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "nil map" {
+				t.Fatalf("recovered %v, want the handler's panic", p)
+			}
+		}()
+		h.Invoke(context.Background(), nil)
+	}()
+
+	got := threads()
+	if len(got) != 1 || got[0].Outcome != logger.OutcomeFailed ||
+		len(got[0].Entries) != 1 || got[0].Entries[0].Level != logger.LevelError {
+		t.Fatalf("logger ended %+v, want the panic logged before it continued", got)
+	}
+}
+
+func TestXrayTrace(t *testing.T) {
+	for header, ok := range map[string]bool{
+		"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0": true,
+		"Root=1-5759e988-bd862e3fe1be46a994272793":                                   false,
+		"Root=2-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8":           false,
+		"": false,
+	} {
+		if _, got := xrayTrace(header); got != ok {
+			t.Errorf("xrayTrace(%q) ok is %v, want %v", header, got, ok)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Level is the severity of an entry. Levels are ordered so
+they may be compared, e.g. e.Level >= LevelInfo.
+*/
+type Level int
+
+const (
+	LevelDebug Level = iota + 1
+	LevelInfo
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "Debug",
+	LevelInfo:  "Info",
+	LevelError: "Error",
+}
+
+func (lv Level) String() string {
+	if name, ok := levelNames[lv]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(lv))
+}
+
+/*
+ParseLevel returns the Level named by s, ignoring case.
+*/
+func ParseLevel(s string) (Level, error) {
+	for lv, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return lv, nil
+		}
+	}
+	return 0, fmt.Errorf("logger: unknown level %q", s)
+}
+
+/*
+MarshalText writes lv's name, or its number if it hasn't
+one, e.g. the zero Level, so encoding a thread never fails
+because of a level.
+*/
+func (lv Level) MarshalText() ([]byte, error) {
+	if name, ok := levelNames[lv]; ok {
+		return []byte(name), nil
+	}
+	return []byte(strconv.Itoa(int(lv))), nil
+}
+
+/*
+UnmarshalText reads a level written by MarshalText.
+*/
+func (lv *Level) UnmarshalText(text []byte) error {
+	parsed, err := ParseLevel(string(text))
+	if err != nil {
+		n, nerr := strconv.Atoi(string(text))
+		if nerr != nil {
+			return err
+		}
+		parsed = Level(n)
+	}
+	*lv = parsed
+	return nil
+}
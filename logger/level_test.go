@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLevelText(t *testing.T) {
+	for _, lv := range []Level{0, LevelDebug, LevelInfo, LevelError, 7, -1} {
+		b, err := json.Marshal(lv)
+		if err != nil {
+			t.Fatalf("marshalling %v: %s", lv, err)
+		}
+		var got Level
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("unmarshalling %s: %s", b, err)
+		}
+		if got != lv {
+			t.Errorf("%v became %s and then %v", lv, b, got)
+		}
+	}
+	var lv Level
+	if err := lv.UnmarshalText([]byte("loud")); err == nil {
+		t.Error("unmarshalled an unknown level name")
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Package log declares interfaces for the logger package so
+applications can depend on them rather than on *logger.Logger
+directly and swap in other implementations.
+*/
+package log
+
+import (
+	"net/http"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+type Entry interface {
+	Data(key string, val interface{}) Entry
+	Err(err error) Entry
+}
+
+type Session interface {
+	Log(level logger.Level, msg string) Entry
+	Info(msg string) Entry
+	Error(msg string) Entry
+	Debug(msg string) Entry
+	InfoF(format string, a ...interface{}) Entry
+	ErrorF(format string, a ...interface{}) Entry
+	DebugF(format string, a ...interface{}) Entry
+	InfoT(tmpl string, fields logger.Fields) Entry
+	ErrorT(tmpl string, fields logger.Fields) Entry
+	DebugT(tmpl string, fields logger.Fields) Entry
+	Sess(name string) Session
+	ThreadData(k string, v interface{})
+	Tag(tags ...string)
+	SeenError() bool
+	End() (logger.Thread, bool)
+}
+
+type Component interface {
+	Named(name string) Component
+	Log(level logger.Level, reqId, msg string) Entry
+	Info(reqId, msg string) Entry
+	Error(reqId, msg string) Entry
+	Debug(reqId, msg string) Entry
+	InfoF(reqId, format string, a ...interface{}) Entry
+	ErrorF(reqId, format string, a ...interface{}) Entry
+	DebugF(reqId, format string, a ...interface{}) Entry
+	InfoT(reqId, tmpl string, fields logger.Fields) Entry
+	ErrorT(reqId, tmpl string, fields logger.Fields) Entry
+	DebugT(reqId, tmpl string, fields logger.Fields) Entry
+}
+
+/*
+closer is implemented by Loggers that may hold threads back,
+e.g. in an asynchronous queue, until they are closed. Wrap's
+Loggers implement it.
+*/
+type closer interface {
+	Close()
+}
+
+type Logger interface {
+	NewId() string
+	Named(name string) Component
+	Sess(name string) Session
+	SessFrom(parentId, name string) Session
+	ThreadData(reqId, k string, v interface{})
+	Tag(reqId string, tags ...string)
+
+	Log(level logger.Level, reqId, msg string) Entry
+	Info(reqId, msg string) Entry
+	Error(reqId, msg string) Entry
+	Debug(reqId, msg string) Entry
+	InfoF(reqId, format string, a ...interface{}) Entry
+	ErrorF(reqId, format string, a ...interface{}) Entry
+	DebugF(reqId, format string, a ...interface{}) Entry
+	InfoT(reqId, tmpl string, fields logger.Fields) Entry
+	ErrorT(reqId, tmpl string, fields logger.Fields) Entry
+	DebugT(reqId, tmpl string, fields logger.Fields) Entry
+	ErrorMulti(reqId, msg, key string, errs []error) Entry
+
+	HttpStatus(reqId string, w logger.HeaderWriter, code int)
+	Status(reqId string, w logger.HeaderWriter, code int)
+	StatusE(reqId string, w logger.HeaderWriter, code int, msg string) Entry
+	Redirect(reqId string, w http.ResponseWriter, url string, code int)
+	BadRequest(reqId string, w logger.HeaderWriter, msg string) Entry
+	Unauthorised(reqId string, w logger.HeaderWriter) Entry
+	NotFound(reqId string, w logger.HeaderWriter) Entry
+	Forbidden(reqId string, w logger.HeaderWriter) Entry
+	Conflict(reqId string, w logger.HeaderWriter) Entry
+	UnprocessableEntity(reqId string, w logger.HeaderWriter) Entry
+	TooManyRequests(reqId string, w logger.HeaderWriter) Entry
+	InternalError(reqId string, w logger.HeaderWriter) Entry
+	ServiceUnavailable(reqId string, w logger.HeaderWriter) Entry
+
+	Once(msg string)
+	OnceF(format string, a ...interface{})
+	Fatal(err error)
+	Begin(reqId string)
+	End(reqId, ip, method, route string, duration int64) (logger.Thread, bool)
+}
@@ -0,0 +1,432 @@
+package log
+
+import (
+	"net/http"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Multi returns a Logger that forwards every call to each of
+loggers in order. Ids are generated by the first logger and
+used as-is by the rest. Status helpers only write the header
+using the first logger so it isn't written more than once.
+*/
+func Multi(loggers ...Logger) Logger {
+	if len(loggers) == 0 {
+		return Nop{}
+	}
+	return multi(loggers)
+}
+
+type multi []Logger
+
+type multiSession []Session
+
+type multiComponent []Component
+
+type multiEntry []Entry
+
+type discardHeader struct{}
+
+func (discardHeader) WriteHeader(int) {
+}
+
+type discardResponse struct {
+	discardHeader
+}
+
+func (discardResponse) Header() http.Header {
+	return http.Header{}
+}
+func (discardResponse) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (m multiEntry) Data(key string, val interface{}) Entry {
+	for _, e := range m {
+		e.Data(key, val)
+	}
+	return m
+}
+func (m multiEntry) Err(err error) Entry {
+	for _, e := range m {
+		e.Err(err)
+	}
+	return m
+}
+
+func (m multiSession) each(fn func(s Session) Entry) Entry {
+	ee := make(multiEntry, len(m))
+	for i, s := range m {
+		ee[i] = fn(s)
+	}
+	return ee
+}
+
+func (m multiSession) Log(level logger.Level, msg string) Entry {
+	return m.each(func(s Session) Entry { return s.Log(level, msg) })
+}
+func (m multiSession) Info(msg string) Entry {
+	return m.each(func(s Session) Entry { return s.Info(msg) })
+}
+func (m multiSession) Error(msg string) Entry {
+	return m.each(func(s Session) Entry { return s.Error(msg) })
+}
+func (m multiSession) Debug(msg string) Entry {
+	return m.each(func(s Session) Entry { return s.Debug(msg) })
+}
+func (m multiSession) InfoF(format string, a ...interface{}) Entry {
+	return m.each(func(s Session) Entry { return s.InfoF(format, a...) })
+}
+func (m multiSession) ErrorF(format string, a ...interface{}) Entry {
+	return m.each(func(s Session) Entry { return s.ErrorF(format, a...) })
+}
+func (m multiSession) DebugF(format string, a ...interface{}) Entry {
+	return m.each(func(s Session) Entry { return s.DebugF(format, a...) })
+}
+func (m multiSession) InfoT(tmpl string, fields logger.Fields) Entry {
+	return m.each(func(s Session) Entry { return s.InfoT(tmpl, fields) })
+}
+func (m multiSession) ErrorT(tmpl string, fields logger.Fields) Entry {
+	return m.each(func(s Session) Entry { return s.ErrorT(tmpl, fields) })
+}
+func (m multiSession) DebugT(tmpl string, fields logger.Fields) Entry {
+	return m.each(func(s Session) Entry { return s.DebugT(tmpl, fields) })
+}
+
+func (m multiSession) Sess(name string) Session {
+	ss := make(multiSession, len(m))
+	for i, s := range m {
+		ss[i] = s.Sess(name)
+	}
+	return ss
+}
+
+func (m multiSession) ThreadData(k string, v interface{}) {
+	for _, s := range m {
+		s.ThreadData(k, v)
+	}
+}
+
+func (m multiSession) Tag(tags ...string) {
+	for _, s := range m {
+		s.Tag(tags...)
+	}
+}
+
+/*
+SeenError reports whether any of the underlying sessions
+have seen an error.
+*/
+func (m multiSession) SeenError() bool {
+	for _, s := range m {
+		if s.SeenError() {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+End ends every session and returns the first one's thread.
+*/
+func (m multiSession) End() (logger.Thread, bool) {
+	var t logger.Thread
+	var open bool
+	for i, s := range m {
+		st, ok := s.End()
+		if i == 0 {
+			t, open = st, ok
+		}
+	}
+	return t, open
+}
+
+func (m multiComponent) each(fn func(c Component) Entry) Entry {
+	ee := make(multiEntry, len(m))
+	for i, c := range m {
+		ee[i] = fn(c)
+	}
+	return ee
+}
+
+func (m multiComponent) Named(name string) Component {
+	cc := make(multiComponent, len(m))
+	for i, c := range m {
+		cc[i] = c.Named(name)
+	}
+	return cc
+}
+func (m multiComponent) Log(level logger.Level, reqId, msg string) Entry {
+	return m.each(func(c Component) Entry { return c.Log(level, reqId, msg) })
+}
+func (m multiComponent) Info(reqId, msg string) Entry {
+	return m.each(func(c Component) Entry { return c.Info(reqId, msg) })
+}
+func (m multiComponent) Error(reqId, msg string) Entry {
+	return m.each(func(c Component) Entry { return c.Error(reqId, msg) })
+}
+func (m multiComponent) Debug(reqId, msg string) Entry {
+	return m.each(func(c Component) Entry { return c.Debug(reqId, msg) })
+}
+func (m multiComponent) InfoF(reqId, format string, a ...interface{}) Entry {
+	return m.each(func(c Component) Entry { return c.InfoF(reqId, format, a...) })
+}
+func (m multiComponent) ErrorF(reqId, format string, a ...interface{}) Entry {
+	return m.each(func(c Component) Entry { return c.ErrorF(reqId, format, a...) })
+}
+func (m multiComponent) DebugF(reqId, format string, a ...interface{}) Entry {
+	return m.each(func(c Component) Entry { return c.DebugF(reqId, format, a...) })
+}
+func (m multiComponent) InfoT(reqId, tmpl string, fields logger.Fields) Entry {
+	return m.each(func(c Component) Entry { return c.InfoT(reqId, tmpl, fields) })
+}
+func (m multiComponent) ErrorT(reqId, tmpl string, fields logger.Fields) Entry {
+	return m.each(func(c Component) Entry { return c.ErrorT(reqId, tmpl, fields) })
+}
+func (m multiComponent) DebugT(reqId, tmpl string, fields logger.Fields) Entry {
+	return m.each(func(c Component) Entry { return c.DebugT(reqId, tmpl, fields) })
+}
+
+func (m multi) each(fn func(l Logger) Entry) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = fn(l)
+	}
+	return ee
+}
+
+// header returns w for the first logger and a HeaderWriter
+// that discards the code for the others.
+func header(i int, w logger.HeaderWriter) logger.HeaderWriter {
+	if i == 0 {
+		return w
+	}
+	return discardHeader{}
+}
+
+func (m multi) NewId() string {
+	return m[0].NewId()
+}
+func (m multi) Named(name string) Component {
+	cc := make(multiComponent, len(m))
+	for i, l := range m {
+		cc[i] = l.Named(name)
+	}
+	return cc
+}
+func (m multi) Sess(name string) Session {
+	ss := make(multiSession, len(m))
+	for i, l := range m {
+		ss[i] = l.Sess(name)
+	}
+	return ss
+}
+
+func (m multi) SessFrom(parentId, name string) Session {
+	ss := make(multiSession, len(m))
+	for i, l := range m {
+		ss[i] = l.SessFrom(parentId, name)
+	}
+	return ss
+}
+
+func (m multi) ThreadData(reqId, k string, v interface{}) {
+	for _, l := range m {
+		l.ThreadData(reqId, k, v)
+	}
+}
+
+func (m multi) Tag(reqId string, tags ...string) {
+	for _, l := range m {
+		l.Tag(reqId, tags...)
+	}
+}
+
+func (m multi) Log(level logger.Level, reqId, msg string) Entry {
+	return m.each(func(l Logger) Entry { return l.Log(level, reqId, msg) })
+}
+func (m multi) Info(reqId, msg string) Entry {
+	return m.each(func(l Logger) Entry { return l.Info(reqId, msg) })
+}
+func (m multi) Error(reqId, msg string) Entry {
+	return m.each(func(l Logger) Entry { return l.Error(reqId, msg) })
+}
+func (m multi) Debug(reqId, msg string) Entry {
+	return m.each(func(l Logger) Entry { return l.Debug(reqId, msg) })
+}
+func (m multi) InfoF(reqId, format string, a ...interface{}) Entry {
+	return m.each(func(l Logger) Entry { return l.InfoF(reqId, format, a...) })
+}
+func (m multi) ErrorF(reqId, format string, a ...interface{}) Entry {
+	return m.each(func(l Logger) Entry { return l.ErrorF(reqId, format, a...) })
+}
+func (m multi) DebugF(reqId, format string, a ...interface{}) Entry {
+	return m.each(func(l Logger) Entry { return l.DebugF(reqId, format, a...) })
+}
+func (m multi) InfoT(reqId, tmpl string, fields logger.Fields) Entry {
+	return m.each(func(l Logger) Entry { return l.InfoT(reqId, tmpl, fields) })
+}
+func (m multi) ErrorT(reqId, tmpl string, fields logger.Fields) Entry {
+	return m.each(func(l Logger) Entry { return l.ErrorT(reqId, tmpl, fields) })
+}
+func (m multi) DebugT(reqId, tmpl string, fields logger.Fields) Entry {
+	return m.each(func(l Logger) Entry { return l.DebugT(reqId, tmpl, fields) })
+}
+func (m multi) ErrorMulti(reqId, msg, key string, errs []error) Entry {
+	return m.each(func(l Logger) Entry { return l.ErrorMulti(reqId, msg, key, errs) })
+}
+
+func (m multi) HttpStatus(reqId string, w logger.HeaderWriter, code int) {
+	for i, l := range m {
+		l.HttpStatus(reqId, header(i, w), code)
+	}
+}
+func (m multi) Status(reqId string, w logger.HeaderWriter, code int) {
+	for i, l := range m {
+		l.Status(reqId, header(i, w), code)
+	}
+}
+func (m multi) StatusE(reqId string, w logger.HeaderWriter, code int, msg string) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.StatusE(reqId, header(i, w), code, msg)
+	}
+	return ee
+}
+func (m multi) Redirect(reqId string, w http.ResponseWriter, url string, code int) {
+	for i, l := range m {
+		if i == 0 {
+			l.Redirect(reqId, w, url, code)
+			continue
+		}
+		l.Redirect(reqId, discardResponse{}, url, code)
+	}
+}
+func (m multi) BadRequest(reqId string, w logger.HeaderWriter, msg string) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.BadRequest(reqId, header(i, w), msg)
+	}
+	return ee
+}
+func (m multi) Unauthorised(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.Unauthorised(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) NotFound(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.NotFound(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) Forbidden(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.Forbidden(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) Conflict(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.Conflict(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) UnprocessableEntity(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.UnprocessableEntity(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) TooManyRequests(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.TooManyRequests(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) InternalError(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.InternalError(reqId, header(i, w))
+	}
+	return ee
+}
+func (m multi) ServiceUnavailable(reqId string, w logger.HeaderWriter) Entry {
+	ee := make(multiEntry, len(m))
+	for i, l := range m {
+		ee[i] = l.ServiceUnavailable(reqId, header(i, w))
+	}
+	return ee
+}
+
+func (m multi) Once(msg string) {
+	for _, l := range m {
+		l.Once(msg)
+	}
+}
+func (m multi) OnceF(format string, a ...interface{}) {
+	for _, l := range m {
+		l.OnceF(format, a...)
+	}
+}
+
+/*
+Fatal logs err to every logger but the last as a session,
+closing those that have a Close method so their threads are
+emitted, before the last logger's Fatal exits.
+*/
+func (m multi) Fatal(err error) {
+	last := len(m) - 1
+	for _, l := range m[:last] {
+		s := l.Sess("")
+		s.Error(err.Error())
+		s.End()
+		if c, ok := l.(closer); ok {
+			c.Close()
+		}
+	}
+	m[last].Fatal(err)
+}
+
+/*
+Close closes every logger that has a Close method.
+*/
+func (m multi) Close() {
+	for _, l := range m {
+		if c, ok := l.(closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// Begin opens reqId with every logger.
+func (m multi) Begin(reqId string) {
+	for _, l := range m {
+		l.Begin(reqId)
+	}
+}
+
+/*
+End ends reqId with every logger and returns the first
+logger's thread.
+*/
+func (m multi) End(reqId, ip, method, route string, duration int64) (logger.Thread, bool) {
+	var t logger.Thread
+	var open bool
+	for i, l := range m {
+		lt, ok := l.End(reqId, ip, method, route, duration)
+		if i == 0 {
+			t, open = lt, ok
+		}
+	}
+	return t, open
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+fatalRecorder records Fatal rather than exiting.
+*/
+type fatalRecorder struct {
+	Nop
+	fatal func(err error)
+}
+
+func (f fatalRecorder) Fatal(err error) {
+	f.fatal(err)
+}
+
+/*
+TestMultiFatal checks every logger has emitted the error by
+the time the last one's Fatal is called, even those that
+emit threads asynchronously.
+*/
+func TestMultiFatal(t *testing.T) {
+
+	var emitted []string
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		for _, e := range t.Entries {
+			emitted = append(emitted, e.Message)
+		}
+		return nil
+	}}
+	l.SetNormalise(false)
+	l.SetAsync(logger.AsyncOptions{QueueSize: 16})
+
+	called := false
+	m := Multi(Wrap(l), fatalRecorder{fatal: func(err error) {
+		called = true
+		if len(emitted) != 1 || emitted[0] != "boom" {
+			t.Errorf("first logger had emitted %q when the last exited", emitted)
+		}
+	}})
+	m.Fatal(errors.New("boom"))
+	if !called {
+		t.Fatal("last logger's Fatal wasn't called")
+	}
+}
@@ -0,0 +1,222 @@
+package log
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Nop is a Logger that discards everything without allocating.
+Status helpers still write their header to w, and Fatal
+still exits, so swapping in Nop doesn't change behaviour
+beyond the absence of logs.
+*/
+type Nop struct{}
+
+type nopSession struct{}
+
+type nopComponent struct{}
+
+type nopEntry struct{}
+
+func (nopEntry) Data(key string, val interface{}) Entry {
+	return nopEntry{}
+}
+func (nopEntry) Err(err error) Entry {
+	return nopEntry{}
+}
+
+func (nopSession) Log(level logger.Level, msg string) Entry {
+	return nopEntry{}
+}
+func (nopSession) Info(msg string) Entry {
+	return nopEntry{}
+}
+func (nopSession) Error(msg string) Entry {
+	return nopEntry{}
+}
+func (nopSession) Debug(msg string) Entry {
+	return nopEntry{}
+}
+func (nopSession) InfoF(format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (nopSession) ErrorF(format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (nopSession) DebugF(format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (nopSession) InfoT(tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (nopSession) ErrorT(tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (nopSession) DebugT(tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (nopSession) Sess(name string) Session {
+	return nopSession{}
+}
+func (nopSession) ThreadData(k string, v interface{}) {
+}
+func (nopSession) Tag(tags ...string) {
+}
+func (nopSession) SeenError() bool {
+	return false
+}
+func (nopSession) End() (logger.Thread, bool) {
+	return logger.Thread{}, false
+}
+
+func (nopComponent) Named(name string) Component {
+	return nopComponent{}
+}
+func (nopComponent) Log(level logger.Level, reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (nopComponent) Info(reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (nopComponent) Error(reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (nopComponent) Debug(reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (nopComponent) InfoF(reqId, format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (nopComponent) ErrorF(reqId, format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (nopComponent) DebugF(reqId, format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (nopComponent) InfoT(reqId, tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (nopComponent) ErrorT(reqId, tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (nopComponent) DebugT(reqId, tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+
+func (Nop) NewId() string {
+	return ""
+}
+func (Nop) Named(name string) Component {
+	return nopComponent{}
+}
+func (Nop) Sess(name string) Session {
+	return nopSession{}
+}
+func (Nop) SessFrom(parentId, name string) Session {
+	return nopSession{}
+}
+func (Nop) ThreadData(reqId, k string, v interface{}) {
+}
+func (Nop) Tag(reqId string, tags ...string) {
+}
+
+func (Nop) Log(level logger.Level, reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (Nop) Info(reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (Nop) Error(reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (Nop) Debug(reqId, msg string) Entry {
+	return nopEntry{}
+}
+func (Nop) InfoF(reqId, format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (Nop) ErrorF(reqId, format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (Nop) DebugF(reqId, format string, a ...interface{}) Entry {
+	return nopEntry{}
+}
+func (Nop) InfoT(reqId, tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (Nop) ErrorT(reqId, tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (Nop) DebugT(reqId, tmpl string, fields logger.Fields) Entry {
+	return nopEntry{}
+}
+func (Nop) ErrorMulti(reqId, msg, key string, errs []error) Entry {
+	return nopEntry{}
+}
+
+func (Nop) HttpStatus(reqId string, w logger.HeaderWriter, code int) {
+	w.WriteHeader(code)
+}
+func (Nop) Status(reqId string, w logger.HeaderWriter, code int) {
+	w.WriteHeader(code)
+}
+func (Nop) StatusE(reqId string, w logger.HeaderWriter, code int, msg string) Entry {
+	w.WriteHeader(code)
+	return nopEntry{}
+}
+func (Nop) Redirect(reqId string, w http.ResponseWriter, url string, code int) {
+	w.Header().Set("Location", url)
+	w.WriteHeader(code)
+}
+func (Nop) BadRequest(reqId string, w logger.HeaderWriter, msg string) Entry {
+	w.WriteHeader(400)
+	return nopEntry{}
+}
+func (Nop) Unauthorised(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(401)
+	return nopEntry{}
+}
+func (Nop) NotFound(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(404)
+	return nopEntry{}
+}
+func (Nop) Forbidden(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(403)
+	return nopEntry{}
+}
+func (Nop) Conflict(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(409)
+	return nopEntry{}
+}
+func (Nop) UnprocessableEntity(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(422)
+	return nopEntry{}
+}
+func (Nop) TooManyRequests(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(429)
+	return nopEntry{}
+}
+func (Nop) InternalError(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(500)
+	return nopEntry{}
+}
+func (Nop) ServiceUnavailable(reqId string, w logger.HeaderWriter) Entry {
+	w.WriteHeader(503)
+	return nopEntry{}
+}
+
+func (Nop) Once(msg string) {
+}
+func (Nop) OnceF(format string, a ...interface{}) {
+}
+func (Nop) Fatal(err error) {
+	os.Exit(1)
+}
+func (Nop) Begin(reqId string) {
+}
+func (Nop) End(reqId, ip, method, route string, duration int64) (logger.Thread, bool) {
+	return logger.Thread{}, false
+}
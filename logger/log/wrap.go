@@ -0,0 +1,247 @@
+package log
+
+import (
+	"net/http"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Wrap returns l as a Logger. Go doesn't allow *logger.Logger
+to satisfy Logger directly since its methods return concrete
+types, so Wrap adapts them without allocating.
+*/
+func Wrap(l *logger.Logger) Logger {
+	return wrapped{l}
+}
+
+/*
+WrapSession returns s as a Session.
+*/
+func WrapSession(s *logger.Session) Session {
+	return wrappedSession{s}
+}
+
+/*
+WrapEntry returns e as an Entry.
+*/
+func WrapEntry(e *logger.Entry) Entry {
+	return wrappedEntry{e}
+}
+
+type wrappedEntry struct {
+	e *logger.Entry
+}
+
+func (w wrappedEntry) Data(key string, val interface{}) Entry {
+	w.e.Data(key, val)
+	return w
+}
+func (w wrappedEntry) Err(err error) Entry {
+	w.e.Err(err)
+	return w
+}
+
+type wrappedSession struct {
+	s *logger.Session
+}
+
+func (w wrappedSession) Log(level logger.Level, msg string) Entry {
+	return wrappedEntry{w.s.Log(level, msg)}
+}
+func (w wrappedSession) Info(msg string) Entry {
+	return wrappedEntry{w.s.Info(msg)}
+}
+func (w wrappedSession) Error(msg string) Entry {
+	return wrappedEntry{w.s.Error(msg)}
+}
+func (w wrappedSession) Debug(msg string) Entry {
+	return wrappedEntry{w.s.Debug(msg)}
+}
+func (w wrappedSession) InfoF(format string, a ...interface{}) Entry {
+	return wrappedEntry{w.s.InfoF(format, a...)}
+}
+func (w wrappedSession) ErrorF(format string, a ...interface{}) Entry {
+	return wrappedEntry{w.s.ErrorF(format, a...)}
+}
+func (w wrappedSession) DebugF(format string, a ...interface{}) Entry {
+	return wrappedEntry{w.s.DebugF(format, a...)}
+}
+func (w wrappedSession) InfoT(tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.s.InfoT(tmpl, fields)}
+}
+func (w wrappedSession) ErrorT(tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.s.ErrorT(tmpl, fields)}
+}
+func (w wrappedSession) DebugT(tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.s.DebugT(tmpl, fields)}
+}
+func (w wrappedSession) Sess(name string) Session {
+	return wrappedSession{w.s.Sess(name)}
+}
+func (w wrappedSession) ThreadData(k string, v interface{}) {
+	w.s.ThreadData(k, v)
+}
+func (w wrappedSession) Tag(tags ...string) {
+	w.s.Tag(tags...)
+}
+func (w wrappedSession) SeenError() bool {
+	return w.s.SeenError()
+}
+func (w wrappedSession) End() (logger.Thread, bool) {
+	return w.s.End()
+}
+
+type wrappedComponent struct {
+	c *logger.Component
+}
+
+func (w wrappedComponent) Named(name string) Component {
+	return wrappedComponent{w.c.Named(name)}
+}
+func (w wrappedComponent) Log(level logger.Level, reqId, msg string) Entry {
+	return wrappedEntry{w.c.Log(level, reqId, msg)}
+}
+func (w wrappedComponent) Info(reqId, msg string) Entry {
+	return wrappedEntry{w.c.Info(reqId, msg)}
+}
+func (w wrappedComponent) Error(reqId, msg string) Entry {
+	return wrappedEntry{w.c.Error(reqId, msg)}
+}
+func (w wrappedComponent) Debug(reqId, msg string) Entry {
+	return wrappedEntry{w.c.Debug(reqId, msg)}
+}
+func (w wrappedComponent) InfoF(reqId, format string, a ...interface{}) Entry {
+	return wrappedEntry{w.c.InfoF(reqId, format, a...)}
+}
+func (w wrappedComponent) ErrorF(reqId, format string, a ...interface{}) Entry {
+	return wrappedEntry{w.c.ErrorF(reqId, format, a...)}
+}
+func (w wrappedComponent) DebugF(reqId, format string, a ...interface{}) Entry {
+	return wrappedEntry{w.c.DebugF(reqId, format, a...)}
+}
+func (w wrappedComponent) InfoT(reqId, tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.c.InfoT(reqId, tmpl, fields)}
+}
+func (w wrappedComponent) ErrorT(reqId, tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.c.ErrorT(reqId, tmpl, fields)}
+}
+func (w wrappedComponent) DebugT(reqId, tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.c.DebugT(reqId, tmpl, fields)}
+}
+
+type wrapped struct {
+	l *logger.Logger
+}
+
+func (w wrapped) NewId() string {
+	return w.l.NewId()
+}
+func (w wrapped) Named(name string) Component {
+	return wrappedComponent{w.l.Named(name)}
+}
+func (w wrapped) Sess(name string) Session {
+	return wrappedSession{w.l.Sess(name)}
+}
+func (w wrapped) SessFrom(parentId, name string) Session {
+	return wrappedSession{w.l.SessFrom(parentId, name)}
+}
+func (w wrapped) ThreadData(reqId, k string, v interface{}) {
+	w.l.ThreadData(reqId, k, v)
+}
+func (w wrapped) Tag(reqId string, tags ...string) {
+	w.l.Tag(reqId, tags...)
+}
+
+func (w wrapped) Log(level logger.Level, reqId, msg string) Entry {
+	return wrappedEntry{w.l.Log(level, reqId, msg)}
+}
+func (w wrapped) Info(reqId, msg string) Entry {
+	return wrappedEntry{w.l.Info(reqId, msg)}
+}
+func (w wrapped) Error(reqId, msg string) Entry {
+	return wrappedEntry{w.l.Error(reqId, msg)}
+}
+func (w wrapped) Debug(reqId, msg string) Entry {
+	return wrappedEntry{w.l.Debug(reqId, msg)}
+}
+func (w wrapped) InfoF(reqId, format string, a ...interface{}) Entry {
+	return wrappedEntry{w.l.InfoF(reqId, format, a...)}
+}
+func (w wrapped) ErrorF(reqId, format string, a ...interface{}) Entry {
+	return wrappedEntry{w.l.ErrorF(reqId, format, a...)}
+}
+func (w wrapped) DebugF(reqId, format string, a ...interface{}) Entry {
+	return wrappedEntry{w.l.DebugF(reqId, format, a...)}
+}
+func (w wrapped) InfoT(reqId, tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.l.InfoT(reqId, tmpl, fields)}
+}
+func (w wrapped) ErrorT(reqId, tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.l.ErrorT(reqId, tmpl, fields)}
+}
+func (w wrapped) DebugT(reqId, tmpl string, fields logger.Fields) Entry {
+	return wrappedEntry{w.l.DebugT(reqId, tmpl, fields)}
+}
+func (w wrapped) ErrorMulti(reqId, msg, key string, errs []error) Entry {
+	return wrappedEntry{w.l.ErrorMulti(reqId, msg, key, errs)}
+}
+
+func (w wrapped) HttpStatus(reqId string, hw logger.HeaderWriter, code int) {
+	w.l.HttpStatus(reqId, hw, code)
+}
+func (w wrapped) Status(reqId string, hw logger.HeaderWriter, code int) {
+	w.l.Status(reqId, hw, code)
+}
+func (w wrapped) StatusE(reqId string, hw logger.HeaderWriter, code int, msg string) Entry {
+	return wrappedEntry{w.l.StatusE(reqId, hw, code, msg)}
+}
+func (w wrapped) Redirect(reqId string, rw http.ResponseWriter, url string, code int) {
+	w.l.Redirect(reqId, rw, url, code)
+}
+func (w wrapped) BadRequest(reqId string, hw logger.HeaderWriter, msg string) Entry {
+	return wrappedEntry{w.l.BadRequest(reqId, hw, msg)}
+}
+func (w wrapped) Unauthorised(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.Unauthorised(reqId, hw)}
+}
+func (w wrapped) NotFound(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.NotFound(reqId, hw)}
+}
+func (w wrapped) Forbidden(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.Forbidden(reqId, hw)}
+}
+func (w wrapped) Conflict(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.Conflict(reqId, hw)}
+}
+func (w wrapped) UnprocessableEntity(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.UnprocessableEntity(reqId, hw)}
+}
+func (w wrapped) TooManyRequests(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.TooManyRequests(reqId, hw)}
+}
+func (w wrapped) InternalError(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.InternalError(reqId, hw)}
+}
+func (w wrapped) ServiceUnavailable(reqId string, hw logger.HeaderWriter) Entry {
+	return wrappedEntry{w.l.ServiceUnavailable(reqId, hw)}
+}
+
+func (w wrapped) Once(msg string) {
+	w.l.Once(msg)
+}
+func (w wrapped) OnceF(format string, a ...interface{}) {
+	w.l.OnceF(format, a...)
+}
+func (w wrapped) Fatal(err error) {
+	w.l.Fatal(err)
+}
+func (w wrapped) Close() {
+	w.l.Close()
+}
+func (w wrapped) Begin(reqId string) {
+	w.l.Begin(reqId)
+}
+func (w wrapped) End(reqId, ip, method, route string, duration int64) (logger.Thread, bool) {
+	return w.l.End(reqId, ip, method, route, duration)
+}
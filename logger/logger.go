@@ -2,37 +2,58 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 var (
-	levelInfo  = logLevel{"Info"}
-	levelError = logLevel{"Error"}
-	levelDebug = logLevel{"Debug"}
-
-	kindRequest = threadKind{"request"}
-	kindSession = threadKind{"session"}
+	KindRequest    = ThreadKind{"request"}
+	KindSession    = ThreadKind{"session"}
+	KindConnection = ThreadKind{"connection"}
+	KindCommand    = ThreadKind{"command"}
 )
 
-type logLevel struct {
+/*
+ThreadKind distinguishes requests, which have an HTTP status
+and duration, from sessions, which don't, connections to
+servers of other protocols, which have a duration but no
+status, and runs of command line programs, whose status is
+their exit status.
+*/
+type ThreadKind struct {
 	name string
 }
 
-func (ll logLevel) String() string {
-	return ll.name
+func (tk ThreadKind) String() string {
+	return tk.name
 }
 
-type threadKind struct {
-	name string
+func (tk ThreadKind) MarshalText() ([]byte, error) {
+	return []byte(tk.name), nil
 }
 
-func (tk threadKind) String() string {
-	return tk.name
+func (tk *ThreadKind) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case KindRequest.name:
+		*tk = KindRequest
+	case KindSession.name:
+		*tk = KindSession
+	case KindConnection.name:
+		*tk = KindConnection
+	case KindCommand.name:
+		*tk = KindCommand
+	default:
+		return fmt.Errorf("logger: unknown thread kind %q", text)
+	}
+	return nil
 }
 
 type HeaderWriter interface {
@@ -46,51 +67,235 @@ type kv struct {
 
 type Entry struct {
 	ThreadId string
-	Level    string
+	Level    Level
 	Function string
 	File     string
 	Message  string
+	Key      string
 	Line     int
 	KeyVals  []kv
+
+	// Time is when the entry was logged, as told by the
+	// logger's clock. See SetClock.
+	Time time.Time
+
+	// Seq is a sequence number shared by every thread of a
+	// logger and its clones, increasing with each entry, so
+	// gaps show where entries were lost in transport. Within
+	// a thread it increases in the order of Thread.Entries.
+	Seq uint64
+
+	buf    *buffer
+	logger *Logger
+	index  int
+	frozen bool
 }
 
+/*
+discard is returned in place of an entry that won't be
+logged, e.g. because its level is disabled. It is shared
+so suppressed calls don't allocate and Data ignores it.
+*/
+var discard = &Entry{}
+
 func (e *Entry) Data(k string, v interface{}) *Entry {
-	e.KeyVals = append(e.KeyVals, kv{k, v})
+	e.modify(func() {
+		added := kv{k, e.logger.redact(k, v)}
+		e.KeyVals = append(e.KeyVals, added)
+		e.logger.walData(e, "", added)
+	})
 	return e
 }
 
 func (e *Entry) DataMulti(kvs KeyValuer) *Entry {
-	for k, v, done := kvs.Next(); !done; {
-		e.KeyVals = append(e.KeyVals, kv{k, v})
-	}
+	e.modify(func() {
+		var added []kv
+		for {
+			k, v, done := kvs.Next()
+			if done {
+				break
+			}
+			added = append(added, kv{k, e.logger.redact(k, v)})
+		}
+		e.KeyVals = append(e.KeyVals, added...)
+		e.logger.walData(e, "", added...)
+	})
 	return e
 }
 
+/*
+modify calls fn while holding the lock of e's thread. Once
+the thread has ended its entries may be read by formatters
+at any time so fn isn't called and the misuse is reported
+to OnInternalError instead. It reports whether fn was called.
+*/
+func (e *Entry) modify(fn func()) bool {
+
+	if e == discard {
+		return false
+	}
+	if e.buf == nil {
+		fn()
+		return true
+	}
+
+	e.buf.mu.Lock()
+	closed, frozen := e.buf.closed, e.frozen
+	if !closed && !frozen {
+		fn()
+	}
+	e.buf.mu.Unlock()
+
+	switch {
+	case frozen:
+		e.logger.internalError(fmt.Errorf(
+			"logger: entry %q modified after its chunk of thread %s was emitted",
+			e.Message, e.ThreadId))
+	case closed:
+		e.logger.internalError(fmt.Errorf(
+			"logger: entry %q modified after thread %s ended",
+			e.Message, e.ThreadId))
+	}
+	return !closed && !frozen
+}
+
 type KeyValuer interface {
 	Next() (key string, val interface{}, done bool)
 }
 
+/*
+Caser changes the case of the first word of a message
+when it is normalised. It should only upper-case the first
+rune so words like "iOS" and "HTTP" keep their case. The
+Caser type from package golang.org/x/text/cases satisfies
+this interface but its titles lower-case the rest of the
+word unless they are given the NoLower option, e.g.
+cases.Title(language.Turkish, cases.NoLower).
+*/
+type Caser interface {
+	String(s string) string
+}
+
 type Logger struct {
-	OnLog     func(Thread)
-	OnError   func(Thread)
-	idCount   int64
-	debug     bool
-	runtime   bool
-	idCountMu sync.Mutex
-	debugMu   sync.Mutex
-	runtimeMu sync.Mutex
-	logs      sync.Map
+
+	// OnLog is passed every ended thread and OnError those
+	// with errors, containing only the error entries. If
+	// either returns an error the thread is passed again as
+	// set by SetDeliveryAttempts and if every attempt fails
+	// a *DeliveryError is passed to OnInternalError and
+	// written to any dead-letter file. See SetDeadLetter.
+	OnLog   func(Thread) error
+	OnError func(Thread) error
+
+	// Enrich is called with each ended thread before it is
+	// passed to OnError and OnLog so it can be augmented,
+	// e.g. by resolving Ip to a country with Thread.Data.
+	// It runs on the dispatcher's goroutine if the logger
+	// is asynchronous so slow lookups don't delay responses.
+	Enrich func(*Thread)
+
+	// OnInternalError is called when the logger itself
+	// encounters a problem, such as an entry being modified
+	// after its thread ended. If nil the error is written
+	// to stderr.
+	OnInternalError func(error)
+
+	idCount  int64
+	entrySeq uint64
+
+	// cfg holds the current *settings. See update.
+	cfg atomic.Value
+
+	async      *dispatcher
+	throttle   *throttle
+	progress   *progress
+	maxAgeStop chan struct{}
+	wal        *wal
+	deadLetter *os.File
+	sinks      []io.Closer
+	dropped    uint64
+	suppressed uint64
+	workers    []*WorkerPool
+	queues     map[string]func() (int, int)
+	queueStop  chan struct{}
+	idCountMu  sync.Mutex
+	seqMu      sync.Mutex
+	cfgMu      sync.Mutex
+	asyncMu    sync.Mutex
+	statsMu    sync.Mutex
+	walMu      sync.Mutex
+	deadMu     sync.Mutex
+	sinksMu    sync.Mutex
+	workersMu  sync.Mutex
+	queueMu    sync.Mutex
+	throttleMu sync.Mutex
+	progressMu sync.Mutex
+	maxAgeMu   sync.Mutex
+	threads    store
+	root       *Logger
+	parent     *Logger
+	overrides  []override
+	children   []*Logger
+	childrenMu sync.Mutex
+	fields     []kv
 }
 
 func (l *Logger) SetDebug(enabled bool) {
-	l.debugMu.Lock()
-	l.debug = enabled
-	l.debugMu.Unlock()
+	l.update("debug", func(s *settings) { s.debug = enabled })
+}
+
+/*
+SetLevel discards entries below lv, e.g. LevelError to log
+errors only. Debug entries additionally require SetDebug.
+*/
+func (l *Logger) SetLevel(lv Level) {
+	l.update("level", func(s *settings) { s.level = lv })
 }
+
 func (l *Logger) SetRuntime(enabled bool) {
-	l.runtimeMu.Lock()
-	l.runtime = enabled
-	l.runtimeMu.Unlock()
+	l.update("runtime", func(s *settings) { s.runtime = enabled })
+}
+
+/*
+SetNormalise controls whether messages are capitalised and
+given a trailing period. It is enabled by default.
+*/
+func (l *Logger) SetNormalise(enabled bool) {
+	l.update("noNormalise", func(s *settings) { s.noNormalise = !enabled })
+}
+
+/*
+SetCaser replaces the naive upper-casing of a message's
+first rune with c, which is passed the message's first
+word. Passing nil restores the default behaviour.
+*/
+func (l *Logger) SetCaser(c Caser) {
+	l.update("caser", func(s *settings) { s.caser = c })
+}
+
+/*
+SetCatalog sets the Catalog consulted by FormatPretty and
+FormatTerse. FormatRecord always writes the original message
+so stored logs keep stable keys.
+*/
+func (l *Logger) SetCatalog(c Catalog) {
+	l.update("catalog", func(s *settings) { s.catalog = c })
+}
+
+/*
+SetClock replaces time.Now as the source of thread dates,
+e.g. with a fixed time so output is deterministic in tests.
+Passing nil restores time.Now.
+*/
+func (l *Logger) SetClock(now func() time.Time) {
+	l.update("clock", func(s *settings) { s.clock = now })
+}
+
+func (l *Logger) now() time.Time {
+	if clock := l.settings().clock; clock != nil {
+		return clock()
+	}
+	return time.Now()
 }
 
 /*
@@ -100,6 +305,7 @@ ids, starting from 1.
 */
 
 func (l *Logger) NewId() string {
+	l = l.shared()
 	l.idCountMu.Lock()
 
 	// We defer to avoid idCount changing between
@@ -109,25 +315,139 @@ func (l *Logger) NewId() string {
 	return strconv.FormatInt(l.idCount, 10)
 }
 
+func (l *Logger) nextSeq() uint64 {
+	l = l.shared()
+	l.seqMu.Lock()
+	defer l.seqMu.Unlock()
+	l.entrySeq++
+	return l.entrySeq
+}
+
+/*
+Index returns the position of e in its thread, counting from
+zero and including any entries emitted in chunks before it.
+*/
+func (e *Entry) Index() int {
+	return e.index
+}
+
+/*
+ThreadData attaches k and v to the thread reqId itself rather
+than one of its entries, e.g. the id of the authenticated
+user. Formatters render them in the thread's header.
+*/
+func (l *Logger) ThreadData(reqId, k string, v interface{}) {
+	v = l.redact(k, v)
+	b := l.shared().threads.buffer(reqId)
+	b.mu.Lock()
+	b.data = append(b.data, kv{k, v})
+	b.mu.Unlock()
+}
+
+/*
+Tag adds tags to the thread reqId. Unlike ThreadData tags
+aren't rendered; they are for hooks and the logger itself
+to make decisions with, e.g. AsyncOptions.KeepTags.
+*/
+func (l *Logger) Tag(reqId string, tags ...string) {
+	b := l.shared().threads.buffer(reqId)
+	b.mu.Lock()
+	for _, tag := range tags {
+		if !hasTag(b.tags, tag) {
+			b.tags = append(b.tags, tag)
+		}
+	}
+	b.mu.Unlock()
+}
+
 func (l *Logger) HttpStatus(reqId string, w HeaderWriter, code int) {
 	l.logStatus(reqId, w, code)
 }
-func (l *Logger) Redirect(reqId string, code int) {
-	l.logs.Store(reqId+"_status", code)
+
+/*
+Redirect sets the Location header of w to url, writes code
+and records it as the request's status.
+*/
+func (l *Logger) Redirect(reqId string, w http.ResponseWriter, url string, code int) {
+	w.Header().Set("Location", url)
+	l.logStatus(reqId, w, code)
+	l.SetMeta(reqId, MetaRedirect, url)
 }
 func (l *Logger) BadRequest(reqId string, w HeaderWriter, msg string) *Entry {
 	l.logStatus(reqId, w, 400)
-	return l.logEntry(levelError, reqId, msg)
+	return l.logEntry(LevelError, reqId, msg)
+}
+func (l *Logger) Unauthorised(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 401)
+}
+func (l *Logger) NotFound(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 404)
+}
+func (l *Logger) Forbidden(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 403)
+}
+func (l *Logger) Conflict(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 409)
+}
+func (l *Logger) UnprocessableEntity(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 422)
+}
+func (l *Logger) TooManyRequests(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 429)
+}
+func (l *Logger) InternalError(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 500)
+}
+func (l *Logger) ServiceUnavailable(reqId string, w HeaderWriter) *Entry {
+	return l.statusEntry(reqId, w, 503)
+}
+
+/*
+Status writes code to w, records it as the request's status
+and logs an entry with the canonical text for code, such as
+"Not Found". Codes of 400 and above are logged as errors.
+*/
+func (l *Logger) Status(reqId string, w HeaderWriter, code int) {
+	l.logStatus(reqId, w, code)
+	l.logEntry(statusLevel(code), reqId, http.StatusText(code))
+}
+
+/*
+StatusE is like Status but logs msg instead, with the
+canonical text attached as data so context can be added to
+the returned entry.
+*/
+func (l *Logger) StatusE(reqId string, w HeaderWriter, code int, msg string) *Entry {
+	l.logStatus(reqId, w, code)
+	return l.logEntry(statusLevel(code), reqId, msg).
+		Data("status", fmt.Sprintf("%d %s", code, http.StatusText(code)))
 }
-func (l *Logger) Unauthorised(reqId string, w HeaderWriter) {
-	l.logStatus(reqId, w, 401)
+
+/*
+statusEntry is used by helpers like NotFound to write and
+record code and log its canonical text as an error, which
+callers can then add context to.
+*/
+func (l *Logger) statusEntry(reqId string, w HeaderWriter, code int) *Entry {
+	l.logStatus(reqId, w, code)
+	return l.logEntry(LevelError, reqId, http.StatusText(code))
 }
-func (l *Logger) NotFound(reqId string, w HeaderWriter) {
-	l.logStatus(reqId, w, 404)
+
+func statusLevel(code int) Level {
+	if code >= 400 {
+		return LevelError
+	}
+	return LevelInfo
 }
+
 func (l *Logger) logStatus(reqId string, w HeaderWriter, code int) {
 	w.WriteHeader(code)
-	l.logs.Store(reqId+"_status", code)
+	l.setStatus(reqId, code, false)
+}
+func (l *Logger) setStatus(reqId string, code int, onlyIfUnset bool) {
+	l.shared().threads.buffer(reqId).setStatus(code, onlyIfUnset, func() {
+		l.walStatus(reqId, code)
+	})
 }
 
 func (l *Logger) ErrorMulti(reqId, msg, key string, errs []error) *Entry {
@@ -140,7 +460,7 @@ func (l *Logger) ErrorMulti(reqId, msg, key string, errs []error) *Entry {
 		m[err.Error()]++
 	}
 
-	e := l.logEntry(levelError, reqId, msg)
+	e := l.logEntry(LevelError, reqId, msg)
 
 	for es, i := range m {
 		e.Data(key, fmt.Sprintf("(%d instances) %s", i, es))
@@ -151,72 +471,177 @@ func (l *Logger) ErrorMulti(reqId, msg, key string, errs []error) *Entry {
 
 func (l *Logger) Fatal(err error) {
 	id := l.NewId()
-	l.logEntry(levelError, id, err.Error())
-	l.end(kindSession, id, "", "", "", 0)
+	l.logEntry(LevelError, id, err.Error())
+	l.end(KindSession, id, "", "", "", 0)
+	l.Flush()
+	l.DumpOpen(os.Stderr)
 	os.Exit(1)
 }
 
 func (l *Logger) Once(msg string) {
 	id := l.NewId()
-	l.logEntry(levelInfo, id, msg)
-	l.end(kindSession, id, "", "", "", 0)
+	l.logEntry(LevelInfo, id, msg)
+	l.end(KindSession, id, "", "", "", 0)
 }
 func (l *Logger) OnceF(format string, a ...interface{}) {
 	id := l.NewId()
-	l.logEntry(levelInfo, id, fmt.Sprintf(format, a...))
-	l.end(kindSession, id, "", "", "", 0)
+	l.logEntry(LevelInfo, id, fmt.Sprintf(format, a...))
+	l.end(KindSession, id, "", "", "", 0)
+}
+
+/*
+Log logs msg at the given level. It is intended for
+wrappers and adapters that forward arbitrary levels.
+*/
+func (l *Logger) Log(level Level, reqId, msg string) *Entry {
+	return l.logEntry(level, reqId, msg)
 }
 
 func (l *Logger) Info(reqId, msg string) *Entry {
-	return l.logEntry(levelInfo, reqId, msg)
+	return l.logEntry(LevelInfo, reqId, msg)
 }
 func (l *Logger) Error(reqId, msg string) *Entry {
-	return l.logEntry(levelError, reqId, msg)
+	return l.logEntry(LevelError, reqId, msg)
 }
 func (l *Logger) Debug(reqId, msg string) *Entry {
-	return l.logEntry(levelDebug, reqId, msg)
+	return l.logEntry(LevelDebug, reqId, msg)
 }
 
 func (l *Logger) InfoF(reqId, format string, a ...interface{}) *Entry {
-	return l.logEntry(levelInfo, reqId, fmt.Sprintf(format, a...))
+	if !l.mayLog(LevelInfo) {
+		return discard
+	}
+	return l.logEntry(LevelInfo, reqId, fmt.Sprintf(format, a...))
 }
 func (l *Logger) ErrorF(reqId, format string, a ...interface{}) *Entry {
-	return l.logEntry(levelError, reqId, fmt.Sprintf(format, a...))
+	if !l.mayLog(LevelError) {
+		return discard
+	}
+	return l.logEntry(LevelError, reqId, fmt.Sprintf(format, a...))
 }
 func (l *Logger) DebugF(reqId, format string, a ...interface{}) *Entry {
-	return l.logEntry(levelDebug, reqId, fmt.Sprintf(format, a...))
+	if !l.mayLog(LevelDebug) {
+		return discard
+	}
+	return l.logEntry(LevelDebug, reqId, fmt.Sprintf(format, a...))
+}
+
+/*
+InfoT logs tmpl with each {key} replaced by the matching
+value in fields. The fields are also attached to the entry
+as data so messages stay consistent for grouping.
+*/
+func (l *Logger) InfoT(reqId, tmpl string, fields Fields) *Entry {
+	if !l.mayLog(LevelInfo) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, l.redactFields(fields))
+	return l.logEntry(LevelInfo, reqId, msg).template(tmpl, kvs)
+}
+func (l *Logger) ErrorT(reqId, tmpl string, fields Fields) *Entry {
+	if !l.mayLog(LevelError) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, l.redactFields(fields))
+	return l.logEntry(LevelError, reqId, msg).template(tmpl, kvs)
+}
+func (l *Logger) DebugT(reqId, tmpl string, fields Fields) *Entry {
+	if !l.mayLog(LevelDebug) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, l.redactFields(fields))
+	return l.logEntry(LevelDebug, reqId, msg).template(tmpl, kvs)
 }
 
-func (l *Logger) End(reqId, ip, method, route string, duration int64) {
-	l.end(kindRequest, reqId, ip, method, route, duration)
+/*
+Begin opens the thread reqId and starts timing it, so its
+duration is measured by the logger with the monotonic clock
+and a wall clock step can't make it negative or absurd. The
+duration passed to End is then ignored.
+*/
+func (l *Logger) Begin(reqId string) {
+	b := l.shared().threads.buffer(reqId)
+	b.mu.Lock()
+	b.begun = true
+	b.mu.Unlock()
 }
 
-func (l *Logger) logEntry(level logLevel, threadId, msg string) *Entry {
+/*
+End ends the request reqId and passes it to OnError and
+OnLog. It returns the thread and whether reqId was open, i.e.
+whether anything was logged to it.
 
-	// Capitalise msg and add a period at the end.
-	if !strings.HasSuffix(msg, ".") {
-		msg += "."
+If reqId wasn't opened with Begin the duration is used, unless
+it is zero or negative, in which case the thread is timed
+from when it was first logged to.
+*/
+func (l *Logger) End(reqId, ip, method, route string, duration int64) (Thread, bool) {
+	return l.end(KindRequest, reqId, ip, method, route, duration)
+}
+
+func (l *Logger) logEntry(level Level, threadId, msg string) *Entry {
+	return l.componentEntry("", level, threadId, msg)
+}
+
+/*
+componentEntry logs msg on behalf of component, which is
+found from the call site if it is empty and there are
+component levels to consult.
+*/
+func (l *Logger) componentEntry(component string, level Level, threadId, msg string) *Entry {
+
+	// Check before doing anything else so suppressed
+	// calls don't allocate.
+	var frame runtime.Frame
+	var haveFrame bool
+	if component == "" && l.components() != nil {
+		frame, haveFrame = caller()
+		component = framePackage(frame.Function)
 	}
-	for _, r := range msg {
-		msg = strings.ToUpper(string(r)) + msg[len(string(r)):]
-		break
+	var enabled bool
+	if component == "" {
+		enabled = l.enabled(level)
+	} else {
+		enabled = l.componentEnabled(component, level)
+	}
+	if !enabled && !(level == LevelDebug && l.threadDebug(threadId)) {
+		return discard
 	}
 
-	if level == levelDebug && !l.debug {
-		return &Entry{}
+	s := l.settings()
+	key := msg
+	if !s.noNormalise {
+		msg = s.normalise(msg)
 	}
 
-	e := &Entry{
-		ThreadId: threadId,
-		Level:    level.String(),
-		Message:  msg,
+	e := newEntry(s.pool)
+	e.logger = l
+	e.ThreadId = threadId
+	e.Level = level
+	e.Message = msg
+	e.Key = key
+	e.Time = l.now()
+
+	if s.runtime {
+		if !haveFrame {
+			frame, haveFrame = caller()
+		}
+		e.Function, e.File, e.Line = "Unknown", "Unable to obtain call site", 0
+		if haveFrame {
+			e.Function = frame.Function
+			if idx := strings.LastIndex(e.Function, "/"); idx != -1 {
+				e.Function = e.Function[idx+1:]
+			}
+			e.File = frame.File
+			e.Line = frame.Line
+		}
 	}
 
-	if l.runtime {
-		function, file, line := callSite()
-		e.Function = function
-		e.File = file
-		e.Line = line
+	if len(l.fields) > 0 {
+		e.KeyVals = append(e.KeyVals, l.fields...)
+	}
+	if s.profile {
+		e.KeyVals = append(e.KeyVals, kv{"goroutine", goroutineId()})
 	}
 
 	l.insertEntry(e)
@@ -224,89 +649,186 @@ func (l *Logger) logEntry(level logLevel, threadId, msg string) *Entry {
 	return e
 }
 
-func (l *Logger) insertEntry(e *Entry) {
-
-	entries, ok := l.logs.Load(e.ThreadId)
-	if !ok {
-		l.logs.Store(e.ThreadId, []*Entry{e})
+func (l *Logger) internalError(err error) {
+	if l.OnInternalError == nil {
+		fmt.Fprintln(os.Stderr, err)
 		return
 	}
+	l.OnInternalError(err)
+}
+
+func (l *Logger) enabled(level Level) bool {
+	s := l.settings()
+	return level >= s.level && (level != LevelDebug || s.debug)
+}
+
+// Capitalise msg and add a period at the end.
+func (s *settings) normalise(msg string) string {
+
+	if !strings.HasSuffix(msg, ".") {
+		msg += "."
+	}
+
+	if s.caser != nil {
+		end := strings.IndexFunc(msg, unicode.IsSpace)
+		if end == -1 {
+			end = len(msg)
+		}
+		return s.caser.String(msg[:end]) + msg[end:]
+	}
 
-	// We know the map only has this type as values.
-	ee := entries.([]*Entry)
-	ee = append(ee, e)
-	l.logs.Store(e.ThreadId, ee)
+	for _, r := range msg {
+		return strings.ToUpper(string(r)) + msg[len(string(r)):]
+	}
+	return msg
 }
 
-func (l *Logger) end(kind threadKind, threadId, ip, method, route string, duration int64) {
+func (l *Logger) insertEntry(e *Entry) {
+
+	// If the thread ends between getting its buffer and
+	// appending to it, the entry belongs to a new thread
+	// with the same id rather than being lost.
+	for !l.shared().threads.buffer(e.ThreadId).append(e, l.nextSeq, l.walEntry) {
+	}
+	l.entryProgress(e)
+	l.chunk(e)
+}
 
+/*
+end removes the thread threadId and dispatches it. It returns
+the thread and whether it was open.
+*/
+func (l *Logger) end(kind ThreadKind, threadId, ip, method, route string, duration int64) (Thread, bool) {
+
+	s := l.settings()
 	var ee []*Entry
-	entries, ok := l.logs.Load(threadId)
-	if ok {
-		l.logs.Delete(threadId)
-		ee = entries.([]*Entry)
+	var m meta
+	var data []kv
+	var tags []string
+	b := l.shared().threads.remove(threadId)
+	if b != nil {
+		ee, m, data, tags = b.close()
+		l.walEnd(threadId)
+		if b.begun || duration <= 0 {
+			duration = int64(time.Since(b.started))
+		}
+	}
+	now := l.now()
+	m.endPhase(now)
+
+	if stamped := s.stampedFields(); stamped != nil {
+		data = append(stamped[:len(stamped):len(stamped)], data...)
+	}
+
+	log := Thread{
+		Date:      now,
+		Id:        threadId,
+		Kind:      kind,
+		Ip:        ip,
+		Method:    method,
+		Route:     route,
+		Duration:  duration,
+		Entries:   ee,
+		Redirect:  m.redirect,
+		Meta:      m.values,
+		KeyVals:   data,
+		Tags:      tags,
+		Phases:    m.phases,
+		catalog:   s.catalog,
+		precision: s.precision,
+		humanize:  s.humanize,
+		glyphs:    s.glyphs,
+		pooled:    s.pool,
+
+		CorrelationId: m.correlation,
+		Outcome:       m.outcome,
+	}
+	if m.chunks > 0 {
+		log.Chunk = m.chunks + 1
+	}
+
+	if kind == KindRequest {
+		log.Status = m.status
+		if log.Status == 0 {
+			log.Status = 200
+		}
+	}
+	if kind == KindCommand {
+		log.Status = m.status
+	}
+
+	if s.snapshot && log.hasError() {
+		log.KeyVals = append(log.KeyVals, runtimeSnapshot()...)
 	}
 
 	// Unlike requests there's no value in logging a
 	// session with no entries because it doesn't have
-	// an overall HTTP status or duration to report.
-	if kind == kindSession && len(ee) == 0 {
-		return
+	// an overall HTTP status or duration to report,
+	// unless it recorded an outcome.
+	if kind == KindSession && len(ee) == 0 && m.chunks == 0 && m.outcome == "" {
+		return log, b != nil
 	}
 
-	log := Thread{
-		Date:     time.Now(),
-		Id:       threadId,
-		Kind:     kind,
-		Ip:       ip,
-		Method:   method,
-		Route:    route,
-		Duration: duration,
-		Entries:  ee,
+	// Pooled entries are reused once the thread has been
+	// emitted so the caller gets copies.
+	ended := log
+	if log.pooled {
+		ended.Entries = make([]*Entry, len(ee))
+		for i, e := range ee {
+			ended.Entries[i] = e.copy()
+		}
 	}
 
-	if kind == kindRequest {
-		log.Status = l.status(threadId)
+	if l.throttled(log) {
+		l.suppress(log)
+		return ended, b != nil
+	}
+	l.dispatch(log)
+	return ended, b != nil
+}
+
+/*
+emit passes t to the hooks. It is called from end unless
+the logger is asynchronous, in which case the dispatcher
+calls it.
+*/
+func (l *Logger) emit(t Thread) {
+
+	if l.Enrich != nil {
+		l.Enrich(&t)
 	}
 
 	if l.OnError != nil {
 		var errs []*Entry
-		for _, e := range ee {
-			if e.Level == levelError.String() {
+		for _, e := range t.Entries {
+			if e.Level == LevelError {
 				errs = append(errs, e)
 			}
 		}
 		if errs != nil {
-			log.Entries = errs
-			l.OnError(log)
+			errThread := t
+			errThread.Entries = errs
+			l.deliver("OnError", l.OnError, errThread)
 		}
 	}
 
-	if l.OnLog == nil {
-		return
+	if l.OnLog != nil {
+		l.deliver("OnLog", l.OnLog, t)
 	}
-	l.OnLog(log)
-}
 
-func (l *Logger) status(reqId string) (code int) {
-	status, ok := l.logs.Load(reqId + "_status")
-	if ok {
-		return status.(int)
+	if t.pooled {
+		recycle(t.Entries)
 	}
-	return 200
 }
 
-func callSite() (string, string, int) {
+// modulePath is used to skip frames belonging to this
+// package and its wrappers, such as package log.
+const modulePath = "github.com/jakebowkett/go-logger/logger"
 
-	pc, fn, ln, ok := runtime.Caller(3)
-	if !ok {
-		return "Unknown", "Unable to obtain call site", 0
+func inModule(function string) bool {
+	if !strings.HasPrefix(function, modulePath) {
+		return false
 	}
-
-	function := runtime.FuncForPC(pc).Name()
-	if idx := strings.LastIndex(function, "/"); idx != -1 {
-		function = function[idx+1 : len(function)]
-	}
-
-	return function, fn, ln
+	rest := function[len(modulePath):]
+	return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "/")
 }
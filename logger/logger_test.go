@@ -0,0 +1,68 @@
+package logger
+
+import "testing"
+
+/*
+suppressedCalls are calls at every level on a logger set
+above them all, which are meant to cost nothing.
+*/
+func suppressedCalls(l *Logger, id string) map[string]func() {
+	l.SetLevel(LevelError + 1)
+	s := l.Sess("session")
+	n := 1000
+	fields := Fields{"n": n}
+	return map[string]func(){
+		"Debug":          func() { l.Debug(id, "debug") },
+		"DebugF":         func() { l.DebugF(id, "n=%d", n) },
+		"DebugT":         func() { l.DebugT(id, "n={n}", fields) },
+		"InfoF":          func() { l.InfoF(id, "n=%d", n) },
+		"InfoT":          func() { l.InfoT(id, "n={n}", fields) },
+		"ErrorF":         func() { l.ErrorF(id, "n=%d", n) },
+		"ErrorT":         func() { l.ErrorT(id, "n={n}", fields) },
+		"Data":           func() { l.Debug(id, "debug").Data("k", "v") },
+		"Session.Debug":  func() { s.Debug("debug") },
+		"Session.InfoF":  func() { s.InfoF("n=%d", n) },
+		"Session.ErrorT": func() { s.ErrorT("n={n}", fields) },
+	}
+}
+
+func TestSuppressedAllocs(t *testing.T) {
+
+	l := &Logger{OnLog: func(Thread) error { return nil }}
+	id := l.NewId()
+	for name, fn := range suppressedCalls(l, id) {
+		if n := testing.AllocsPerRun(100, fn); n != 0 {
+			t.Errorf("%s: %v allocations, want 0", name, n)
+		}
+	}
+
+	// Component levels make every call find its package.
+	l.SetComponentLevels(map[string]Level{"other": LevelDebug})
+	fn := func() { l.Debug(id, "debug") }
+	if n := testing.AllocsPerRun(100, fn); n > 2 {
+		t.Errorf("with component levels: %v allocations, want at most 2", n)
+	}
+}
+
+func BenchmarkSuppressed(b *testing.B) {
+
+	l := &Logger{OnLog: func(Thread) error { return nil }}
+	id := l.NewId()
+	for name, fn := range suppressedCalls(l, id) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fn()
+			}
+		})
+	}
+
+	b.Run("ComponentLevels", func(b *testing.B) {
+		l.SetComponentLevels(map[string]Level{"other": LevelDebug})
+		defer l.SetComponentLevels(nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l.Debug(id, "debug")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package loggertest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+AssertLogged fails t unless an emitted entry at level has
+a message containing substr.
+*/
+func (l *Logger) AssertLogged(t testing.TB, level logger.Level, substr string) {
+	t.Helper()
+	for _, e := range l.Entries() {
+		if e.Level == level && strings.Contains(e.Message, substr) {
+			return
+		}
+	}
+	t.Errorf("loggertest: no %s entry containing %q was logged", level, substr)
+}
+
+/*
+AssertNotLogged fails t if an emitted entry at level has
+a message containing substr.
+*/
+func (l *Logger) AssertNotLogged(t testing.TB, level logger.Level, substr string) {
+	t.Helper()
+	for _, e := range l.Entries() {
+		if e.Level == level && strings.Contains(e.Message, substr) {
+			t.Errorf("loggertest: unexpected %s entry %q", level, e.Message)
+			return
+		}
+	}
+}
+
+/*
+AssertThreadStatus fails t unless the request thread reqId
+has ended with the HTTP status code.
+*/
+func (l *Logger) AssertThreadStatus(t testing.TB, reqId string, code int) {
+	t.Helper()
+	thread, ok := l.Thread(reqId)
+	if !ok {
+		t.Errorf("loggertest: thread %q has not ended", reqId)
+		return
+	}
+	if thread.Status != code {
+		t.Errorf("loggertest: thread %q has status %d, want %d", reqId, thread.Status, code)
+	}
+}
+
+/*
+AssertNoErrors fails t for every error entry emitted so far.
+*/
+func (l *Logger) AssertNoErrors(t testing.TB) {
+	t.Helper()
+	for _, e := range l.Entries() {
+		if e.Level == logger.LevelError {
+			t.Errorf("loggertest: unexpected error in thread %q: %s", e.ThreadId, e.Message)
+		}
+	}
+}
+
+/*
+Thread returns the emitted thread with id. If several have
+the same id the most recent is returned.
+*/
+func (l *Logger) Thread(id string) (logger.Thread, bool) {
+	threads := l.Threads()
+	for i := len(threads) - 1; i >= 0; i-- {
+		if threads[i].Id == id {
+			return threads[i], true
+		}
+	}
+	return logger.Thread{}, false
+}
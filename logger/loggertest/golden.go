@@ -0,0 +1,95 @@
+package loggertest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+FixedTime is the date given to threads by a Logger in
+deterministic mode.
+*/
+var FixedTime = time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+/*
+UpdateEnv is the environment variable that makes
+AssertGolden write its input to the golden file rather than
+compare against it. An environment variable is used instead
+of a flag since test packages commonly define -update
+themselves.
+*/
+const UpdateEnv = "LOGGERTEST_UPDATE"
+
+/*
+Deterministic makes the output of l's threads stable across
+runs: every thread is dated FixedTime and file paths are
+reduced to their base name. Ids are already sequential from
+1 for each Logger.
+*/
+func (l *Logger) Deterministic() {
+	l.SetClock(func() time.Time {
+		return FixedTime
+	})
+	l.mu.Lock()
+	l.stripPaths = true
+	l.mu.Unlock()
+}
+
+func stripPaths(t logger.Thread) logger.Thread {
+	ee := make([]*logger.Entry, len(t.Entries))
+	for i, e := range t.Entries {
+		stripped := *e
+		if stripped.File != "" {
+			stripped.File = filepath.Base(stripped.File)
+		}
+		ee[i] = &stripped
+	}
+	t.Entries = ee
+	return t
+}
+
+/*
+Render formats every thread emitted so far with format,
+which is typically a method value like logger.Thread.FormatPretty,
+and concatenates the results.
+*/
+func (l *Logger) Render(format func(logger.Thread) string) string {
+	var b bytes.Buffer
+	for _, t := range l.Threads() {
+		b.WriteString(format(t))
+	}
+	return b.String()
+}
+
+/*
+AssertGolden fails t unless got matches the contents of the
+file at path. If UpdateEnv is set the file is overwritten
+with got instead.
+*/
+func AssertGolden(t testing.TB, path, got string) {
+	t.Helper()
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("loggertest: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("loggertest: %s", err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("loggertest: %s (set %s=1 to create it)", err, UpdateEnv)
+	}
+	if string(want) != got {
+		t.Errorf("loggertest: output differs from %s\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package loggertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Serve passes r to h wrapped in l's Middleware and returns
+the recorded response along with the thread it produced.
+*/
+func (l *Logger) Serve(h http.Handler, r *http.Request) (*httptest.ResponseRecorder, logger.Thread) {
+	l.t.Helper()
+	w := httptest.NewRecorder()
+	l.Middleware(h).ServeHTTP(w, r)
+	l.mirror()
+	t, _ := l.LastThread()
+	return w, t
+}
+
+/*
+NewServer starts an httptest.Server that serves h wrapped in
+l's Middleware. The server is closed by Finish. Use
+LastThread or Threads to inspect what it logged.
+*/
+func (l *Logger) NewServer(h http.Handler) *httptest.Server {
+	srv := httptest.NewServer(l.Middleware(h))
+	l.mu.Lock()
+	l.servers = append(l.servers, srv)
+	l.mu.Unlock()
+	return srv
+}
+
+/*
+LastThread returns the most recently emitted thread.
+*/
+func (l *Logger) LastThread() (logger.Thread, bool) {
+	threads := l.Threads()
+	if len(threads) == 0 {
+		return logger.Thread{}, false
+	}
+	return threads[len(threads)-1], true
+}
@@ -0,0 +1,48 @@
+package loggertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+func TestServe(t *testing.T) {
+
+	l := New(t)
+	defer l.Finish()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Info(logger.RequestId(r.Context()), "teapot")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	w, thread := l.Serve(h, httptest.NewRequest("GET", "/tea", nil))
+	if w.Code != http.StatusTeapot {
+		t.Fatalf("responded %d, want 418", w.Code)
+	}
+	l.AssertThreadStatus(t, thread.Id, http.StatusTeapot)
+	l.AssertLogged(t, logger.LevelInfo, "eapot")
+}
+
+func TestNewServer(t *testing.T) {
+
+	l := New(t)
+	srv := l.NewServer(http.NotFoundHandler())
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	thread, ok := l.LastThread()
+	if !ok || thread.Status != http.StatusNotFound {
+		t.Fatalf("last thread is %+v, want one with status 404", thread)
+	}
+
+	l.Finish()
+	if res, err := http.Get(srv.URL); err == nil {
+		res.Body.Close()
+		t.Fatal("server is still running after Finish")
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Package loggertest provides a Logger for use in tests. It
+records every thread it emits so tests can assert on them
+and mirrors each thread to t.Log.
+*/
+package loggertest
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Logger wraps a *logger.Logger with debug and runtime info
+enabled. Threads are mirrored to t.Log by End, Once, OnceF
+and Session.End, which call t.Helper so the output points at
+the test rather than this package. Threads ended some other
+way are mirrored by Finish, which tests should defer:
+
+	l := loggertest.New(t)
+	defer l.Finish()
+*/
+type Logger struct {
+	*logger.Logger
+	t          testing.TB
+	mu         sync.Mutex
+	threads    []logger.Thread
+	mirrored   int
+	stripPaths bool
+	servers    []*httptest.Server
+}
+
+type Session struct {
+	*logger.Session
+	l *Logger
+}
+
+func New(t testing.TB) *Logger {
+	l := &Logger{
+		Logger: &logger.Logger{},
+		t:      t,
+	}
+	l.SetDebug(true)
+	l.SetRuntime(true)
+	l.OnLog = l.record
+	return l
+}
+
+/*
+Finish closes any servers started by NewServer and mirrors
+to t.Log the threads that haven't been yet.
+*/
+func (l *Logger) Finish() {
+	l.t.Helper()
+	l.mu.Lock()
+	servers := l.servers
+	l.servers = nil
+	l.mu.Unlock()
+	for _, srv := range servers {
+		srv.Close()
+	}
+	l.mirror()
+}
+
+func (l *Logger) record(t logger.Thread) error {
+	l.mu.Lock()
+	if l.stripPaths {
+		t = stripPaths(t)
+	}
+	l.threads = append(l.threads, t)
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *Logger) mirror() {
+	l.t.Helper()
+	l.mu.Lock()
+	pending := l.threads[l.mirrored:]
+	l.mirrored = len(l.threads)
+	l.mu.Unlock()
+	for _, t := range pending {
+		l.t.Log(strings.Trim(t.FormatPretty(), "\n"))
+	}
+}
+
+/*
+Threads returns every thread emitted so far in the order
+they ended.
+*/
+func (l *Logger) Threads() []logger.Thread {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	threads := make([]logger.Thread, len(l.threads))
+	copy(threads, l.threads)
+	return threads
+}
+
+/*
+Entries returns the entries of every emitted thread in the
+order they ended.
+*/
+func (l *Logger) Entries() []*logger.Entry {
+	var ee []*logger.Entry
+	for _, t := range l.Threads() {
+		ee = append(ee, t.Entries...)
+	}
+	return ee
+}
+
+/*
+Reset discards all recorded threads.
+*/
+func (l *Logger) Reset() {
+	l.mu.Lock()
+	l.threads = nil
+	l.mirrored = 0
+	l.mu.Unlock()
+}
+
+func (l *Logger) End(reqId, ip, method, route string, duration int64) (logger.Thread, bool) {
+	l.t.Helper()
+	t, open := l.Logger.End(reqId, ip, method, route, duration)
+	l.mirror()
+	return t, open
+}
+func (l *Logger) Once(msg string) {
+	l.t.Helper()
+	l.Logger.Once(msg)
+	l.mirror()
+}
+func (l *Logger) OnceF(format string, a ...interface{}) {
+	l.t.Helper()
+	l.Logger.OnceF(format, a...)
+	l.mirror()
+}
+
+func (l *Logger) Sess(name string) *Session {
+	return &Session{
+		Session: l.Logger.Sess(name),
+		l:       l,
+	}
+}
+
+func (s *Session) End() (logger.Thread, bool) {
+	s.l.t.Helper()
+	t, open := s.Session.End()
+	s.l.mirror()
+	return t, open
+}
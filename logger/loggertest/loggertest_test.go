@@ -0,0 +1,138 @@
+package loggertest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+fakeT records what the helpers report rather than failing
+the test running them.
+*/
+type fakeT struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, a ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, a...))
+}
+
+func (t *fakeT) Log(a ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprint(a...))
+}
+
+func TestMirror(t *testing.T) {
+
+	ft := &fakeT{TB: t}
+	l := New(ft)
+
+	id := l.NewId()
+	l.Info(id, "ended by the wrapper")
+	l.End(id, "", "GET", "/", 0)
+	if len(ft.logs) != 1 {
+		t.Fatalf("End mirrored %d threads, want 1", len(ft.logs))
+	}
+
+	id = l.NewId()
+	l.Info(id, "ended by the logger")
+	l.Logger.End(id, "", "GET", "/", 0)
+	if len(ft.logs) != 1 {
+		t.Fatalf("Logger.End mirrored a thread")
+	}
+	l.Finish()
+	if len(ft.logs) != 2 || !strings.Contains(ft.logs[1], "by the logger") {
+		t.Fatalf("Finish mirrored %q", ft.logs[1:])
+	}
+	l.Finish()
+	if len(ft.logs) != 2 {
+		t.Fatalf("Finish mirrored threads twice")
+	}
+
+	if n := len(l.Threads()); n != 2 {
+		t.Fatalf("recorded %d threads, want 2", n)
+	}
+	if n := len(l.Entries()); n != 2 {
+		t.Fatalf("recorded %d entries, want 2", n)
+	}
+	l.Reset()
+	if n := len(l.Threads()); n != 0 {
+		t.Fatalf("Reset left %d threads", n)
+	}
+}
+
+func TestAssert(t *testing.T) {
+
+	ft := &fakeT{TB: t}
+	l := New(ft)
+	defer l.Finish()
+
+	id := l.NewId()
+	l.Info(id, "hello world")
+	l.Status(id, httptest.NewRecorder(), 202)
+	l.End(id, "", "GET", "/", 0)
+
+	l.AssertLogged(ft, logger.LevelInfo, "world")
+	l.AssertNotLogged(ft, logger.LevelInfo, "goodbye")
+	l.AssertNoErrors(ft)
+	l.AssertThreadStatus(ft, id, 202)
+	if len(ft.errors) != 0 {
+		t.Fatalf("passing assertions reported %q", ft.errors)
+	}
+
+	l.AssertLogged(ft, logger.LevelError, "world")
+	l.AssertNotLogged(ft, logger.LevelInfo, "world")
+	l.AssertThreadStatus(ft, "missing", 200)
+	id = l.NewId()
+	l.Error(id, "broken")
+	l.End(id, "", "GET", "/", 0)
+	l.AssertNoErrors(ft)
+	if len(ft.errors) != 4 {
+		t.Fatalf("failing assertions reported %d errors, want 4: %q", len(ft.errors), ft.errors)
+	}
+}
+
+func TestGolden(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "loggertest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := New(t)
+	defer l.Finish()
+	l.Deterministic()
+	id := l.NewId()
+	l.Info(id, "hello")
+	l.End(id, "", "GET", "/", 0)
+	got := l.Render(logger.Thread.FormatPretty)
+	if !strings.Contains(got, "12:00PM") {
+		t.Fatalf("Deterministic output isn't dated FixedTime:\n%s", got)
+	}
+	if strings.Contains(got, string(filepath.Separator)+"loggertest_test.go") {
+		t.Fatalf("Deterministic output has full paths:\n%s", got)
+	}
+
+	path := filepath.Join(dir, "testdata", "hello.golden")
+	os.Setenv(UpdateEnv, "1")
+	AssertGolden(t, path, got)
+	os.Unsetenv(UpdateEnv)
+	AssertGolden(t, path, got)
+
+	ft := &fakeT{TB: t}
+	AssertGolden(ft, path, got+"changed")
+	if len(ft.errors) != 1 {
+		t.Fatalf("AssertGolden reported %d differences, want 1", len(ft.errors))
+	}
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"time"
+)
+
+/*
+SetMaxAge makes the logger force threads that have been open
+for longer than max to be emitted, marked TimedOut and
+Unterminated, so forgotten sessions and hung requests surface
+rather than sitting in memory. Their kind isn't known so they
+are emitted as sessions, and anything logged to them
+afterwards starts a new thread with the same id. Threads are
+checked periodically so they may be emitted up to a tenth of
+max late. Zero disables it.
+*/
+func (l *Logger) SetMaxAge(max time.Duration) {
+
+	var stop chan struct{}
+	if max > 0 {
+		stop = make(chan struct{})
+	}
+
+	l.maxAgeMu.Lock()
+	old := l.maxAgeStop
+	l.maxAgeStop = stop
+	l.maxAgeMu.Unlock()
+
+	if old != nil {
+		close(old)
+	}
+	if stop != nil {
+		go l.maxAgeLoop(max, stop)
+	}
+}
+
+func (l *Logger) maxAgeLoop(max time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(max / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			now := l.now()
+			l.shared().threads.each(func(id string, b *buffer) {
+				b.mu.Lock()
+				if b.opened.IsZero() {
+					b.opened = now
+				}
+				expired := !b.closed && now.Sub(b.opened) >= max
+				b.mu.Unlock()
+				if expired {
+					l.timeOut(id, b)
+				}
+			})
+		}
+	}
+}
+
+/*
+timeOut emits the thread id, whose buffer is b, if it is
+still open.
+*/
+func (l *Logger) timeOut(id string, b *buffer) {
+
+	threads := &l.shared().threads
+	if threads.lookup(id) != b || threads.remove(id) != b {
+		return
+	}
+
+	b.mu.Lock()
+	b.closed = true
+	t := l.snapshotThread(id, b, l.now())
+	b.mu.Unlock()
+	l.walEnd(id)
+
+	t.TimedOut = true
+	l.dispatch(t)
+}
@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"fmt"
+)
+
+/*
+MetaKey identifies a value stored against a thread as a
+whole rather than against one of its entries.
+*/
+type MetaKey string
+
+const (
+	// MetaStatus holds the int HTTP status of a request or
+	// the exit status of a command.
+	MetaStatus MetaKey = "status"
+
+	// MetaRedirect holds the string target of a redirect.
+	MetaRedirect MetaKey = "redirect"
+
+	// MetaCorrelation holds the string id shared by related
+	// threads. See SetCorrelationId.
+	MetaCorrelation MetaKey = "correlation"
+
+	// MetaTrace holds the TraceContext of a request that
+	// arrived with trace headers. Its span is the request's
+	// own, with the caller's as the parent.
+	MetaTrace MetaKey = "trace"
+
+	// MetaOutcome holds the Outcome of a thread. See
+	// Session.Fail and Session.Succeed.
+	MetaOutcome MetaKey = "outcome"
+
+	// MetaRoutePattern holds the string route pattern a
+	// request matched. See SetRoutePattern.
+	MetaRoutePattern MetaKey = "routePattern"
+)
+
+type meta struct {
+	status      int
+	redirect    string
+	correlation string
+	values      map[MetaKey]interface{}
+	phases      []Phase
+	phaseOpen   bool
+	chunks      int
+	outcome     Outcome
+}
+
+func (m *meta) set(key MetaKey, val interface{}) error {
+	switch key {
+	case MetaStatus:
+		code, ok := val.(int)
+		if !ok {
+			return fmt.Errorf("logger: %s must be an int, not %T", key, val)
+		}
+		m.status = code
+	case MetaRedirect:
+		url, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("logger: %s must be a string, not %T", key, val)
+		}
+		m.redirect = url
+	case MetaCorrelation:
+		id, ok := val.(string)
+		if !ok {
+			return fmt.Errorf("logger: %s must be a string, not %T", key, val)
+		}
+		m.correlation = id
+	case MetaOutcome:
+		outcome, ok := val.(Outcome)
+		if !ok {
+			return fmt.Errorf("logger: %s must be an Outcome, not %T", key, val)
+		}
+		m.outcome = outcome
+	default:
+		if m.values == nil {
+			m.values = map[MetaKey]interface{}{}
+		}
+		m.values[key] = val
+	}
+	return nil
+}
+
+func (m *meta) get(key MetaKey) (interface{}, bool) {
+	switch key {
+	case MetaStatus:
+		return m.status, m.status != 0
+	case MetaRedirect:
+		return m.redirect, m.redirect != ""
+	case MetaCorrelation:
+		return m.correlation, m.correlation != ""
+	case MetaOutcome:
+		return m.outcome, m.outcome != ""
+	}
+	val, ok := m.values[key]
+	return val, ok
+}
+
+/*
+SetMeta stores val against the thread threadId. Values for
+MetaStatus must be an int, those for MetaRedirect and
+MetaCorrelation must be strings and those for MetaOutcome
+must be an Outcome; others are reported to OnInternalError.
+Values for any other key are passed on in Thread.Meta.
+*/
+func (l *Logger) SetMeta(threadId string, key MetaKey, val interface{}) {
+	b := l.shared().threads.buffer(threadId)
+	b.mu.Lock()
+	err := b.meta.set(key, val)
+	if err == nil && key == MetaStatus {
+		l.walStatus(threadId, val.(int))
+	}
+	b.mu.Unlock()
+	if err != nil {
+		l.internalError(err)
+	}
+}
+
+/*
+Meta returns the value stored against threadId for key, if
+the thread is still open and has one.
+*/
+func (l *Logger) Meta(threadId string, key MetaKey) (interface{}, bool) {
+	b := l.shared().threads.lookup(threadId)
+	if b == nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.meta.get(key)
+}
+
+func copyMeta(values map[MetaKey]interface{}) map[MetaKey]interface{} {
+	if values == nil {
+		return nil
+	}
+	c := make(map[MetaKey]interface{}, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+)
+
+type ctxKey int
+
+const reqIdKey ctxKey = 0
+
+/*
+RequestId returns the id the middleware assigned to the
+request that ctx belongs to. It returns an empty string if
+ctx didn't come from a request passed through Middleware.
+*/
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(reqIdKey).(string)
+	return id
+}
+
+/*
+WithRequestId returns a copy of ctx from which RequestId
+returns id, for handlers that open threads without
+Middleware.
+*/
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, reqIdKey, id)
+}
+
+/*
+Middleware opens a request thread for every request, makes
+its id available to next via RequestId and ends the thread
+once next returns. If next writes a status directly rather
+than through a helper like NotFound it is still recorded.
+A B3 trace context in the request's headers, or failing that
+a Google Cloud one, is stored under MetaTrace and made
+available to next via Trace, and baggage entries named by
+SetBaggageKeys are attached as thread data. Debug entries
+are enabled for requests matching SetDebugRoutes and the
+goroutine is labelled if SetProfileLabels is enabled. The
+thread's route is the pattern the request matched if it is
+known; see SetRoutePatterns.
+*/
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs := l.BeginRequest(r)
+		sw := &statusWriter{ResponseWriter: w}
+		r = r.WithContext(rs.Context)
+		next.ServeHTTP(sw, r)
+		rs.End(l.routePattern(r), sw.code)
+	})
+}
+
+/*
+statusWriter records the status next responds with. It
+forwards the optional interfaces a ResponseWriter may have,
+returning an error from those the wrapped one lacks, so
+handlers can still flush, push, hijack the connection for
+e.g. websockets and copy with sendfile.
+*/
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("logger: ResponseWriter doesn't implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && sw.code == 0 {
+		sw.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (sw *statusWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := sw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (sw *statusWriter) ReadFrom(r io.Reader) (int64, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	if rf, ok := sw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(sw.ResponseWriter, r)
+}
+
+/*
+Recoverer recovers panics in next, logging the panic and its
+stack as an error on the request thread and responding with
+500 if nothing has been written yet. It should be wrapped by
+Middleware, which then ends the thread as usual; if it isn't
+it wraps itself.
+*/
+func (l *Logger) Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		reqId := RequestId(r.Context())
+		if reqId == "" {
+			l.Middleware(l.Recoverer(next)).ServeHTTP(w, r)
+			return
+		}
+
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+
+			// This is used to abort a response deliberately
+			// and net/http doesn't log it either.
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			l.Error(reqId, fmt.Sprintf("panic: %v", p)).
+				Data("stack", string(debug.Stack()))
+
+			if sw, ok := w.(*statusWriter); ok && sw.code != 0 {
+				return
+			}
+			l.HttpStatus(reqId, w, http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
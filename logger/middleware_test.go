@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/*
+TestMiddlewareHijack hijacks a connection through Middleware,
+as websocket handlers do, and responds on it directly.
+*/
+func TestMiddlewareHijack(t *testing.T) {
+
+	threads := make(chan Thread, 1)
+	l := &Logger{OnLog: func(t Thread) error {
+		threads <- t
+		return nil
+	}}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %s", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\nhello")
+		rw.Flush()
+	})
+	srv := httptest.NewServer(l.Middleware(h))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %d, want 101", res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("read %q from the hijacked connection", body)
+	}
+	if thread := <-threads; thread.Status != http.StatusSwitchingProtocols {
+		t.Fatalf("thread has status %d, want 101", thread.Status)
+	}
+}
+
+func TestMiddlewareOptionalInterfaces(t *testing.T) {
+
+	threads := make(chan Thread, 1)
+	l := &Logger{OnLog: func(t Thread) error {
+		threads <- t
+		return nil
+	}}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			t.Error("hijacked a ResponseRecorder")
+		}
+		if err := w.(http.Pusher).Push("/style.css", nil); err != http.ErrNotSupported {
+			t.Errorf("push returned %v, want http.ErrNotSupported", err)
+		}
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("body")); err != nil {
+			t.Errorf("ReadFrom: %s", err)
+		}
+	})
+	w := httptest.NewRecorder()
+	l.Middleware(h).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "body" {
+		t.Fatalf("wrote %q, want body", w.Body)
+	}
+	if thread := <-threads; thread.Status != http.StatusOK {
+		t.Fatalf("thread has status %d, want 200", thread.Status)
+	}
+}
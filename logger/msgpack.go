@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger/internal/msgpack"
+)
+
+/*
+MarshalMsgpack encodes t as a MessagePack map, which is more
+compact and quicker to parse than JSON. Keys match the
+field names of Thread in lower camel case and empty fields
+are omitted. Data is encoded as a map in the order it was
+logged. Values that are strings, numbers, booleans or nil
+keep their type, times become RFC 3339 strings and anything
+else is formatted with fmt.Sprint.
+*/
+func (t Thread) MarshalMsgpack() []byte {
+
+	var m msgpackEncoder
+	var n int
+	fields := []func(){}
+	add := func(key string, present bool, fn func()) {
+		if !present {
+			return
+		}
+		n++
+		fields = append(fields, func() {
+			m.string(key)
+			fn()
+		})
+	}
+
+	add("date", !t.Date.IsZero(), func() { m.int(t.Date.UnixNano()) })
+	add("kind", t.Kind.name != "", func() { m.string(t.Kind.name) })
+	add("id", t.Id != "", func() { m.string(t.Id) })
+	add("ip", t.Ip != "", func() { m.string(t.Ip) })
+	add("method", t.Method != "", func() { m.string(t.Method) })
+	add("route", t.Route != "", func() { m.string(t.Route) })
+	add("status", t.Status != 0, func() { m.int(int64(t.Status)) })
+	add("duration", t.Duration != 0, func() { m.int(t.Duration) })
+	add("redirect", t.Redirect != "", func() { m.string(t.Redirect) })
+	add("correlationId", t.CorrelationId != "", func() { m.string(t.CorrelationId) })
+	add("outcome", t.Outcome != "", func() { m.string(string(t.Outcome)) })
+	add("chunk", t.Chunk != 0, func() { m.int(int64(t.Chunk)) })
+	add("unterminated", t.Unterminated, func() { m.bool(true) })
+	add("timedOut", t.TimedOut, func() { m.bool(true) })
+	add("data", len(t.KeyVals) > 0, func() { m.kvs(t.KeyVals) })
+	add("tags", len(t.Tags) > 0, func() {
+		m.array(len(t.Tags))
+		for _, tag := range t.Tags {
+			m.string(tag)
+		}
+	})
+	add("meta", len(t.Meta) > 0, func() {
+		m.map_(len(t.Meta))
+		for k, v := range t.Meta {
+			m.string(string(k))
+			m.value(v)
+		}
+	})
+	add("phases", len(t.Phases) > 0, func() {
+		m.array(len(t.Phases))
+		for _, p := range t.Phases {
+			m.map_(3)
+			m.string("name")
+			m.string(p.Name)
+			m.string("start")
+			m.int(p.Start.UnixNano())
+			m.string("duration")
+			m.int(int64(p.Duration))
+		}
+	})
+	add("entries", len(t.Entries) > 0, func() {
+		m.array(len(t.Entries))
+		for _, e := range t.Entries {
+			m.entry(t.message(e), e)
+		}
+	})
+
+	m.map_(n)
+	for _, fn := range fields {
+		fn()
+	}
+	return m.b
+}
+
+type msgpackEncoder struct {
+	b []byte
+}
+
+func (m *msgpackEncoder) entry(msg string, e *Entry) {
+	n := 2
+	for _, present := range []bool{e.Key != "", e.Function != "", e.File != "", e.Line != 0, len(e.KeyVals) > 0, e.Seq != 0} {
+		if present {
+			n++
+		}
+	}
+	m.map_(n)
+	m.string("level")
+	m.string(e.Level.String())
+	m.string("message")
+	m.string(msg)
+	if e.Key != "" {
+		m.string("key")
+		m.string(e.Key)
+	}
+	if e.Function != "" {
+		m.string("function")
+		m.string(e.Function)
+	}
+	if e.File != "" {
+		m.string("file")
+		m.string(e.File)
+	}
+	if e.Line != 0 {
+		m.string("line")
+		m.int(int64(e.Line))
+	}
+	if len(e.KeyVals) > 0 {
+		m.string("data")
+		m.kvs(e.KeyVals)
+	}
+	if e.Seq != 0 {
+		m.string("seq")
+		m.uint(e.Seq)
+	}
+}
+
+func (m *msgpackEncoder) kvs(kvs []kv) {
+	m.map_(len(kvs))
+	for _, kv := range kvs {
+		m.string(kv.Key)
+		m.value(kv.Val)
+	}
+}
+
+func (m *msgpackEncoder) value(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		m.b = msgpack.AppendNil(m.b)
+	case bool:
+		m.bool(v)
+	case string:
+		m.string(v)
+	case int:
+		m.int(int64(v))
+	case int8:
+		m.int(int64(v))
+	case int16:
+		m.int(int64(v))
+	case int32:
+		m.int(int64(v))
+	case int64:
+		m.int(v)
+	case uint:
+		m.uint(uint64(v))
+	case uint8:
+		m.uint(uint64(v))
+	case uint16:
+		m.uint(uint64(v))
+	case uint32:
+		m.uint(uint64(v))
+	case uint64:
+		m.uint(v)
+	case float32:
+		m.float(float64(v))
+	case float64:
+		m.float(v)
+	case time.Time:
+		m.string(v.Format(time.RFC3339Nano))
+	case error:
+		m.string(v.Error())
+	default:
+		m.string(fmt.Sprint(v))
+	}
+}
+
+func (m *msgpackEncoder) bool(v bool)     { m.b = msgpack.AppendBool(m.b, v) }
+func (m *msgpackEncoder) int(v int64)     { m.b = msgpack.AppendInt(m.b, v) }
+func (m *msgpackEncoder) uint(v uint64)   { m.b = msgpack.AppendUint(m.b, v) }
+func (m *msgpackEncoder) float(v float64) { m.b = msgpack.AppendFloat(m.b, v) }
+func (m *msgpackEncoder) string(s string) { m.b = msgpack.AppendString(m.b, s) }
+func (m *msgpackEncoder) array(n int)     { m.b = msgpack.AppendArray(m.b, n) }
+func (m *msgpackEncoder) map_(n int)      { m.b = msgpack.AppendMap(m.b, n) }
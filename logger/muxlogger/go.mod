@@ -0,0 +1,10 @@
+module github.com/jakebowkett/go-logger/logger/muxlogger
+
+go 1.20
+
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/jakebowkett/go-logger/logger v0.0.0
+)
+
+replace github.com/jakebowkett/go-logger/logger => ../
@@ -0,0 +1,32 @@
+/*
+Package muxlogger records gorilla/mux requests as request
+threads of a logger.Logger, grouped by the route template
+they matched. It is a module of its own so the logger
+doesn't depend on gorilla/mux.
+*/
+package muxlogger
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Middleware is logger.Middleware for a gorilla/mux router.
+Add it with Use and each thread's route is the template the
+request matched, e.g. /users/{id}, rather than its path.
+*/
+func Middleware(l *logger.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					l.SetRoutePattern(logger.RequestId(r.Context()), tmpl)
+				}
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
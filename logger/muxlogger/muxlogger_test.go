@@ -0,0 +1,34 @@
+package muxlogger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	threads := make(chan logger.Thread, 1)
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		threads <- t
+		return nil
+	}}
+
+	r := mux.NewRouter()
+	r.Use(Middleware(l))
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("GET")
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	thread := <-threads
+	if thread.Route != "/users/{id}" {
+		t.Errorf("route is %q, want /users/{id}", thread.Route)
+	}
+	if thread.Status != http.StatusNoContent {
+		t.Errorf("status is %d, want 204", thread.Status)
+	}
+}
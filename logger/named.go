@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+)
+
+/*
+Component logs on behalf of a named part of an application,
+such as "db". Its entries carry the name under the key
+"component" and are filtered by SetComponentLevels using the
+name rather than the call site.
+*/
+type Component struct {
+	logger *Logger
+	name   string
+}
+
+/*
+Named returns a Component called name that logs to l.
+*/
+func (l *Logger) Named(name string) *Component {
+	return &Component{logger: l, name: name}
+}
+
+/*
+Named returns a Component nested within c, whose name is
+c's joined to name with a period, e.g. "db.pool". Component
+levels set for "db" also apply to "db.pool" unless it has
+its own.
+*/
+func (c *Component) Named(name string) *Component {
+	return &Component{logger: c.logger, name: c.name + "." + name}
+}
+
+func (c *Component) Name() string {
+	return c.name
+}
+
+func (c *Component) Log(level Level, reqId, msg string) *Entry {
+	return c.logEntry(level, reqId, msg)
+}
+func (c *Component) Info(reqId, msg string) *Entry {
+	return c.logEntry(LevelInfo, reqId, msg)
+}
+func (c *Component) Error(reqId, msg string) *Entry {
+	return c.logEntry(LevelError, reqId, msg)
+}
+func (c *Component) Debug(reqId, msg string) *Entry {
+	return c.logEntry(LevelDebug, reqId, msg)
+}
+
+func (c *Component) InfoF(reqId, format string, a ...interface{}) *Entry {
+	return c.logEntry(LevelInfo, reqId, fmt.Sprintf(format, a...))
+}
+func (c *Component) ErrorF(reqId, format string, a ...interface{}) *Entry {
+	return c.logEntry(LevelError, reqId, fmt.Sprintf(format, a...))
+}
+func (c *Component) DebugF(reqId, format string, a ...interface{}) *Entry {
+	if !c.logger.componentEnabled(c.name, LevelDebug) {
+		return discard
+	}
+	return c.logEntry(LevelDebug, reqId, fmt.Sprintf(format, a...))
+}
+
+func (c *Component) InfoT(reqId, tmpl string, fields Fields) *Entry {
+	msg, kvs := interpolate(tmpl, c.logger.redactFields(fields))
+	return c.logEntry(LevelInfo, reqId, msg).template(tmpl, kvs)
+}
+func (c *Component) ErrorT(reqId, tmpl string, fields Fields) *Entry {
+	msg, kvs := interpolate(tmpl, c.logger.redactFields(fields))
+	return c.logEntry(LevelError, reqId, msg).template(tmpl, kvs)
+}
+func (c *Component) DebugT(reqId, tmpl string, fields Fields) *Entry {
+	if !c.logger.componentEnabled(c.name, LevelDebug) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, c.logger.redactFields(fields))
+	return c.logEntry(LevelDebug, reqId, msg).template(tmpl, kvs)
+}
+
+func (c *Component) logEntry(level Level, reqId, msg string) *Entry {
+	return c.logger.componentEntry(c.name, level, reqId, msg).Data("component", c.name)
+}
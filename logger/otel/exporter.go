@@ -0,0 +1,122 @@
+package otel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/httpclient"
+)
+
+/*
+Exporter sends threads as spans to an OTLP/HTTP collector
+using the JSON encoding.
+*/
+type Exporter struct {
+
+	// Endpoint is the collector's traces URL, typically
+	// http://localhost:4318/v1/traces.
+	Endpoint string
+
+	// ServiceName is reported as the service.name resource
+	// attribute.
+	ServiceName string
+
+	// Client defaults to http.DefaultClient, or a client
+	// using TLS if it is set.
+	Client *http.Client
+
+	// TLS configures how the collector is verified, and
+	// any client certificate, when Client isn't set and
+	// Endpoint is https.
+	TLS *logger.TLSOptions
+
+	// Token is sent as a bearer token if it is set.
+	Token string
+
+	// Compression is the content encoding of requests, such
+	// as gzip or, once registered, zstd, or empty to send
+	// them as they are. See logger.RegisterCompression.
+	Compression string
+
+	clients httpclient.Cache
+}
+
+/*
+Export sends threads to the collector in a single request.
+*/
+func (e *Exporter) Export(threads ...logger.Thread) error {
+
+	spans := make([]Span, len(threads))
+	for i, t := range threads {
+		spans[i] = FromThread(t)
+	}
+
+	var res Resource
+	if e.ServiceName != "" {
+		res.Attributes = []KeyValue{attr("service.name", e.ServiceName)}
+	}
+
+	body, err := json.Marshal(TracesData{
+		ResourceSpans: []ResourceSpans{{
+			Resource: res,
+			ScopeSpans: []ScopeSpans{{
+				Scope: Scope{Name: "github.com/jakebowkett/go-logger/logger"},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	body, err = logger.Compress(e.Compression, body)
+	if err != nil {
+		return fmt.Errorf("otel: %v", strings.TrimPrefix(err.Error(), "logger: "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.Compression != "" {
+		req.Header.Set("Content-Encoding", e.Compression)
+	}
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otel: collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+/*
+Hook exports t. It has the signature of Logger.OnLog so it
+can be assigned to it, letting the logger retry failed
+exports.
+*/
+func (e *Exporter) Hook(t logger.Thread) error {
+	return e.Export(t)
+}
+
+func (e *Exporter) client() (*http.Client, error) {
+	c, err := e.clients.Get(e.Client, e.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("otel: %v", err)
+	}
+	return c, nil
+}
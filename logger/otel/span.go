@@ -0,0 +1,222 @@
+/*
+Package otel converts threads to OpenTelemetry spans in the
+OTLP/JSON encoding and exports them to an OTLP/HTTP collector
+so the same instrumentation feeds both logs and traces.
+*/
+package otel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+const (
+	spanKindServer   = 2
+	spanKindInternal = 1
+
+	statusUnset = 0
+	statusError = 2
+)
+
+type TracesData struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type ScopeSpans struct {
+	Scope Scope  `json:"scope"`
+	Spans []Span `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type Span struct {
+	TraceId           string     `json:"traceId"`
+	SpanId            string     `json:"spanId"`
+	ParentSpanId      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Events            []Event    `json:"events,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+type Event struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+}
+
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+/*
+FromThread converts t to a span. Requests become server spans
+and sessions internal ones. Entries become span events, at
+the time they were logged and with their key/vals as
+attributes, and the thread's own key/vals
+become span attributes. The span has an error status if the
+request failed with a 5xx status or any entry is an error.
+
+A request that arrived with a trace context continues that
+trace with the span Middleware derived for it, a child of
+the caller's and the parent of calls made through
+Transport; other threads start a new trace.
+*/
+func FromThread(t logger.Thread) Span {
+
+	end := t.Date
+	start := end.Add(-time.Duration(t.Duration))
+
+	s := Span{
+		TraceId:           randomHex(16),
+		SpanId:            randomHex(8),
+		Name:              spanName(t),
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: nanos(start),
+		EndTimeUnixNano:   nanos(end),
+	}
+	if tc, ok := t.Meta[logger.MetaTrace].(logger.TraceContext); ok {
+		s.TraceId = padTraceId(tc.TraceId)
+		s.SpanId = tc.SpanId
+		s.ParentSpanId = tc.ParentSpanId
+	}
+
+	if t.Kind == logger.KindRequest {
+		s.Kind = spanKindServer
+		s.Attributes = append(s.Attributes,
+			attr("http.request.method", t.Method),
+			attr("url.path", t.Route),
+			attr("http.response.status_code", t.Status),
+		)
+		if t.Ip != "" {
+			s.Attributes = append(s.Attributes, attr("client.address", t.Ip))
+		}
+		if t.Status >= 500 {
+			s.Status = Status{Code: statusError, Message: fmt.Sprintf("HTTP %d", t.Status)}
+		}
+	}
+	if t.CorrelationId != "" {
+		s.Attributes = append(s.Attributes, attr("correlation.id", t.CorrelationId))
+	}
+	for _, kv := range t.KeyVals {
+		s.Attributes = append(s.Attributes, attr(kv.Key, kv.Val))
+	}
+
+	for _, e := range t.Entries {
+		at := e.Time
+		if at.IsZero() {
+			at = end
+		}
+		ev := Event{
+			TimeUnixNano: nanos(at),
+			Name:         e.Message,
+			Attributes:   []KeyValue{attr("log.severity", e.Level.String())},
+		}
+		if e.File != "" {
+			ev.Attributes = append(ev.Attributes,
+				attr("code.filepath", e.File),
+				attr("code.lineno", e.Line),
+				attr("code.function", e.Function),
+			)
+		}
+		for _, kv := range e.KeyVals {
+			ev.Attributes = append(ev.Attributes, attr(kv.Key, kv.Val))
+		}
+		s.Events = append(s.Events, ev)
+
+		if e.Level == logger.LevelError && s.Status.Code == statusUnset {
+			s.Status = Status{Code: statusError, Message: e.Message}
+		}
+	}
+
+	return s
+}
+
+func spanName(t logger.Thread) string {
+	if t.Kind == logger.KindRequest {
+		return t.Method + " " + t.Route
+	}
+	if t.Route != "" {
+		return t.Route
+	}
+	return "session"
+}
+
+func attr(key string, val interface{}) KeyValue {
+	var v AnyValue
+	switch val := val.(type) {
+	case string:
+		v.StringValue = &val
+	case bool:
+		v.BoolValue = &val
+	case int:
+		i := strconv.Itoa(val)
+		v.IntValue = &i
+	case int64:
+		i := strconv.FormatInt(val, 10)
+		v.IntValue = &i
+	case float64:
+		v.DoubleValue = &val
+	case error:
+		s := val.Error()
+		v.StringValue = &s
+	default:
+		s := fmt.Sprint(val)
+		v.StringValue = &s
+	}
+	return KeyValue{Key: key, Value: v}
+}
+
+/*
+padTraceId widens 64-bit B3 trace ids to the 128 bits OTLP
+requires.
+*/
+func padTraceId(id string) string {
+	if len(id) == 16 {
+		return strings.Repeat("0", 16) + id
+	}
+	return id
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,98 @@
+package otel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+TestSpanParentage checks that a request's span is a child of
+its caller's and the parent of the calls it makes through
+Transport.
+*/
+func TestSpanParentage(t *testing.T) {
+
+	const traceId, callerSpan = "463ac35c9f6413ad48485a3953bb6124", "a2fb4a1d1a96d312"
+
+	outgoing := make(chan http.Header, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing <- r.Header
+	}))
+	defer upstream.Close()
+
+	threads := make(chan logger.Thread, 1)
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		threads <- t
+		return nil
+	}}
+	client := &http.Client{Transport: l.Transport(nil)}
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest("GET", upstream.URL, nil)
+		res, err := client.Do(req.WithContext(r.Context()))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res.Body.Close()
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-B3-TraceId", traceId)
+	r.Header.Set("X-B3-SpanId", callerSpan)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	span := FromThread(<-threads)
+	out := <-outgoing
+	if span.TraceId != traceId {
+		t.Errorf("span has trace id %s, want %s", span.TraceId, traceId)
+	}
+	if span.ParentSpanId != callerSpan {
+		t.Errorf("span's parent is %s, want the caller's span %s", span.ParentSpanId, callerSpan)
+	}
+	if span.SpanId == callerSpan {
+		t.Errorf("span has the caller's span id")
+	}
+	if got := out.Get("X-B3-ParentSpanId"); got != span.SpanId {
+		t.Errorf("outgoing request's parent is %s, want the server span %s", got, span.SpanId)
+	}
+	if got := out.Get("X-B3-SpanId"); got == span.SpanId || got == callerSpan {
+		t.Errorf("outgoing request reused span id %s", got)
+	}
+}
+
+func TestEventTimes(t *testing.T) {
+
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	threads := make(chan logger.Thread, 1)
+	l := &logger.Logger{OnLog: func(t logger.Thread) error {
+		threads <- t
+		return nil
+	}}
+	l.SetClock(func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	})
+
+	id := l.NewId()
+	l.Info(id, "first")
+	l.Info(id, "second")
+	l.End(id, "", "GET", "/", 0)
+	thread := <-threads
+
+	span := FromThread(thread)
+	if len(span.Events) != 2 {
+		t.Fatalf("span has %d events, want 2", len(span.Events))
+	}
+	for i, ev := range span.Events {
+		if want := nanos(thread.Entries[i].Time); ev.TimeUnixNano != want {
+			t.Errorf("event %d is at %s, want %s", i, ev.TimeUnixNano, want)
+		}
+	}
+	if span.Events[0].TimeUnixNano == span.Events[1].TimeUnixNano {
+		t.Errorf("events share the time %s", span.Events[0].TimeUnixNano)
+	}
+}
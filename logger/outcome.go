@@ -0,0 +1,40 @@
+package logger
+
+/*
+Outcome records how a thread finished, independently of
+what levels it logged at, so a job that logged errors but
+recovered can be told apart from one that failed.
+*/
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+)
+
+/*
+Fail records that s failed. If err isn't nil it is also
+logged as an error, which is returned. Calling Succeed later
+replaces the outcome.
+*/
+func (s *Session) Fail(err error) *Entry {
+	if s.ended {
+		return discard
+	}
+	s.logger.SetMeta(s.id, MetaOutcome, OutcomeFailed)
+	if err == nil {
+		return discard
+	}
+	return s.logger.logEntry(LevelError, s.id, err.Error()).Err(err)
+}
+
+/*
+Succeed records that s succeeded, even if it logged errors
+along the way.
+*/
+func (s *Session) Succeed() {
+	if s.ended {
+		return
+	}
+	s.logger.SetMeta(s.id, MetaOutcome, OutcomeSucceeded)
+}
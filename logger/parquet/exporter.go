@@ -0,0 +1,137 @@
+package parquet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Exporter accumulates ended threads and writes them to a new
+Parquet file in Dir whenever MaxRows is reached, Flush is
+called or, once started, periodically.
+*/
+type Exporter struct {
+
+	// Dir is where files are written. They are named by
+	// the time they were written, e.g.
+	// threads-20060102T150405.000000000Z.parquet.
+	Dir string
+
+	// MaxRows flushes once this many rows have accumulated.
+	// Zero leaves flushing to Flush and Start.
+	MaxRows int
+
+	// OnError is called when a periodic flush fails.
+	OnError func(error)
+
+	mu   sync.Mutex
+	rows []row
+	stop chan struct{}
+}
+
+/*
+Hook adds t to the next file. It has the signature of
+Logger.OnLog so it can be assigned to it.
+*/
+func (e *Exporter) Hook(t logger.Thread) error {
+	e.mu.Lock()
+	e.rows = append(e.rows, rows(t)...)
+	full := e.MaxRows > 0 && len(e.rows) >= e.MaxRows
+	e.mu.Unlock()
+	if full {
+		return e.Flush()
+	}
+	return nil
+}
+
+/*
+Flush writes the accumulated rows to a new file, unless
+there are none. The rows are kept if it fails.
+*/
+func (e *Exporter) Flush() error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.rows) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, e.rows); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see
+	// a partial one.
+	name := "threads-" + time.Now().UTC().Format("20060102T150405.000000000Z") + ".parquet"
+	tmp, err := ioutil.TempFile(e.Dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(e.Dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	e.rows = nil
+	return nil
+}
+
+/*
+Start flushes every interval until Close is called.
+*/
+func (e *Exporter) Start(every time.Duration) {
+
+	stop := make(chan struct{})
+	e.mu.Lock()
+	old := e.stop
+	e.stop = stop
+	e.mu.Unlock()
+	if old != nil {
+		close(old)
+	}
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := e.Flush(); err != nil && e.OnError != nil {
+					e.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+/*
+Close stops periodic flushing and flushes what remains.
+*/
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	stop := e.stop
+	e.stop = nil
+	e.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return e.Flush()
+}
@@ -0,0 +1,299 @@
+/*
+Package parquet writes threads to Parquet files with one row
+per entry and the thread's fields repeated on each, so logs
+can be queried with tools such as DuckDB or Athena. Threads
+without entries get a single row whose entry is -1. Data is
+stored as a JSON object of strings.
+
+Files are written uncompressed with the PLAIN encoding and a
+single row group, which every Parquet reader supports.
+*/
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+const (
+	typeInt32     = 1
+	typeInt64     = 2
+	typeByteArray = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	convertedUTF8 = 0
+
+	repetitionRequired = 0
+)
+
+type row struct {
+	date          int64
+	kind          string
+	threadId      string
+	ip            string
+	method        string
+	route         string
+	status        int32
+	duration      int64
+	correlationId string
+	outcome       string
+	threadData    string
+	tags          string
+	entry         int32
+	level         string
+	message       string
+	key           string
+	function      string
+	file          string
+	line          int32
+	data          string
+}
+
+type column struct {
+	name string
+	typ  int32
+	get  func(r *row) interface{}
+}
+
+var columns = []column{
+	{"date", typeInt64, func(r *row) interface{} { return r.date }},
+	{"kind", typeByteArray, func(r *row) interface{} { return r.kind }},
+	{"thread_id", typeByteArray, func(r *row) interface{} { return r.threadId }},
+	{"ip", typeByteArray, func(r *row) interface{} { return r.ip }},
+	{"method", typeByteArray, func(r *row) interface{} { return r.method }},
+	{"route", typeByteArray, func(r *row) interface{} { return r.route }},
+	{"status", typeInt32, func(r *row) interface{} { return r.status }},
+	{"duration", typeInt64, func(r *row) interface{} { return r.duration }},
+	{"correlation_id", typeByteArray, func(r *row) interface{} { return r.correlationId }},
+	{"outcome", typeByteArray, func(r *row) interface{} { return r.outcome }},
+	{"thread_data", typeByteArray, func(r *row) interface{} { return r.threadData }},
+	{"tags", typeByteArray, func(r *row) interface{} { return r.tags }},
+	{"entry", typeInt32, func(r *row) interface{} { return r.entry }},
+	{"level", typeByteArray, func(r *row) interface{} { return r.level }},
+	{"message", typeByteArray, func(r *row) interface{} { return r.message }},
+	{"key", typeByteArray, func(r *row) interface{} { return r.key }},
+	{"function", typeByteArray, func(r *row) interface{} { return r.function }},
+	{"file", typeByteArray, func(r *row) interface{} { return r.file }},
+	{"line", typeInt32, func(r *row) interface{} { return r.line }},
+	{"data", typeByteArray, func(r *row) interface{} { return r.data }},
+}
+
+/*
+rows flattens t into one row per entry.
+*/
+func rows(t logger.Thread) []row {
+
+	var date int64
+	if !t.Date.IsZero() {
+		date = t.Date.UnixNano()
+	}
+	base := row{
+		date:          date,
+		kind:          t.Kind.String(),
+		threadId:      t.Id,
+		ip:            t.Ip,
+		method:        t.Method,
+		route:         t.Route,
+		status:        int32(t.Status),
+		duration:      t.Duration,
+		correlationId: t.CorrelationId,
+		outcome:       string(t.Outcome),
+		threadData:    jsonObject(threadData(t)),
+		tags:          strings.Join(t.Tags, ","),
+		entry:         -1,
+		data:          "{}",
+	}
+	if len(t.Entries) == 0 {
+		return []row{base}
+	}
+
+	rr := make([]row, len(t.Entries))
+	for i, e := range t.Entries {
+		r := base
+		r.entry = int32(i)
+		r.level = e.Level.String()
+		r.message = e.Message
+		r.key = e.Key
+		r.function = e.Function
+		r.file = e.File
+		r.line = int32(e.Line)
+		r.data = jsonObject(entryData(e))
+		rr[i] = r
+	}
+	return rr
+}
+
+func threadData(t logger.Thread) [][2]string {
+	var pairs [][2]string
+	for _, kv := range t.KeyVals {
+		pairs = append(pairs, [2]string{kv.Key, fmt.Sprint(kv.Val)})
+	}
+	return pairs
+}
+
+func entryData(e *logger.Entry) [][2]string {
+	var pairs [][2]string
+	for _, kv := range e.KeyVals {
+		pairs = append(pairs, [2]string{kv.Key, fmt.Sprint(kv.Val)})
+	}
+	return pairs
+}
+
+/*
+jsonObject encodes pairs as a JSON object in the order they
+were logged.
+*/
+func jsonObject(pairs [][2]string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(p[0])
+		v, _ := json.Marshal(p[1])
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+/*
+Write writes threads to w as a Parquet file.
+*/
+func Write(w io.Writer, threads ...logger.Thread) error {
+	var rr []row
+	for _, t := range threads {
+		rr = append(rr, rows(t)...)
+	}
+	return write(w, rr)
+}
+
+func write(w io.Writer, rr []row) error {
+
+	out := []byte("PAR1")
+	offsets := make([]int64, len(columns))
+	sizes := make([]int64, len(columns))
+
+	for i, col := range columns {
+
+		var data []byte
+		for j := range rr {
+			switch v := col.get(&rr[j]).(type) {
+			case int32:
+				data = appendUint32(data, uint32(v))
+			case int64:
+				data = appendUint64(data, uint64(v))
+			case string:
+				data = appendUint32(data, uint32(len(v)))
+				data = append(data, v...)
+			}
+		}
+
+		var h compact
+		h.begin()
+		h.i32(1, 0) // DATA_PAGE
+		h.i32(2, int32(len(data)))
+		h.i32(3, int32(len(data)))
+		h.structField(5)
+		h.i32(1, int32(len(rr)))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+
+		offsets[i] = int64(len(out))
+		sizes[i] = int64(len(h.b) + len(data))
+		out = append(out, h.b...)
+		out = append(out, data...)
+	}
+
+	meta := fileMetaData(len(rr), offsets, sizes)
+	out = append(out, meta...)
+	out = appendUint32(out, uint32(len(meta)))
+	out = append(out, "PAR1"...)
+
+	_, err := w.Write(out)
+	return err
+}
+
+func fileMetaData(numRows int, offsets, sizes []int64) []byte {
+
+	var c compact
+	c.begin()
+	c.i32(1, 1)
+
+	c.list(2, thriftStruct, len(columns)+1)
+	c.begin()
+	c.string(4, "thread")
+	c.i32(5, int32(len(columns)))
+	c.end()
+	for _, col := range columns {
+		c.begin()
+		c.i32(1, col.typ)
+		c.i32(3, repetitionRequired)
+		c.string(4, col.name)
+		if col.typ == typeByteArray {
+			c.i32(6, convertedUTF8)
+		}
+		c.end()
+	}
+
+	c.i64(3, int64(numRows))
+
+	var total int64
+	for _, s := range sizes {
+		total += s
+	}
+	c.list(4, thriftStruct, 1)
+	c.begin()
+	c.list(1, thriftStruct, len(columns))
+	for i, col := range columns {
+		c.begin()
+		c.i64(2, offsets[i])
+		c.structField(3)
+		c.i32(1, col.typ)
+		c.list(2, thriftI32, 2)
+		c.elemI32(encodingPlain)
+		c.elemI32(encodingRLE)
+		c.list(3, thriftBinary, 1)
+		c.elemString(col.name)
+		c.i32(4, 0) // UNCOMPRESSED
+		c.i64(5, int64(numRows))
+		c.i64(6, sizes[i])
+		c.i64(7, sizes[i])
+		c.i64(9, offsets[i])
+		c.end()
+		c.end()
+	}
+	c.i64(2, total)
+	c.i64(3, int64(numRows))
+	c.end()
+
+	c.string(6, "github.com/jakebowkett/go-logger/logger/parquet")
+	c.end()
+
+	return c.b
+}
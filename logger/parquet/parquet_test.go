@@ -0,0 +1,245 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+reader reads the Thrift compact protocol into generic
+values: structs become maps of field id to value, lists
+slices, integers int64 and binary strings.
+*/
+type reader struct {
+	t *testing.T
+	b []byte
+}
+
+func (r *reader) next() byte {
+	if len(r.b) == 0 {
+		r.t.Fatal("ran out of bytes reading Thrift")
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *reader) varint() uint64 {
+	var u uint64
+	for shift := uint(0); ; shift += 7 {
+		c := r.next()
+		u |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return u
+		}
+	}
+}
+
+func (r *reader) zigzag() int64 {
+	u := r.varint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *reader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.b[:n])
+		r.b = r.b[n:]
+		return s
+	case thriftList:
+		h := r.next()
+		n, elem := int(h>>4), h&0xf
+		if n == 15 {
+			n = int(r.varint())
+		}
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = r.value(elem)
+		}
+		return l
+	case thriftStruct:
+		s := map[int16]interface{}{}
+		var id int16
+		for {
+			h := r.next()
+			if h == 0 {
+				return s
+			}
+			if delta := int16(h >> 4); delta != 0 {
+				id += delta
+			} else {
+				id = int16(r.zigzag())
+			}
+			s[id] = r.value(h & 0xf)
+		}
+	}
+	r.t.Fatalf("unexpected Thrift type %d", typ)
+	return nil
+}
+
+/*
+read decodes a file written by Write into its columns,
+checking the structure a reader relies on along the way.
+*/
+func read(t *testing.T, b []byte) (numRows int, cols map[string][]interface{}) {
+
+	if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Fatal("file isn't framed by PAR1")
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta := (&reader{t, b[len(b)-8-n : len(b)-8]}).value(thriftStruct).(map[int16]interface{})
+	numRows = int(meta[3].(int64))
+
+	schema := meta[2].([]interface{})
+	if root := schema[0].(map[int16]interface{}); root[5].(int64) != int64(len(schema)-1) {
+		t.Fatalf("root has %d children, schema has %d columns", root[5], len(schema)-1)
+	}
+	groups := meta[4].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("file has %d row groups, want 1", len(groups))
+	}
+	chunks := groups[0].(map[int16]interface{})[1].([]interface{})
+
+	cols = map[string][]interface{}{}
+	for i, c := range chunks {
+		field := schema[i+1].(map[int16]interface{})
+		name, typ := field[4].(string), field[1].(int64)
+		cm := c.(map[int16]interface{})[3].(map[int16]interface{})
+		if path := cm[3].([]interface{}); path[0] != name {
+			t.Fatalf("column %d is %v in its chunk and %s in the schema", i, path, name)
+		}
+
+		r := &reader{t, b[cm[9].(int64):]}
+		page := r.value(thriftStruct).(map[int16]interface{})
+		values := int(page[5].(map[int16]interface{})[1].(int64))
+		if values != numRows {
+			t.Fatalf("column %s has %d values, want %d", name, values, numRows)
+		}
+		data := r.b[:page[3].(int64)]
+		for j := 0; j < values; j++ {
+			switch typ {
+			case typeInt32:
+				cols[name] = append(cols[name], int32(binary.LittleEndian.Uint32(data)))
+				data = data[4:]
+			case typeInt64:
+				cols[name] = append(cols[name], int64(binary.LittleEndian.Uint64(data)))
+				data = data[8:]
+			case typeByteArray:
+				l := binary.LittleEndian.Uint32(data)
+				cols[name] = append(cols[name], string(data[4:4+l]))
+				data = data[4+l:]
+			}
+		}
+		if len(data) != 0 {
+			t.Fatalf("column %s has %d bytes left over", name, len(data))
+		}
+	}
+	return numRows, cols
+}
+
+func thread(t *testing.T, l *logger.Logger) logger.Thread {
+	id := l.NewId()
+	l.Info(id, "handled").Data("user", 7)
+	l.Error(id, "failed")
+	l.ThreadData(id, "tenant", "acme")
+	l.Tag(id, "audit", "slow")
+	th, ok := l.End(id, "10.0.0.1", "GET", "/users/:id", 1500)
+	if !ok {
+		t.Fatal("thread wasn't open")
+	}
+	return th
+}
+
+func TestWrite(t *testing.T) {
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	l := &logger.Logger{}
+	l.SetClock(func() time.Time { return date })
+	th := thread(t, l)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, th, logger.Thread{Kind: logger.KindSession, Id: "empty"}); err != nil {
+		t.Fatal(err)
+	}
+	n, cols := read(t, buf.Bytes())
+	if n != 3 {
+		t.Fatalf("file has %d rows, want 3", n)
+	}
+
+	want := map[string][]interface{}{
+		"date":        {date.UnixNano(), date.UnixNano(), int64(0)},
+		"kind":        {"request", "request", "session"},
+		"thread_id":   {th.Id, th.Id, "empty"},
+		"status":      {int32(200), int32(200), int32(0)},
+		"duration":    {int64(1500), int64(1500), int64(0)},
+		"thread_data": {`{"tenant":"acme"}`, `{"tenant":"acme"}`, "{}"},
+		"tags":        {"audit,slow", "audit,slow", ""},
+		"entry":       {int32(0), int32(1), int32(-1)},
+		"level":       {"Info", "Error", ""},
+		"message":     {"Handled.", "Failed.", ""},
+		"data":        {`{"user":"7"}`, "{}", "{}"},
+	}
+	for name, vals := range want {
+		for i, v := range vals {
+			if got := cols[name]; len(got) != len(vals) || got[i] != v {
+				t.Fatalf("column %s is %#v, want %#v", name, got, vals)
+			}
+		}
+	}
+}
+
+func TestExporter(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := &logger.Logger{}
+	e := &Exporter{Dir: dir, MaxRows: 3}
+	if err := e.Hook(thread(t, l)); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("wrote %d files before reaching MaxRows", len(files))
+	}
+	if err := e.Hook(thread(t, l)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("wrote %d files, want 1", len(files))
+	}
+	name := files[0].Name()
+	if !strings.HasPrefix(name, "threads-") || !strings.HasSuffix(name, ".parquet") {
+		t.Fatalf("wrote %s", name)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := read(t, b); n != 4 {
+		t.Fatalf("file has %d rows, want 4", n)
+	}
+}
@@ -0,0 +1,105 @@
+package parquet
+
+/*
+compact writes the subset of the Thrift compact protocol
+needed for Parquet's page headers and file metadata.
+*/
+type compact struct {
+	b    []byte
+	last []int16
+	prev int16
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func (c *compact) varint(u uint64) {
+	for u >= 0x80 {
+		c.b = append(c.b, byte(u)|0x80)
+		u >>= 7
+	}
+	c.b = append(c.b, byte(u))
+}
+
+func (c *compact) zigzag(v int64) {
+	c.varint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (c *compact) field(id int16, typ byte) {
+	if delta := id - c.prev; delta > 0 && delta <= 15 {
+		c.b = append(c.b, byte(delta)<<4|typ)
+	} else {
+		c.b = append(c.b, typ)
+		c.zigzag(int64(id))
+	}
+	c.prev = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, thriftI32)
+	c.zigzag(int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, thriftI64)
+	c.zigzag(v)
+}
+
+func (c *compact) string(id int16, s string) {
+	c.field(id, thriftBinary)
+	c.varint(uint64(len(s)))
+	c.b = append(c.b, s...)
+}
+
+/*
+list starts a list field of n elements of typ. Struct
+elements are written with begin and end; others with the
+elem methods.
+*/
+func (c *compact) list(id int16, typ byte, n int) {
+	c.field(id, thriftList)
+	if n < 15 {
+		c.b = append(c.b, byte(n)<<4|typ)
+	} else {
+		c.b = append(c.b, 0xf0|typ)
+		c.varint(uint64(n))
+	}
+}
+
+func (c *compact) elemI32(v int32) {
+	c.zigzag(int64(v))
+}
+
+func (c *compact) elemString(s string) {
+	c.varint(uint64(len(s)))
+	c.b = append(c.b, s...)
+}
+
+/*
+structField starts a struct valued field. Call end after
+writing its fields.
+*/
+func (c *compact) structField(id int16) {
+	c.field(id, thriftStruct)
+	c.begin()
+}
+
+/*
+begin starts a struct, whether a list element or the top
+level one.
+*/
+func (c *compact) begin() {
+	c.last = append(c.last, c.prev)
+	c.prev = 0
+}
+
+func (c *compact) end() {
+	c.b = append(c.b, 0)
+	c.prev = c.last[len(c.last)-1]
+	c.last = c.last[:len(c.last)-1]
+}
@@ -0,0 +1,232 @@
+/*
+Package pb encodes threads as protocol buffers following
+thread.proto, e.g. to ship them over gRPC or store them
+compactly. The encoding is written by hand so the module has
+no dependencies but is compatible with code generated from
+the schema. Data and meta values are encoded as strings.
+*/
+package pb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Marshal encodes t as a Thread message.
+*/
+func Marshal(t logger.Thread) []byte {
+
+	var e encoder
+	if !t.Date.IsZero() {
+		e.varint(1, t.Date.UnixNano())
+	}
+	e.string(2, t.Kind.String())
+	e.string(3, t.Id)
+	e.string(4, t.Ip)
+	e.string(5, t.Method)
+	e.string(6, t.Route)
+	e.varint(7, int64(t.Status))
+	e.varint(8, t.Duration)
+	for _, entry := range t.Entries {
+		entry := entry
+		e.message(9, func(m *encoder) { marshalEntry(m, entry) })
+	}
+	e.string(10, t.Redirect)
+	for _, kv := range t.KeyVals {
+		e.message(11, keyVal(kv.Key, kv.Val))
+	}
+	for _, tag := range t.Tags {
+		e.repeatedString(12, tag)
+	}
+	e.string(13, t.CorrelationId)
+	e.string(14, string(t.Outcome))
+	e.varint(15, int64(t.Chunk))
+	e.bool(16, t.Unterminated)
+	e.bool(17, t.TimedOut)
+	for _, p := range t.Phases {
+		p := p
+		e.message(18, func(m *encoder) {
+			m.string(1, p.Name)
+			if !p.Start.IsZero() {
+				m.varint(2, p.Start.UnixNano())
+			}
+			m.varint(3, int64(p.Duration))
+		})
+	}
+	// Meta is a map, so its keys are sorted to encode a thread
+	// the same way every time.
+	keys := make([]string, 0, len(t.Meta))
+	for k := range t.Meta {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.message(19, keyVal(k, t.Meta[logger.MetaKey(k)]))
+	}
+
+	return e.b
+}
+
+func marshalEntry(e *encoder, entry *logger.Entry) {
+	e.varint(1, int64(entry.Level))
+	e.string(2, entry.Message)
+	e.string(3, entry.Key)
+	e.string(4, entry.Function)
+	e.string(5, entry.File)
+	e.varint(6, int64(entry.Line))
+	for _, kv := range entry.KeyVals {
+		e.message(7, keyVal(kv.Key, kv.Val))
+	}
+	e.uvarint(8, entry.Seq)
+}
+
+func keyVal(k string, v interface{}) func(*encoder) {
+	return func(e *encoder) {
+		e.string(1, k)
+		e.string(2, fmt.Sprint(v))
+	}
+}
+
+/*
+Unmarshal decodes a Thread message. Unknown fields are
+ignored.
+*/
+func Unmarshal(b []byte) (logger.Thread, error) {
+
+	var t logger.Thread
+	err := fields(b, func(field int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			t.Date = time.Unix(0, int64(v))
+		case 2:
+			return t.Kind.UnmarshalText(data)
+		case 3:
+			t.Id = string(data)
+		case 4:
+			t.Ip = string(data)
+		case 5:
+			t.Method = string(data)
+		case 6:
+			t.Route = string(data)
+		case 7:
+			t.Status = int(int32(v))
+		case 8:
+			t.Duration = int64(v)
+		case 9:
+			entry, err := unmarshalEntry(data)
+			if err != nil {
+				return err
+			}
+			entry.ThreadId = t.Id
+			t.Entries = append(t.Entries, entry)
+		case 10:
+			t.Redirect = string(data)
+		case 11:
+			k, v, err := unmarshalKeyVal(data)
+			if err != nil {
+				return err
+			}
+			t.Data(k, v)
+		case 12:
+			t.Tags = append(t.Tags, string(data))
+		case 13:
+			t.CorrelationId = string(data)
+		case 14:
+			t.Outcome = logger.Outcome(data)
+		case 15:
+			t.Chunk = int(int32(v))
+		case 16:
+			t.Unterminated = v != 0
+		case 17:
+			t.TimedOut = v != 0
+		case 18:
+			p, err := unmarshalPhase(data)
+			if err != nil {
+				return err
+			}
+			t.Phases = append(t.Phases, p)
+		case 19:
+			k, v, err := unmarshalKeyVal(data)
+			if err != nil {
+				return err
+			}
+			if t.Meta == nil {
+				t.Meta = map[logger.MetaKey]interface{}{}
+			}
+			t.Meta[logger.MetaKey(k)] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return logger.Thread{}, err
+	}
+
+	// The id may follow the entries.
+	for _, e := range t.Entries {
+		e.ThreadId = t.Id
+	}
+	return t, nil
+}
+
+func unmarshalEntry(b []byte) (*logger.Entry, error) {
+	e := &logger.Entry{}
+	err := fields(b, func(field int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			e.Level = logger.Level(int32(v))
+		case 2:
+			e.Message = string(data)
+		case 3:
+			e.Key = string(data)
+		case 4:
+			e.Function = string(data)
+		case 5:
+			e.File = string(data)
+		case 6:
+			e.Line = int(int32(v))
+		case 7:
+			k, v, err := unmarshalKeyVal(data)
+			if err != nil {
+				return err
+			}
+			e.Data(k, v)
+		case 8:
+			e.Seq = v
+		}
+		return nil
+	})
+	return e, err
+}
+
+func unmarshalKeyVal(b []byte) (k, v string, err error) {
+	err = fields(b, func(field int, _ uint64, data []byte) error {
+		switch field {
+		case 1:
+			k = string(data)
+		case 2:
+			v = string(data)
+		}
+		return nil
+	})
+	return k, v, err
+}
+
+func unmarshalPhase(b []byte) (logger.Phase, error) {
+	var p logger.Phase
+	err := fields(b, func(field int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			p.Name = string(data)
+		case 2:
+			p.Start = time.Unix(0, int64(v))
+		case 3:
+			p.Duration = time.Duration(v)
+		}
+		return nil
+	})
+	return p, err
+}
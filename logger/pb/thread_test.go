@@ -0,0 +1,69 @@
+package pb
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+func TestRoundTrip(t *testing.T) {
+
+	start := time.Unix(0, 1600000000000000000)
+	entry := &logger.Entry{
+		ThreadId: "abc",
+		Level:    logger.LevelError,
+		Function: "main.handler",
+		File:     "main.go",
+		Message:  "Something broke.",
+		Key:      "broke",
+		Line:     42,
+		Seq:      7,
+	}
+	entry.Data("attempt", "3")
+
+	want := logger.Thread{
+		Date:          start,
+		Kind:          logger.KindRequest,
+		Id:            "abc",
+		Ip:            "127.0.0.1",
+		Method:        "GET",
+		Route:         "/users/:id",
+		Status:        500,
+		Duration:      int64(time.Millisecond),
+		Entries:       []*logger.Entry{entry},
+		Redirect:      "/login",
+		Meta:          map[logger.MetaKey]interface{}{"region": "eu", "version": "1.2"},
+		Tags:          []string{"slow", ""},
+		Phases:        []logger.Phase{{Name: "db", Start: start, Duration: time.Millisecond}},
+		Chunk:         2,
+		CorrelationId: "xyz",
+		Outcome:       logger.OutcomeFailed,
+		Unterminated:  true,
+		TimedOut:      true,
+	}
+	want.Data("user", "bob")
+
+	got, err := Unmarshal(Marshal(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestMetaOrder(t *testing.T) {
+	th := logger.Thread{Kind: logger.KindRequest, Meta: map[logger.MetaKey]interface{}{}}
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		th.Meta[logger.MetaKey(k)] = k
+	}
+	first := Marshal(th)
+	for i := 0; i < 20; i++ {
+		if !bytes.Equal(Marshal(th), first) {
+			t.Fatal("encoding changed between calls")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package pb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("pb: message is truncated")
+
+type encoder struct {
+	b []byte
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func (e *encoder) tag(field, wire int) {
+	e.b = appendVarint(e.b, uint64(field)<<3|uint64(wire))
+}
+
+func (e *encoder) varint(field int, v int64) {
+	e.uvarint(field, uint64(v))
+}
+
+func (e *encoder) uvarint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.b = appendVarint(e.b, v)
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.varint(field, 1)
+	}
+}
+
+func (e *encoder) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.bytes(field, []byte(s))
+}
+
+/*
+repeatedString writes s even if it is empty, as elements of
+repeated fields always are.
+*/
+func (e *encoder) repeatedString(field int, s string) {
+	e.bytes(field, []byte(s))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.b = appendVarint(e.b, uint64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) message(field int, fn func(*encoder)) {
+	var m encoder
+	fn(&m)
+	e.bytes(field, m.b)
+}
+
+/*
+fields calls fn with each field in b. Only varint and length
+delimited values are passed on; fixed width values, which
+thread.proto doesn't use, are skipped.
+*/
+func fields(b []byte, fn func(field int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		field, wire := int(key>>3), int(key&7)
+
+		switch wire {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+			if err := fn(field, v, nil); err != nil {
+				return err
+			}
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			data := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if err := fn(field, 0, data); err != nil {
+				return err
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			b = b[4:]
+		default:
+			return fmt.Errorf("pb: unsupported wire type %d", wire)
+		}
+	}
+	return nil
+}
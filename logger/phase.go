@@ -0,0 +1,156 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+Phase is a named stage of a thread, such as "auth", and how
+long it took.
+*/
+type Phase struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+}
+
+/*
+Phase starts the phase name in the thread reqId, ending the
+current phase if there is one. Phases are passed on in order
+in Thread.Phases and FormatPretty shows how long each took.
+*/
+func (l *Logger) Phase(reqId, name string) {
+	now := l.now()
+	b := l.shared().threads.buffer(reqId)
+	b.mu.Lock()
+	b.meta.endPhase(now)
+	b.meta.phases = append(b.meta.phases, Phase{Name: name, Start: now})
+	b.meta.phaseOpen = true
+	b.mu.Unlock()
+}
+
+/*
+PhaseEnd ends the current phase of the thread reqId. It is
+only needed if the time until the next phase, or the end of
+the thread, shouldn't be counted towards it.
+*/
+func (l *Logger) PhaseEnd(reqId string) {
+	now := l.now()
+	b := l.shared().threads.lookup(reqId)
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.meta.endPhase(now)
+	b.mu.Unlock()
+}
+
+func (s *Session) Phase(name string) {
+	if s.ended {
+		return
+	}
+	s.logger.Phase(s.id, name)
+}
+
+func (s *Session) PhaseEnd() {
+	if s.ended {
+		return
+	}
+	s.logger.PhaseEnd(s.id)
+}
+
+func (m *meta) endPhase(now time.Time) {
+	if !m.phaseOpen {
+		return
+	}
+	p := &m.phases[len(m.phases)-1]
+	p.Duration = now.Sub(p.Start)
+	m.phaseOpen = false
+}
+
+/*
+phaseSummary renders t's phases on one line with their
+share of the request's duration, if it is known.
+*/
+func (t Thread) phaseSummary() string {
+	parts := make([]string, len(t.Phases))
+	for i, p := range t.Phases {
+		parts[i] = escape(p.Name, false) + " " + phaseDuration(p.Duration)
+		if t.Kind == KindRequest && t.Duration > 0 {
+			parts[i] += fmt.Sprintf(" (%d%%)", int64(p.Duration)*100/t.Duration)
+		}
+	}
+	return "phases: " + strings.Join(parts, ", ")
+}
+
+func phaseDuration(d time.Duration) string {
+	if d >= time.Millisecond {
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// waterfallWidth is the number of columns phases are
+// scaled to by FormatWaterfall.
+const waterfallWidth = 40
+
+/*
+FormatWaterfall renders t's phases as a timeline with a bar
+per phase, offset by when it started and scaled to how long
+it took, so latency hotspots stand out in a terminal.
+*/
+func (t Thread) FormatWaterfall() string {
+
+	var b strings.Builder
+	b.WriteString(t.Date.Format(time.Kitchen))
+	if t.Kind == KindRequest {
+		fmt.Fprintf(&b, " %d %s %s", t.Status, t.duration(), escape(t.Method, false))
+	}
+	fmt.Fprintf(&b, " %s\n", escape(t.Route, false))
+
+	if len(t.Phases) == 0 {
+		return b.String()
+	}
+
+	start := t.Phases[0].Start
+	end := start
+	nameWidth := 0
+	for _, p := range t.Phases {
+		if p.Start.Before(start) {
+			start = p.Start
+		}
+		if e := p.Start.Add(p.Duration); e.After(end) {
+			end = e
+		}
+		if n := len([]rune(escape(p.Name, false))); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	total := end.Sub(start)
+
+	for _, p := range t.Phases {
+		offset, width := 0, waterfallWidth
+		if total > 0 {
+			offset = int(int64(p.Start.Sub(start)) * waterfallWidth / int64(total))
+			width = int(int64(p.Duration) * waterfallWidth / int64(total))
+		}
+		if width < 1 {
+			width = 1
+		}
+		if offset+width > waterfallWidth {
+			offset = waterfallWidth - width
+		}
+		name := escape(p.Name, false)
+		fmt.Fprintf(&b, "%s%s |%s%s%s| %s\n",
+			name,
+			strings.Repeat(" ", nameWidth-len([]rune(name))),
+			strings.Repeat(" ", offset),
+			strings.Repeat("#", width),
+			strings.Repeat(" ", waterfallWidth-offset-width),
+			phaseDuration(p.Duration))
+	}
+
+	return b.String()
+}
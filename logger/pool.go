@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"sync"
+)
+
+var entryPool = sync.Pool{
+	New: func() interface{} {
+		return &Entry{}
+	},
+}
+
+/*
+SetPooling enables the reuse of entries once their thread
+has been passed to OnError and OnLog. This reduces garbage
+for services logging many entries but means neither hook
+may retain the Thread or its entries after returning, and
+an *Entry must not be used once its thread has ended.
+*/
+func (l *Logger) SetPooling(enabled bool) {
+	l.update("pool", func(s *settings) { s.pool = enabled })
+}
+
+func newEntry(pooled bool) *Entry {
+	if !pooled {
+		return &Entry{}
+	}
+	return entryPool.Get().(*Entry)
+}
+
+func recycle(ee []*Entry) {
+	for _, e := range ee {
+
+		// Clear key/vals so the pool doesn't keep their
+		// values alive but keep the capacity for reuse.
+		kvs := e.KeyVals
+		for i := range kvs {
+			kvs[i] = kv{}
+		}
+
+		*e = Entry{KeyVals: kvs[:0]}
+		entryPool.Put(e)
+	}
+}
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+)
+
+/*
+ProcessInfo identifies the process that logged a thread so
+logs aggregated from many replicas remain attributable.
+*/
+type ProcessInfo struct {
+	Hostname   string
+	PID        int
+	InstanceId string
+}
+
+/*
+DefaultProcessInfo returns the hostname and PID of the
+current process. InstanceId is taken from the INSTANCE_ID
+environment variable, or is random if that isn't set, so it
+distinguishes replicas on the same host and restarts.
+*/
+func DefaultProcessInfo() ProcessInfo {
+	host, _ := os.Hostname()
+	id := os.Getenv("INSTANCE_ID")
+	if id == "" {
+		b := make([]byte, 6)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	return ProcessInfo{
+		Hostname:   host,
+		PID:        os.Getpid(),
+		InstanceId: id,
+	}
+}
+
+/*
+SetProcessInfo stamps p onto every thread as the thread data
+"host", "pid" and "instance", omitting any that are zero.
+Passing a zero ProcessInfo stops the stamping.
+*/
+func (l *Logger) SetProcessInfo(p ProcessInfo) {
+	var fields []kv
+	if p.Hostname != "" {
+		fields = append(fields, kv{"host", p.Hostname})
+	}
+	if p.PID != 0 {
+		fields = append(fields, kv{"pid", p.PID})
+	}
+	if p.InstanceId != "" {
+		fields = append(fields, kv{"instance", p.InstanceId})
+	}
+	l.update("process", func(s *settings) { s.process = fields })
+}
+
+/*
+stampedFields returns the thread data set by SetProcessInfo,
+SetBuildInfo and SetKubernetesInfo.
+*/
+func (s *settings) stampedFields() []kv {
+	var fields []kv
+	for _, f := range [][]kv{s.process, s.build, s.kube} {
+		if fields == nil {
+			fields = f
+		} else if f != nil {
+			fields = append(fields[:len(fields):len(fields)], f...)
+		}
+	}
+	return fields
+}
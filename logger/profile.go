@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+/*
+SetProfileLabels makes Middleware label the goroutine that
+handles each request with its "reqId" and "route" using
+runtime/pprof, and makes every entry record the id of the
+goroutine that logged it as "goroutine", so CPU profiles and
+goroutine dumps can be cross-referenced with logs. Finding
+the goroutine id costs about a microsecond per entry.
+*/
+func (l *Logger) SetProfileLabels(enabled bool) {
+	l.update("profile", func(s *settings) { s.profile = enabled })
+}
+
+func (l *Logger) profiling() bool {
+	return l.settings().profile
+}
+
+/*
+goroutineId parses the id of the current goroutine from the
+first line of its stack trace, e.g. "goroutine 18 [running]:".
+The runtime doesn't expose it otherwise.
+*/
+func goroutineId() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i != -1 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"time"
+)
+
+type ProgressOptions struct {
+
+	// Every emits a snapshot of each open thread that has
+	// new entries at this interval. Zero disables it.
+	Every time.Duration
+
+	// Entries emits a snapshot of a thread each time this
+	// many entries have been logged to it. Zero disables it.
+	Entries int
+}
+
+/*
+SetProgress makes the logger emit interim snapshots of open
+threads so long-running sessions, such as batch jobs, can be
+observed before they end. Snapshots are passed to the hooks
+like ended threads but are marked Unterminated and tagged
+"progress", and contain every entry so far rather than only
+the new ones. Passing zero options stops them.
+*/
+func (l *Logger) SetProgress(opts ProgressOptions) {
+
+	var p *progress
+	if opts.Every > 0 || opts.Entries > 0 {
+		p = &progress{opts: opts, stop: make(chan struct{})}
+	}
+
+	l.progressMu.Lock()
+	old := l.progress
+	l.progress = p
+	l.progressMu.Unlock()
+
+	if old != nil {
+		close(old.stop)
+	}
+	if p != nil && opts.Every > 0 {
+		go l.progressLoop(p)
+	}
+}
+
+type progress struct {
+	opts ProgressOptions
+	stop chan struct{}
+}
+
+func (l *Logger) progressOptions() ProgressOptions {
+	l.progressMu.Lock()
+	defer l.progressMu.Unlock()
+	if l.progress == nil {
+		return ProgressOptions{}
+	}
+	return l.progress.opts
+}
+
+func (l *Logger) progressLoop(p *progress) {
+	ticker := time.NewTicker(p.opts.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			l.shared().threads.each(func(id string, b *buffer) {
+				b.mu.Lock()
+				if b.closed || len(b.entries) == b.reported {
+					b.mu.Unlock()
+					return
+				}
+				b.reported = len(b.entries)
+				t := l.progressThread(id, b)
+				b.mu.Unlock()
+				l.dispatch(t)
+			})
+		}
+	}
+}
+
+/*
+entryProgress emits a snapshot of e's thread if it has
+reached a multiple of ProgressOptions.Entries.
+*/
+func (l *Logger) entryProgress(e *Entry) {
+	n := l.progressOptions().Entries
+	if n <= 0 || (e.index+1)%n != 0 || e.buf == nil {
+		return
+	}
+	b := e.buf
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.reported = len(b.entries)
+	t := l.progressThread(e.ThreadId, b)
+	b.mu.Unlock()
+	l.dispatch(t)
+}
+
+func (l *Logger) progressThread(id string, b *buffer) Thread {
+	t := l.snapshotThread(id, b, l.now())
+	t.Tags = append(t.Tags, "progress")
+	return t
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+/*
+QueueStats describes the depth of a queue registered with
+WatchQueue at the time Stats was called.
+*/
+type QueueStats struct {
+	Name string `json:"name"`
+	Len  int    `json:"len"`
+	Cap  int    `json:"cap"`
+}
+
+/*
+WatchQueue registers a queue named name whose length and
+capacity are returned by depth, so it is reported by Stats
+and SetQueueReporting. Capacity may be zero if the queue is
+unbounded. Registering a name again replaces the queue and
+a nil depth removes it.
+*/
+func (l *Logger) WatchQueue(name string, depth func() (length, capacity int)) {
+	l.queueMu.Lock()
+	defer l.queueMu.Unlock()
+	if depth == nil {
+		delete(l.queues, name)
+		return
+	}
+	if l.queues == nil {
+		l.queues = map[string]func() (int, int){}
+	}
+	l.queues[name] = depth
+}
+
+/*
+WatchChan registers the channel ch with WatchQueue. It
+panics if ch isn't a channel.
+*/
+func (l *Logger) WatchChan(name string, ch interface{}) {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan {
+		panic(fmt.Sprintf("logger: WatchChan: %T is not a channel", ch))
+	}
+	l.WatchQueue(name, func() (int, int) {
+		return v.Len(), v.Cap()
+	})
+}
+
+/*
+queueStats returns the depths of the watched queues in name
+order.
+*/
+func (l *Logger) queueStats() []QueueStats {
+	l.queueMu.Lock()
+	queues := make(map[string]func() (int, int), len(l.queues))
+	for name, depth := range l.queues {
+		queues[name] = depth
+	}
+	l.queueMu.Unlock()
+
+	var stats []QueueStats
+	for name, depth := range queues {
+		n, c := depth()
+		stats = append(stats, QueueStats{Name: name, Len: n, Cap: c})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+/*
+SetQueueReporting logs the depth of each watched queue every
+interval in a session named "queue depths", with an entry
+for each queue, so backpressure in a pipeline shows up in
+the logs. Queues that are at least 90% full are logged as
+errors. Zero stops the reporting.
+*/
+func (l *Logger) SetQueueReporting(every time.Duration) {
+
+	var stop chan struct{}
+	if every > 0 {
+		stop = make(chan struct{})
+	}
+
+	l.queueMu.Lock()
+	old := l.queueStop
+	l.queueStop = stop
+	l.queueMu.Unlock()
+
+	if old != nil {
+		close(old)
+	}
+	if stop != nil {
+		go l.queueLoop(every, stop)
+	}
+}
+
+func (l *Logger) queueLoop(every time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.reportQueues()
+		}
+	}
+}
+
+func (l *Logger) reportQueues() {
+	stats := l.queueStats()
+	if len(stats) == 0 {
+		return
+	}
+	s := l.Sess("queue depths")
+	for _, q := range stats {
+		level := LevelInfo
+		if q.Cap > 0 && q.Len*10 >= q.Cap*9 {
+			level = LevelError
+		}
+		e := s.Log(level, "queue depth").
+			Data("queue", q.Name).
+			Data("len", q.Len)
+		if q.Cap > 0 {
+			e.Data("cap", q.Cap)
+		}
+	}
+	s.End()
+}
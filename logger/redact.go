@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"strings"
+)
+
+// Redacted replaces the values of redacted keys.
+const Redacted = "[redacted]"
+
+/*
+SetRedactKeys makes the logger replace the value of any data,
+thread data or template field whose key matches one of keys,
+ignoring case, with Redacted before it is recorded anywhere,
+including the write-ahead log. Passing no keys stops
+redaction.
+*/
+func (l *Logger) SetRedactKeys(keys ...string) {
+	keys = append([]string(nil), keys...)
+	l.update("redactKeys", func(s *settings) { s.redactKeys = keys })
+}
+
+func (l *Logger) redact(k string, v interface{}) interface{} {
+	if l.redacted(k) {
+		return Redacted
+	}
+	return v
+}
+
+func (l *Logger) redactFields(fields Fields) Fields {
+	for k := range fields {
+		if !l.redacted(k) {
+			continue
+		}
+		c := make(Fields, len(fields))
+		for k, v := range fields {
+			c[k] = l.redact(k, v)
+		}
+		return c
+	}
+	return fields
+}
+
+func (l *Logger) redacted(k string) bool {
+
+	// Entries built outside a logger, e.g. when decoding,
+	// have none.
+	if l == nil {
+		return false
+	}
+
+	for _, key := range l.settings().redactKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+)
+
+/*
+RequestScope is a request thread opened by BeginRequest. It
+lets middleware for routers and frameworks that don't take
+an http.Handler record requests the way Middleware does.
+*/
+type RequestScope struct {
+
+	// Id is the thread's id.
+	Id string
+
+	// Context carries Id and the request's trace context,
+	// if it has one. Handlers should be given it in place of
+	// the request's own so RequestId and Trace work.
+	Context context.Context
+
+	logger   *Logger
+	ip       string
+	method   string
+	path     string
+	original context.Context
+	labelled bool
+}
+
+/*
+BeginRequest opens a thread for r as Middleware does before
+calling the next handler: it resolves the client IP as set
+by SetTrustedProxies, extracts the trace context,
+attaches baggage, enables debug entries for SetDebugRoutes
+and labels the goroutine if SetProfileLabels is enabled.
+RequestScope.End must be called on the same goroutine once
+the request has been handled.
+
+The ginlogger, echologger and fiberlogger modules use it to
+provide middleware for Gin, Echo and Fiber. They are modules
+of their own so this one needs nothing outside the standard
+library.
+*/
+func (l *Logger) BeginRequest(r *http.Request) *RequestScope {
+
+	rs := &RequestScope{
+		Id:       l.NewId(),
+		logger:   l,
+		ip:       l.requestIp(r),
+		method:   r.Method,
+		path:     r.URL.Path,
+		original: r.Context(),
+	}
+
+	l.Begin(rs.Id)
+	ctx := WithRequestId(r.Context(), rs.Id)
+	tc, ok := ExtractB3(r.Header)
+	if !ok {
+		tc, ok = ExtractCloudTrace(r.Header)
+	}
+	if ok {
+		tc = tc.Child()
+		l.SetMeta(rs.Id, MetaTrace, tc)
+		ctx = context.WithValue(ctx, traceKey, tc)
+	}
+	l.attachBaggage(rs.Id, r.Header)
+	if l.debugRoute(rs.path) {
+		l.DebugThread(rs.Id)
+	}
+	if l.profiling() {
+		ctx = pprof.WithLabels(ctx, pprof.Labels("reqId", rs.Id, "route", rs.path))
+		pprof.SetGoroutineLabels(ctx)
+		rs.labelled = true
+	}
+	rs.Context = ctx
+	return rs
+}
+
+/*
+End ends the thread opened by BeginRequest. The route should
+be the pattern the request matched, e.g. /users/:id, so
+threads can be grouped by it. A pattern set with
+SetRoutePattern takes precedence and if there is neither
+the request's path is used. If the route isn't the path the
+path is kept as the thread data "path". The status is
+recorded unless the thread already has one, e.g. from a
+helper like NotFound, and may be zero if it isn't known.
+*/
+func (rs *RequestScope) End(route string, status int) (Thread, bool) {
+	if rs.labelled {
+		pprof.SetGoroutineLabels(rs.original)
+	}
+	if p, ok := rs.logger.Meta(rs.Id, MetaRoutePattern); ok {
+		route, _ = p.(string)
+	}
+	if route == "" {
+		route = rs.path
+	}
+	if route != rs.path {
+		rs.logger.ThreadData(rs.Id, "path", rs.path)
+	}
+	if status != 0 {
+		rs.logger.setStatus(rs.Id, status, true)
+	}
+	return rs.logger.End(rs.Id, rs.ip, rs.method, route, 0)
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+SetRetryBackoff replaces how long Retry, and a failed OnLog
+or OnError, waits after a failed attempt, numbered from 1. The default doubles from 100ms up
+to 10s. Passing nil restores it.
+*/
+func (l *Logger) SetRetryBackoff(backoff func(attempt int) time.Duration) {
+	l.update("backoff", func(s *settings) { s.backoff = backoff })
+}
+
+func (l *Logger) retryBackoff(attempt int) time.Duration {
+	if backoff := l.settings().backoff; backoff != nil {
+		return backoff(attempt)
+	}
+	return defaultBackoff(attempt)
+}
+
+/*
+defaultBackoff doubles from 100ms up to 10s.
+*/
+func defaultBackoff(attempt int) time.Duration {
+	d := 100 * time.Millisecond
+	for i := 1; i < attempt && d < 10*time.Second; i++ {
+		d *= 2
+	}
+	if d > 10*time.Second {
+		d = 10 * time.Second
+	}
+	return d
+}
+
+/*
+Retry calls fn until it returns nil or it has been called
+attempts times, waiting between attempts as set by
+SetRetryBackoff. Each failed attempt that will be retried is
+logged as info with its error and the backoff. If a retry
+succeeds a summary is logged as info, and if every attempt
+fails it is logged as an error. It returns fn's last error.
+*/
+func (l *Logger) Retry(reqId, name string, attempts int, fn func() error) error {
+	return l.retry(func(level Level, msg string) *Entry {
+		return l.logEntry(level, reqId, msg)
+	}, name, attempts, fn)
+}
+
+/*
+Retry is like Logger.Retry but logs to s.
+*/
+func (s *Session) Retry(name string, attempts int, fn func() error) error {
+	return s.logger.retry(s.Log, name, attempts, fn)
+}
+
+func (l *Logger) retry(log func(Level, string) *Entry, name string, attempts int, fn func() error) error {
+
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+
+		if err = fn(); err == nil {
+			if attempt > 1 {
+				log(LevelInfo, fmt.Sprintf("%s succeeded after %d attempts", name, attempt)).
+					Data("attempts", attempt)
+			}
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		backoff := l.retryBackoff(attempt)
+		log(LevelInfo, fmt.Sprintf("%s failed, retrying", name)).
+			Data("attempt", attempt).
+			Data("backoff", backoff).
+			Err(err)
+		time.Sleep(backoff)
+	}
+
+	log(LevelError, fmt.Sprintf("%s failed after %d attempts", name, attempts)).
+		Data("attempts", attempts).
+		Err(err)
+	return err
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+/*
+SetDebugRoutes enables debug entries for requests whose path
+matches any of patterns, regardless of SetDebug, so a failing
+subsystem can be made verbose without noise from the rest.
+Patterns use path.Match syntax, except that a trailing "/*"
+matches everything beneath the prefix at any depth, e.g.
+"/api/payments/*". Only requests passed through Middleware
+are matched. Passing no patterns disables route debugging.
+*/
+func (l *Logger) SetDebugRoutes(patterns ...string) {
+	patterns = append([]string(nil), patterns...)
+	l.update("debugRoutes", func(s *settings) { s.debugRoutes = patterns })
+}
+
+/*
+DebugRoutes returns the patterns set by SetDebugRoutes.
+*/
+func (l *Logger) DebugRoutes() []string {
+	return append([]string(nil), l.settings().debugRoutes...)
+}
+
+/*
+DebugThread enables debug entries for the thread threadId
+alone, regardless of SetDebug.
+*/
+func (l *Logger) DebugThread(threadId string) {
+	b := l.shared().threads.buffer(threadId)
+	b.mu.Lock()
+	b.debug = true
+	b.mu.Unlock()
+}
+
+func (l *Logger) debugRoutesSet() bool {
+	return len(l.settings().debugRoutes) > 0
+}
+
+func (l *Logger) debugRoute(route string) bool {
+	for _, p := range l.settings().debugRoutes {
+		if matchRoute(p, route) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRoute(pattern, route string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		prefix := pattern[:len(pattern)-1]
+		if strings.HasPrefix(route, prefix) || route+"/" == prefix {
+			return true
+		}
+	}
+	ok, _ := path.Match(pattern, route)
+	return ok
+}
+
+/*
+threadDebug reports whether debug entries were enabled for
+threadId by DebugThread.
+*/
+func (l *Logger) threadDebug(threadId string) bool {
+	b := l.shared().threads.lookup(threadId)
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.debug
+}
+
+/*
+SetRoutePattern records the pattern the request reqId
+matched, e.g. /users/{id}, so Middleware uses it as the
+thread's route in place of the path and threads can be
+grouped by route.
+*/
+func (l *Logger) SetRoutePattern(reqId, pattern string) {
+	l.SetMeta(reqId, MetaRoutePattern, pattern)
+}
+
+/*
+SetRoutePatterns sets the function Middleware calls once a
+request has been handled to find the pattern it matched.
+The chilogger and muxlogger modules provide middleware for
+chi and gorilla/mux that records patterns without it.
+
+If fn returns an empty string the path is used. Passing nil
+stops Middleware looking for patterns.
+*/
+func (l *Logger) SetRoutePatterns(fn func(r *http.Request) string) {
+	l.update("routeFunc", func(s *settings) { s.routeFunc = fn })
+}
+
+func (l *Logger) routePattern(r *http.Request) string {
+	fn := l.settings().routeFunc
+	if fn == nil {
+		return ""
+	}
+	return fn(r)
+}
@@ -0,0 +1,279 @@
+/*
+Package s3 batches ended threads and uploads them as objects
+to S3 or S3 compatible storage, for pipelines that are just
+a bucket.
+*/
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/jakebowkett/go-logger/logger/internal/httpclient"
+)
+
+/*
+DefaultKey is used when Uploader.Key is empty.
+*/
+const DefaultKey = "logs/{date}/{hour}/{host}-{time}.log"
+
+/*
+Uploader accumulates formatted threads and uploads them as
+a single object whenever MaxBytes is reached, Flush is called
+or, once started, periodically. Requests are signed with AWS
+Signature Version 4 and use path-style URLs.
+*/
+type Uploader struct {
+
+	// Endpoint is the storage's base URL, e.g.
+	// https://s3.eu-west-1.amazonaws.com.
+	Endpoint string
+
+	Region string
+	Bucket string
+
+	AccessKeyId     string
+	SecretAccessKey string
+
+	// SessionToken is needed for temporary credentials.
+	SessionToken string
+
+	// Key is the template for object keys. {date} is
+	// replaced with the UTC date as 2006-01-02, {hour} with
+	// the UTC hour, {host} with the hostname and {time}
+	// with the UTC time in nanoseconds, which keeps keys
+	// unique. It defaults to DefaultKey.
+	Key string
+
+	// Format defaults to Thread.FormatRecord.
+	Format func(logger.Thread) string
+
+	// ContentType defaults to text/plain.
+	ContentType string
+
+	// MaxBytes uploads once this much has accumulated.
+	// Zero leaves uploading to Flush and Start.
+	MaxBytes int
+
+	// MaxBuffer caps how much is kept while uploads fail.
+	// Hook returns ErrFull rather than take a thread past
+	// it so the logger retries or dead-letters the thread
+	// instead. It defaults to DefaultMaxBuffer.
+	MaxBuffer int
+
+	// Client defaults to http.DefaultClient, or a client
+	// using TLS if it is set.
+	Client *http.Client
+
+	// TLS configures how the server is verified, and any
+	// client certificate, when Client isn't set.
+	TLS *logger.TLSOptions
+
+	// Compression is the content encoding objects are
+	// compressed with and stored under, such as gzip or,
+	// once registered, zstd, or empty to store them as they
+	// are. See logger.RegisterCompression.
+	Compression string
+
+	// OnError is called when an upload started by Hook or
+	// Start fails.
+	OnError func(error)
+
+	clients httpclient.Cache
+
+	// mu guards buf, the threads not yet uploaded, and
+	// inflight, the size of the upload in progress. It isn't
+	// held while uploading so Hook doesn't wait for it.
+	mu       sync.Mutex
+	buf      []byte
+	inflight int
+	stop     chan struct{}
+
+	// flushMu is held while uploading so a failed upload is
+	// put back before the next one starts, keeping threads
+	// in order.
+	flushMu sync.Mutex
+}
+
+/*
+DefaultMaxBuffer is used when Uploader.MaxBuffer is zero.
+*/
+const DefaultMaxBuffer = 64 << 20
+
+/*
+ErrFull is returned by Hook when uploads have been failing
+for long enough that Uploader.MaxBuffer has been reached.
+*/
+var ErrFull = errors.New("s3: buffer is full")
+
+/*
+Hook adds t to the next object. It has the signature of
+Logger.OnLog so it can be assigned to it. Once t has been
+added Hook returns nil even if the upload it triggers fails,
+since t will be uploaded by the next one and returning the
+error would have the logger pass t again. The failure is
+reported to OnError instead.
+*/
+func (u *Uploader) Hook(t logger.Thread) error {
+	format := u.Format
+	if format == nil {
+		format = logger.Thread.FormatRecord
+	}
+	record := format(t)
+	max := u.MaxBuffer
+	if max <= 0 {
+		max = DefaultMaxBuffer
+	}
+
+	u.mu.Lock()
+	if len(u.buf)+u.inflight+len(record) > max {
+		u.mu.Unlock()
+		return ErrFull
+	}
+	u.buf = append(u.buf, record...)
+	full := u.MaxBytes > 0 && len(u.buf) >= u.MaxBytes
+	u.mu.Unlock()
+
+	if full {
+		if err := u.Flush(); err != nil && u.OnError != nil {
+			u.OnError(err)
+		}
+	}
+	return nil
+}
+
+/*
+Flush uploads what has accumulated, unless nothing has. It
+is kept for the next attempt if the upload fails.
+*/
+func (u *Uploader) Flush() error {
+
+	u.flushMu.Lock()
+	defer u.flushMu.Unlock()
+
+	u.mu.Lock()
+	body := u.buf
+	u.buf = nil
+	u.inflight = len(body)
+	u.mu.Unlock()
+	if len(body) == 0 {
+		return nil
+	}
+
+	err := u.upload(body)
+
+	u.mu.Lock()
+	u.inflight = 0
+	if err != nil {
+		u.buf = append(body, u.buf...)
+	}
+	u.mu.Unlock()
+	return err
+}
+
+func (u *Uploader) upload(body []byte) error {
+
+	now := time.Now()
+	key := u.key(now)
+	body, err := logger.Compress(u.Compression, body)
+	if err != nil {
+		return fmt.Errorf("s3: %v", strings.TrimPrefix(err.Error(), "logger: "))
+	}
+
+	req, err := http.NewRequest(http.MethodPut,
+		strings.TrimSuffix(u.Endpoint, "/")+"/"+escapePath(u.Bucket+"/"+key),
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	contentType := u.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if u.Compression != "" {
+		req.Header.Set("Content-Encoding", u.Compression)
+	}
+	u.sign(req, hexHash(body), now)
+
+	client, err := u.clients.Get(u.Client, u.TLS)
+	if err != nil {
+		return fmt.Errorf("s3: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("s3: uploading %s responded %s", key, resp.Status)
+	}
+	return nil
+}
+
+func (u *Uploader) key(now time.Time) string {
+	tmpl := u.Key
+	if tmpl == "" {
+		tmpl = DefaultKey
+	}
+	host, _ := os.Hostname()
+	now = now.UTC()
+	return strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{hour}", now.Format("15"),
+		"{host}", host,
+		"{time}", strconv.FormatInt(now.UnixNano(), 10),
+	).Replace(tmpl)
+}
+
+/*
+Start uploads every interval until Close is called.
+*/
+func (u *Uploader) Start(every time.Duration) {
+
+	stop := make(chan struct{})
+	u.mu.Lock()
+	old := u.stop
+	u.stop = stop
+	u.mu.Unlock()
+	if old != nil {
+		close(old)
+	}
+
+	go func() {
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := u.Flush(); err != nil && u.OnError != nil {
+					u.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+/*
+Close stops periodic uploads and uploads what remains.
+*/
+func (u *Uploader) Close() error {
+	u.mu.Lock()
+	stop := u.stop
+	u.stop = nil
+	u.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	return u.Flush()
+}
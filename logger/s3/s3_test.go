@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+bucket is an S3 endpoint that fails the first fail uploads
+and records the bodies of the rest.
+*/
+type bucket struct {
+	mu       sync.Mutex
+	fail     int
+	uploaded []string
+	paths    []string
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.Method != http.MethodPut || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if b.fail > 0 {
+		b.fail--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	b.uploaded = append(b.uploaded, string(body))
+	b.paths = append(b.paths, r.URL.Path)
+}
+
+func record(t logger.Thread) string {
+	return "T:" + t.Id + "\n"
+}
+
+/*
+TestUploaderRetry checks a thread is uploaded once when the
+upload Hook triggers fails, rather than kept for the next
+upload and passed again by the logger.
+*/
+func TestUploaderRetry(t *testing.T) {
+
+	b := &bucket{fail: 1}
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	var errs []error
+	u := &Uploader{
+		Endpoint: srv.URL,
+		Region:   "eu-west-1",
+		Bucket:   "logs",
+		Key:      "{host}/{time}.log",
+		Format:   record,
+		MaxBytes: 1,
+		OnError:  func(err error) { errs = append(errs, err) },
+	}
+	if err := u.Hook(logger.Thread{Id: "one"}); err != nil {
+		t.Fatalf("Hook returned %v after buffering the thread", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("OnError was called %d times, want 1", len(errs))
+	}
+	if err := u.Hook(logger.Thread{Id: "two"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.uploaded) != 1 || b.uploaded[0] != "T:one\nT:two\n" {
+		t.Fatalf("uploaded %q, want one object with both threads once", b.uploaded)
+	}
+	if !strings.HasPrefix(b.paths[0], "/logs/") {
+		t.Fatalf("uploaded to %s, want a path in the bucket", b.paths[0])
+	}
+}
+
+func TestUploaderFull(t *testing.T) {
+
+	b := &bucket{fail: 1 << 30}
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	u := &Uploader{
+		Endpoint:  srv.URL,
+		Bucket:    "logs",
+		Format:    record,
+		MaxBuffer: 12,
+	}
+	for _, id := range []string{"one", "two"} {
+		if err := u.Hook(logger.Thread{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := u.Flush(); err == nil {
+		t.Fatal("Flush succeeded against a failing endpoint")
+	}
+	if err := u.Hook(logger.Thread{Id: "three"}); err != ErrFull {
+		t.Fatalf("Hook returned %v past MaxBuffer, want ErrFull", err)
+	}
+
+	b.mu.Lock()
+	b.fail = 0
+	b.mu.Unlock()
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.uploaded) != 1 || b.uploaded[0] != "T:one\nT:two\n" {
+		t.Fatalf("uploaded %q", b.uploaded)
+	}
+}
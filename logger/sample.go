@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"sync/atomic"
+)
+
+/*
+SampleOptions configures Sampled.
+*/
+type SampleOptions struct {
+
+	// Every keeps one in every Every threads. Below 2 every
+	// thread is kept.
+	Every int
+
+	// Threads with any of KeepTags are always kept, as are
+	// those with an error entry or a 5xx status.
+	KeepTags []string
+}
+
+/*
+Sampled returns a hook, such as for OnLog, that passes one
+in every opts.Every threads to hook and discards the rest
+without error. Unlike the asynchronous Sample policy, which
+only applies while the queue is full, it samples whether or
+not the logger is keeping up, to cut the volume of routine
+threads.
+*/
+func Sampled(hook func(Thread) error, opts SampleOptions) func(Thread) error {
+	if opts.Every < 2 {
+		return hook
+	}
+	var n uint64
+	return func(t Thread) error {
+		if alwaysSampled(t, opts.KeepTags) || atomic.AddUint64(&n, 1)%uint64(opts.Every) == 1 {
+			return hook(t)
+		}
+		return nil
+	}
+}
+
+func alwaysSampled(t Thread, tags []string) bool {
+	if t.Status >= 500 {
+		return true
+	}
+	for _, e := range t.Entries {
+		if e.Level == LevelError {
+			return true
+		}
+	}
+	for _, tag := range tags {
+		if t.HasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
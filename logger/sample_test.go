@@ -0,0 +1,34 @@
+package logger
+
+import "testing"
+
+func TestSampled(t *testing.T) {
+
+	var kept []string
+	hook := Sampled(func(t Thread) error {
+		kept = append(kept, t.Id)
+		return nil
+	}, SampleOptions{Every: 3, KeepTags: []string{"critical"}})
+
+	for _, th := range []Thread{
+		{Id: "1"}, {Id: "2"}, {Id: "3"}, {Id: "4"},
+		{Id: "error", Entries: []*Entry{{Level: LevelError}}},
+		{Id: "500", Status: 500},
+		{Id: "tagged", Tags: []string{"critical"}},
+		{Id: "5"}, {Id: "6"}, {Id: "7"},
+	} {
+		if err := hook(th); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"1", "4", "error", "500", "tagged", "7"}
+	if len(kept) != len(want) {
+		t.Fatalf("kept %v, want %v", kept, want)
+	}
+	for i := range want {
+		if kept[i] != want[i] {
+			t.Fatalf("kept %v, want %v", kept, want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ type Session struct {
 	name   string
 	id     string
 	ended  bool
+	beat   chan struct{}
 }
 
 func (l *Logger) Sess(name string) *Session {
@@ -19,59 +20,216 @@ func (l *Logger) Sess(name string) *Session {
 	}
 }
 
+/*
+SetCorrelationId sets the id shared by threads related to
+the thread threadId. See SessFrom.
+*/
+func (l *Logger) SetCorrelationId(threadId, correlationId string) {
+	l.SetMeta(threadId, MetaCorrelation, correlationId)
+}
+
+/*
+CorrelationId returns the correlation id of the open thread
+threadId, if it has one.
+*/
+func (l *Logger) CorrelationId(threadId string) string {
+	id, _ := l.Meta(threadId, MetaCorrelation)
+	s, _ := id.(string)
+	return s
+}
+
+/*
+SessFrom starts a session, e.g. for background work, that is
+correlated with the thread parentId. If the parent has no
+correlation id its own id is used and set on it as well so
+both threads share it.
+*/
+func (l *Logger) SessFrom(parentId, name string) *Session {
+	cid := l.CorrelationId(parentId)
+	if cid == "" {
+		cid = parentId
+		l.SetCorrelationId(parentId, cid)
+	}
+	s := l.Sess(name)
+	l.SetCorrelationId(s.id, cid)
+	return s
+}
+
+/*
+Sess starts a session correlated with s. See SessFrom.
+*/
+func (s *Session) Sess(name string) *Session {
+	return s.logger.SessFrom(s.id, name)
+}
+
+func (s *Session) ThreadData(k string, v interface{}) {
+	if s.ended {
+		return
+	}
+	s.logger.ThreadData(s.id, k, v)
+}
+
+func (s *Session) Tag(tags ...string) {
+	if s.ended {
+		return
+	}
+	s.logger.Tag(s.id, tags...)
+}
+
 func (s *Session) SeenError() bool {
+	return s.SeenLevel(LevelError)
+}
 
-	var ee []*Entry
-	entries, ok := s.logger.logs.Load(s.id)
-	if !ok {
+/*
+SeenLevel reports whether s has logged anything at level or
+above, e.g. SeenLevel(LevelInfo) is true after an error.
+*/
+func (s *Session) SeenLevel(level Level) bool {
+	b := s.logger.shared().threads.lookup(s.id)
+	if b == nil {
 		return false
 	}
-	ee = entries.([]*Entry)
-
-	for _, e := range ee {
-		if e.Level == "Error" {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if level < LevelDebug {
+		level = LevelDebug
+	}
+	for lv := level; lv <= LevelError; lv++ {
+		if b.counts[lv] > 0 {
 			return true
 		}
 	}
 	return false
 }
 
+/*
+ErrorCount returns the number of errors s has logged.
+*/
+func (s *Session) ErrorCount() int {
+	b := s.logger.shared().threads.lookup(s.id)
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.counts[LevelError]
+}
+
+/*
+LastError returns a copy of the most recent error s logged,
+or nil if it hasn't logged any.
+*/
+func (s *Session) LastError() *Entry {
+	b := s.logger.shared().threads.lookup(s.id)
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.lastError == nil {
+		return nil
+	}
+	return b.lastError.copy()
+}
+
+/*
+Entries returns copies of the entries s has logged so far.
+If s is being emitted in chunks only those since the last
+chunk are returned. See SetChunking.
+*/
+func (s *Session) Entries() []*Entry {
+	b := s.logger.shared().threads.lookup(s.id)
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ee := make([]*Entry, len(b.entries))
+	for i, e := range b.entries {
+		ee[i] = e.copy()
+	}
+	return ee
+}
+
+/*
+Len returns the number of entries s has logged, including
+any already emitted in chunks.
+*/
+func (s *Session) Len() int {
+	b := s.logger.shared().threads.lookup(s.id)
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.base + len(b.entries)
+}
+
+func (s *Session) Log(level Level, msg string) *Entry {
+	if s.ended {
+		return discard
+	}
+	return s.logger.logEntry(level, s.id, msg)
+}
+
 func (s *Session) Info(msg string) *Entry {
 	if s.ended {
-		return &Entry{}
+		return discard
 	}
-	return s.logger.logEntry(levelInfo, s.id, msg)
+	return s.logger.logEntry(LevelInfo, s.id, msg)
 }
 func (s *Session) Error(msg string) *Entry {
 	if s.ended {
-		return &Entry{}
+		return discard
 	}
-	return s.logger.logEntry(levelError, s.id, msg)
+	return s.logger.logEntry(LevelError, s.id, msg)
 }
 func (s *Session) Debug(msg string) *Entry {
 	if s.ended {
-		return &Entry{}
+		return discard
 	}
-	return s.logger.logEntry(levelDebug, s.id, msg)
+	return s.logger.logEntry(LevelDebug, s.id, msg)
 }
 
 func (s *Session) InfoF(format string, a ...interface{}) *Entry {
-	if s.ended {
-		return &Entry{}
+	if s.ended || !s.logger.mayLog(LevelInfo) {
+		return discard
 	}
-	return s.logger.logEntry(levelInfo, s.id, fmt.Sprintf(format, a...))
+	return s.logger.logEntry(LevelInfo, s.id, fmt.Sprintf(format, a...))
 }
 func (s *Session) ErrorF(format string, a ...interface{}) *Entry {
-	if s.ended {
-		return &Entry{}
+	if s.ended || !s.logger.mayLog(LevelError) {
+		return discard
 	}
-	return s.logger.logEntry(levelError, s.id, fmt.Sprintf(format, a...))
+	return s.logger.logEntry(LevelError, s.id, fmt.Sprintf(format, a...))
 }
 func (s *Session) DebugF(format string, a ...interface{}) *Entry {
-	if s.ended {
-		return &Entry{}
+	if s.ended || !s.logger.mayLog(LevelDebug) {
+		return discard
 	}
-	return s.logger.logEntry(levelDebug, s.id, fmt.Sprintf(format, a...))
+	return s.logger.logEntry(LevelDebug, s.id, fmt.Sprintf(format, a...))
+}
+
+func (s *Session) InfoT(tmpl string, fields Fields) *Entry {
+	if s.ended || !s.logger.mayLog(LevelInfo) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, s.logger.redactFields(fields))
+	return s.logger.logEntry(LevelInfo, s.id, msg).template(tmpl, kvs)
+}
+func (s *Session) ErrorT(tmpl string, fields Fields) *Entry {
+	if s.ended || !s.logger.mayLog(LevelError) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, s.logger.redactFields(fields))
+	return s.logger.logEntry(LevelError, s.id, msg).template(tmpl, kvs)
+}
+func (s *Session) DebugT(tmpl string, fields Fields) *Entry {
+	if s.ended || !s.logger.mayLog(LevelDebug) {
+		return discard
+	}
+	msg, kvs := interpolate(tmpl, s.logger.redactFields(fields))
+	return s.logger.logEntry(LevelDebug, s.id, msg).template(tmpl, kvs)
 }
 
 /*
@@ -79,11 +237,15 @@ End calls OnError and passes it a Thread containing only
 the error level logs to Session.
 
 If OnError or OnLog were nil nothing will happen.
+
+It returns the thread and whether s was open, i.e. whether
+anything was logged to it. Calling End again returns false.
 */
-func (s *Session) End() {
+func (s *Session) End() (Thread, bool) {
 	if s.ended {
-		return
+		return Thread{}, false
 	}
 	s.ended = true
-	s.logger.end(kindSession, s.id, "", "", s.name, 0)
+	s.stopHeartbeat()
+	return s.logger.end(KindSession, s.id, "", "", s.name, 0)
 }
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+/*
+TestConcurrentEntryOrder logs from several goroutines to one
+shared thread while each also logs to a session of its own.
+Every thread's Entries must be in the order their sequence
+numbers were assigned, and each goroutine's entries in the
+order it logged them.
+*/
+func TestConcurrentEntryOrder(t *testing.T) {
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	l := &Logger{OnLog: func(Thread) error { return nil }}
+	shared := l.NewId()
+
+	const goroutines, entries = 8, 200
+	threads := make([]Thread, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			s := l.Sess(fmt.Sprint("session ", g))
+			for i := 0; i < entries; i++ {
+				msg := fmt.Sprintf("%d %d", g, i)
+				l.Info(shared, msg)
+				s.Info(msg)
+			}
+			t, ok := s.End()
+			if !ok {
+				panic("session didn't end")
+			}
+			threads[g] = t
+		}(g)
+	}
+	wg.Wait()
+	t0, ok := l.End(shared, "", "GET", "/", 0)
+	if !ok {
+		t.Fatal("shared thread didn't end")
+	}
+	if n := len(t0.Entries); n != goroutines*entries {
+		t.Fatalf("shared thread has %d entries, want %d", n, goroutines*entries)
+	}
+
+	seen := map[uint64]bool{}
+	for _, th := range append(threads, t0) {
+		next := make([]int, goroutines)
+		for i, e := range th.Entries {
+			if e.Index() != i {
+				t.Fatalf("thread %s: entry %d has index %d", th.Id, i, e.Index())
+			}
+			if i > 0 && e.Seq <= th.Entries[i-1].Seq {
+				t.Fatalf("thread %s: entry %d has seq %d after %d",
+					th.Id, i, e.Seq, th.Entries[i-1].Seq)
+			}
+			if seen[e.Seq] {
+				t.Fatalf("seq %d assigned twice", e.Seq)
+			}
+			seen[e.Seq] = true
+			var g, n int
+			if _, err := fmt.Sscanf(e.Message, "%d %d", &g, &n); err != nil {
+				t.Fatalf("thread %s: entry %q: %v", th.Id, e.Message, err)
+			}
+			if n != next[g] {
+				t.Fatalf("thread %s: goroutine %d logged entry %d, want %d",
+					th.Id, g, n, next[g])
+			}
+			next[g]++
+		}
+	}
+}
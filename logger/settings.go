@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+/*
+settings are a Logger's options. Once stored they are never
+modified, nor are the slices and maps they refer to: update
+copies them, changes the copy and stores that, so the
+options can be read with a single atomic load on every
+entry however often they are changed, e.g. by AdminHandler.
+State that belongs to a running feature, such as the
+asynchronous dispatcher or the write-ahead log, is kept on
+the Logger under locks of its own.
+*/
+type settings struct {
+	debug       bool
+	level       Level
+	runtime     bool
+	noNormalise bool
+	caser       Caser
+	catalog     Catalog
+	clock       func() time.Time
+	pool        bool
+	precision   Precision
+	humanize    Humanize
+	glyphs      TreeGlyphs
+	backoff     func(int) time.Duration
+	deliveries  int
+	profile     bool
+	snapshot    bool
+	chunkSize   int
+	baggageKeys []string
+	compLevels  map[string]Level
+	redactKeys  []string
+	debugRoutes []string
+	routeFunc   func(*http.Request) string
+	proxies     []*net.IPNet
+	classifiers []Classifier
+	process     []kv
+	build       []kv
+	kube        []kv
+}
+
+// defaults are the settings of a Logger none have been set on.
+var defaults = &settings{}
+
+func (l *Logger) settings() *settings {
+	if s, ok := l.cfg.Load().(*settings); ok {
+		return s
+	}
+	return defaults
+}
+
+/*
+override is a change made to a clone's settings. Those with
+the same key set the same field, so only the last is kept,
+while those with an empty key add to a field, e.g. another
+classifier, and all are kept.
+*/
+type override struct {
+	key string
+	fn  func(s *settings)
+}
+
+/*
+update applies fn to a copy of l's settings and stores the
+copy, then passes the change on to l's clones. If l is a
+clone fn is kept as an override and applied on top of its
+parent's settings, so the fields it sets stay l's own while
+the rest follow the parent. key names the field fn sets, or
+is empty if fn adds to it. Updates are serialised so none
+are lost.
+*/
+func (l *Logger) update(key string, fn func(s *settings)) {
+	l.cfgMu.Lock()
+	defer l.cfgMu.Unlock()
+	if l.parent == nil {
+		s := *l.settings()
+		fn(&s)
+		l.cfg.Store(&s)
+	} else {
+		l.override(key, fn)
+		l.store()
+	}
+	for _, c := range l.clones() {
+		c.inherit()
+	}
+}
+
+func (l *Logger) override(key string, fn func(s *settings)) {
+	if key != "" {
+		for i, o := range l.overrides {
+			if o.key == key {
+				l.overrides = append(l.overrides[:i], l.overrides[i+1:]...)
+				break
+			}
+		}
+	}
+	l.overrides = append(l.overrides, override{key, fn})
+}
+
+/*
+inherit stores l's parent's settings with l's overrides
+applied, and has l's clones do the same.
+*/
+func (l *Logger) inherit() {
+	l.cfgMu.Lock()
+	defer l.cfgMu.Unlock()
+	l.store()
+	for _, c := range l.clones() {
+		c.inherit()
+	}
+}
+
+// store must be called with cfgMu held.
+func (l *Logger) store() {
+	s := *l.parent.settings()
+	for _, o := range l.overrides {
+		o.fn(&s)
+	}
+	l.cfg.Store(&s)
+}
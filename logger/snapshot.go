@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"runtime"
+	"time"
+)
+
+/*
+SetSnapshots makes threads that contain errors carry a
+snapshot of the runtime as thread data when they end: the
+number of goroutines, bytes of heap in use, the number of
+garbage collections and the most recent GC pause. This helps
+correlate failures with resource pressure. Reading memory
+statistics briefly stops the world, so threads without
+errors aren't given one.
+*/
+func (l *Logger) SetSnapshots(enabled bool) {
+	l.update("snapshot", func(s *settings) { s.snapshot = enabled })
+}
+
+func runtimeSnapshot() []kv {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	var pause time.Duration
+	if m.NumGC > 0 {
+		pause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	return []kv{
+		{"goroutines", runtime.NumGoroutine()},
+		{"heap_inuse", m.HeapInuse},
+		{"num_gc", m.NumGC},
+		{"last_gc_pause", pause},
+	}
+}
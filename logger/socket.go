@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+Socket is an io.Writer that sends to a socket, typically a
+Unix domain socket of a local agent. It connects on first
+use and after a failed write, so a restarted agent is picked
+up again. With a datagram network such as unixgram each
+write is sent as one datagram, so each thread written by a
+sink arrives whole.
+*/
+type Socket struct {
+
+	// Network is unix, unixgram or any other accepted by
+	// net.Dial.
+	Network string
+	Addr    string
+
+	// Timeout bounds connecting and each write. It defaults
+	// to 5 seconds.
+	Timeout time.Duration
+
+	// TLS makes a tcp socket connect over TLS if it is set.
+	TLS *TLSOptions
+
+	// Compression compresses each write on its own with the
+	// named content encoding, e.g. gzip. A stream is then a
+	// series of gzip members, which gzip readers such as
+	// Go's and zcat read as one, while a datagram network
+	// has each datagram compressed. See RegisterCompression.
+	Compression string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (s *Socket) Write(p []byte) (int, error) {
+
+	b, err := Compress(s.Compression, p)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if s.conn == nil {
+		conn, err := s.TLS.Dial(s.Network, s.Addr, timeout)
+		if err != nil {
+			return 0, err
+		}
+		s.conn = conn
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(timeout))
+	n, err := s.conn.Write(b)
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	// Only whole writes of compressed bytes can be counted
+	// in bytes of p.
+	if n == len(b) {
+		n = len(p)
+	} else if len(b) != len(p) {
+		n = 0
+	}
+	return n, err
+}
+
+func (s *Socket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
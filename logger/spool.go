@@ -0,0 +1,367 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+ErrSpoolFull is returned by Spool.Write when a thread would
+take the spool past SpoolOptions.MaxBytes.
+*/
+var ErrSpoolFull = errors.New("logger: spool is full")
+
+type SpoolOptions struct {
+
+	// Path is the file threads are queued in. It is created
+	// if it doesn't exist and any threads already in it are
+	// replayed first.
+	Path string
+
+	// MaxBytes caps the size of the queued threads. Zero is
+	// unlimited.
+	MaxBytes int64
+
+	// MaxAge discards queued threads older than it rather
+	// than replaying them. Zero keeps them indefinitely.
+	MaxAge time.Duration
+
+	// Backoff is how long to wait before replaying again
+	// after the sink fails, by the number of failures in a
+	// row from 1. Nil doubles from 100ms up to 10s.
+	Backoff func(attempt int) time.Duration
+}
+
+/*
+Spool sits in front of a sink, typically a network one, and
+queues threads on disk while it is failing. While threads
+are queued Write only appends to the file, and a goroutine
+replays them in order, ahead of new ones, backing off while
+the sink keeps failing. Threads are delivered at least once:
+those replayed shortly before a crash may be replayed again
+once the spool is reopened. Like the dead-letter file data
+values are queued as strings.
+*/
+type Spool struct {
+	sink func(Thread) error
+	opts SpoolOptions
+	stop chan struct{}
+
+	// replayMu is held while replaying, so threads are
+	// replayed once and in order, and while the file is
+	// rewritten.
+	replayMu sync.Mutex
+
+	// mu guards the rest. The queued threads are those
+	// between offset and size.
+	mu        sync.Mutex
+	f         *os.File
+	size      int64
+	offset    int64
+	replaying bool
+	closed    bool
+}
+
+type spoolRecord struct {
+	Time   time.Time `json:"time"`
+	Thread Thread    `json:"thread"`
+}
+
+/*
+NewSpool returns a Spool in front of sink. Its Write method
+has the signature of Logger.OnLog so it can be assigned to it.
+Close stops it replaying.
+*/
+func NewSpool(sink func(Thread) error, opts SpoolOptions) (*Spool, error) {
+	if opts.Path == "" {
+		return nil, errors.New("logger: spool path is empty")
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = defaultBackoff
+	}
+	f, err := os.OpenFile(opts.Path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := terminate(f, info.Size()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	info, err = f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &Spool{
+		sink: sink,
+		opts: opts,
+		stop: make(chan struct{}),
+		f:    f,
+		size: info.Size(),
+	}
+	if s.size > 0 {
+		s.replaying = true
+		go s.run()
+	}
+	return s, nil
+}
+
+/*
+Write passes t to the sink unless threads are queued,
+queueing t instead if they are or the sink fails. It only
+returns an error if t couldn't be queued, so it is retried
+and dead-lettered by the logger as usual.
+*/
+func (s *Spool) Write(t Thread) error {
+	s.mu.Lock()
+	queued := s.size > s.offset
+	s.mu.Unlock()
+	if !queued && s.sink(t) == nil {
+		return nil
+	}
+	return s.queue(t)
+}
+
+/*
+Replay passes queued threads to the sink in order until one
+fails or none are left. They are replayed in the background
+anyway but this doesn't wait for the backoff.
+*/
+func (s *Spool) Replay() error {
+	_, err := s.replay()
+	return err
+}
+
+/*
+Close stops replaying and closes the file. Threads still
+queued stay in it to be replayed by the next Spool opened
+on it.
+*/
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	// Wait for any replay in progress to finish with the
+	// file.
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+	return s.f.Close()
+}
+
+/*
+terminate appends a newline to f, whose size is size, if its
+last record was cut off so threads queued after it don't
+run on from it and get skipped with it.
+*/
+func terminate(f *os.File, size int64) error {
+	if size == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err := f.Write([]byte{'\n'})
+	return err
+}
+
+func (s *Spool) queue(t Thread) error {
+
+	b, err := json.Marshal(spoolRecord{
+		Time:   time.Now(),
+		Thread: printable(t),
+	})
+	if err != nil {
+		return fmt.Errorf("logger: encoding spooled thread: %s", err)
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("logger: spool is closed")
+	}
+	if s.opts.MaxBytes > 0 && s.size-s.offset+int64(len(b)) > s.opts.MaxBytes {
+		return ErrSpoolFull
+	}
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if !s.replaying {
+		s.replaying = true
+		go s.run()
+	}
+	return nil
+}
+
+/*
+run replays queued threads until none are left or the spool
+is closed, backing off after failures.
+*/
+func (s *Spool) run() {
+	attempt := 0
+	for {
+		ok, _ := s.replay()
+
+		// Decide to stop under the same lock queue checks
+		// replaying under so no thread is left behind.
+		s.mu.Lock()
+		if s.size == s.offset || s.closed {
+			s.replaying = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		if ok {
+			attempt = 0
+			continue
+		}
+		attempt++
+		select {
+		case <-time.After(s.opts.Backoff(attempt)):
+		case <-s.stop:
+		}
+	}
+}
+
+/*
+replay reports whether every thread that was queued when it
+began was replayed. Threads the sink accepted or that have
+expired are removed from the queue.
+*/
+func (s *Spool) replay() (bool, error) {
+
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.mu.Lock()
+	f, offset, size, closed := s.f, s.offset, s.size, s.closed
+	s.mu.Unlock()
+	if closed {
+		return false, errors.New("logger: spool is closed")
+	}
+
+	// Nothing but queue changes the file while replayMu is
+	// held and it only appends, so the queued threads can be
+	// read without holding mu.
+	now := time.Now()
+	r := bufio.NewReader(io.NewSectionReader(f, offset, size-offset))
+	ok := true
+	var err error
+	for {
+		// A last record without a newline was cut off, e.g.
+		// by a crash, and is skipped like any other corrupt
+		// one rather than left to be read again forever.
+		line, rerr := r.ReadBytes('\n')
+		if rerr != nil && rerr != io.EOF {
+			ok, err = false, rerr
+			break
+		}
+		if len(line) == 0 {
+			break
+		}
+		var rec spoolRecord
+		if json.Unmarshal(line, &rec) == nil &&
+			(s.opts.MaxAge <= 0 || now.Sub(rec.Time) <= s.opts.MaxAge) &&
+			s.sink(rec.Thread) != nil {
+			ok = false
+			break
+		}
+		offset += int64(len(line))
+		s.mu.Lock()
+		s.offset = offset
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.offset == s.size:
+		if terr := s.truncate(); terr != nil && err == nil {
+			err = terr
+		}
+	case !ok && s.offset > s.size/2:
+		if cerr := s.compact(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return ok, err
+}
+
+/*
+truncate empties the file once every queued thread has been
+replayed. s.mu must be held.
+*/
+func (s *Spool) truncate() error {
+	if err := s.f.Truncate(0); err != nil {
+		return err
+	}
+	s.size, s.offset = 0, 0
+	return nil
+}
+
+/*
+compact rewrites the file with only the queued threads, via
+a temporary file so a crash doesn't lose them, so the
+threads already replayed aren't replayed again after a
+crash and don't take up space. s.mu and s.replayMu must be
+held.
+*/
+func (s *Spool) compact() error {
+
+	b := make([]byte, s.size-s.offset)
+	if _, err := s.f.ReadAt(b, s.offset); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.opts.Path), filepath.Base(s.opts.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.opts.Path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	f, err := os.OpenFile(s.opts.Path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.f.Close()
+	s.f = f
+	s.size, s.offset = int64(len(b)), 0
+	return nil
+}
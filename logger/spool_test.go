@@ -0,0 +1,279 @@
+package logger
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+flakySink is a sink that fails until it is fixed.
+*/
+type flakySink struct {
+	mu     sync.Mutex
+	ok     bool
+	calls  int
+	got    []string
+	arrive chan struct{}
+}
+
+func newFlakySink() *flakySink {
+	return &flakySink{arrive: make(chan struct{}, 100)}
+}
+
+func (s *flakySink) write(t Thread) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if !s.ok {
+		return errors.New("sink is down")
+	}
+	s.got = append(s.got, t.Id)
+	s.arrive <- struct{}{}
+	return nil
+}
+
+func (s *flakySink) set(ok bool) (calls int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ok = ok
+	return s.calls
+}
+
+func (s *flakySink) wait(t *testing.T, n int) []string {
+	for i := 0; i < n; i++ {
+		select {
+		case <-s.arrive:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("sink received %d threads, want %d", i, n)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.got...)
+}
+
+func tempSpool(t *testing.T) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "spool"), func() { os.RemoveAll(dir) }
+}
+
+func noBackoff(int) time.Duration {
+	return time.Millisecond
+}
+
+func TestSpoolReplaysInBackground(t *testing.T) {
+
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	sink := newFlakySink()
+	s, err := NewSpool(sink.write, SpoolOptions{Path: path, Backoff: func(int) time.Duration {
+		return time.Hour
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := s.Write(Thread{Id: id, Kind: KindRequest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the first write tries the sink. The rest queue
+	// behind it, and the replay has tried at most once before
+	// backing off.
+	if calls := sink.set(true); calls > 2 {
+		t.Fatalf("sink was called %d times, want at most 2", calls)
+	}
+	if err := s.Replay(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.wait(t, 3); len(got) != 3 || got[0] != "1" || got[1] != "2" || got[2] != "3" {
+		t.Fatalf("sink received %v, want [1 2 3]", got)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Fatalf("spool wasn't emptied: %v %v", fi.Size(), err)
+	}
+
+	if err := s.Write(Thread{Id: "4", Kind: KindRequest}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.wait(t, 1); got[len(got)-1] != "4" {
+		t.Fatalf("sink received %v, want 4 last", got)
+	}
+}
+
+func TestSpoolReopen(t *testing.T) {
+
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	sink := newFlakySink()
+	s, err := NewSpool(sink.write, SpoolOptions{Path: path, Backoff: noBackoff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := s.Write(Thread{Id: id, Kind: KindRequest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sink.set(true)
+	s, err = NewSpool(sink.write, SpoolOptions{Path: path, Backoff: noBackoff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := sink.wait(t, 2); got[0] != "1" || got[1] != "2" {
+		t.Fatalf("sink received %v, want [1 2]", got)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	sink := newFlakySink()
+	s, err := NewSpool(sink.write, SpoolOptions{Path: path, MaxBytes: 1, Backoff: noBackoff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Write(Thread{Id: "1", Kind: KindRequest}); err != ErrSpoolFull {
+		t.Fatalf("Write returned %v, want ErrSpoolFull", err)
+	}
+}
+
+func TestSpoolCompact(t *testing.T) {
+
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	down, accept := true, map[string]bool{}
+	s, err := NewSpool(func(t Thread) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down || !accept[t.Id] {
+			return errors.New("sink is down")
+		}
+		return nil
+	}, SpoolOptions{Path: path, Backoff: func(int) time.Duration { return time.Hour }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, id := range []string{"1", "2", "3", "4"} {
+		if err := s.Write(Thread{Id: id, Kind: KindRequest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying three of the four threads leaves the last
+	// one alone in the file.
+	mu.Lock()
+	down, accept = false, map[string]bool{"1": true, "2": true, "3": true}
+	mu.Unlock()
+	s.Replay()
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size()/2 {
+		t.Fatalf("spool is %d bytes after replaying 3 of 4 threads, was %d", after.Size(), before.Size())
+	}
+
+	mu.Lock()
+	accept["4"] = true
+	mu.Unlock()
+	if err := s.Replay(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Fatalf("spool wasn't emptied")
+	}
+}
+
+/*
+TestSpoolCutOff opens a spool whose last record was cut off,
+as a crash mid-write leaves it, and checks the record before
+it is replayed, the cut off one skipped and the file emptied
+rather than read again forever.
+*/
+func TestSpoolCutOff(t *testing.T) {
+
+	path, cleanup := tempSpool(t)
+	defer cleanup()
+
+	s, err := NewSpool(func(Thread) error { return errors.New("sink is down") }, SpoolOptions{
+		Path:    path,
+		Backoff: func(int) time.Duration { return time.Hour },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := s.Write(Thread{Id: id, Kind: KindRequest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, fi.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := newFlakySink()
+	sink.set(true)
+	s, err = NewSpool(sink.write, SpoolOptions{Path: path, Backoff: noBackoff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got := sink.wait(t, 1); got[0] != "1" {
+		t.Fatalf("sink received %v, want [1]", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		replaying, size := s.replaying, s.size
+		s.mu.Unlock()
+		if !replaying && size == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("spool still replaying a %d byte file", size)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.Write(Thread{Id: "3", Kind: KindRequest}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.wait(t, 1); len(got) != 2 || got[1] != "3" {
+		t.Fatalf("sink received %v, want [1 3]", got)
+	}
+}
@@ -0,0 +1,214 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+StatusClientClosed is recorded as the status of a request
+whose client disconnected before the response was complete,
+following nginx's convention.
+*/
+const StatusClientClosed = 499
+
+// ErrStreamClosed is returned by Send once the stream has
+// been closed or the client has gone.
+var ErrStreamClosed = errors.New("logger: event stream closed")
+
+/*
+EventStream writes Server-Sent Events to a client and logs
+them to the request's thread, which stays open while the
+stream is. Rather than an entry per event, which would make
+a long-lived stream's thread enormous, the events sent in
+each batch are summarised in one entry.
+*/
+type EventStream struct {
+
+	// BatchEvery is how often the events sent are
+	// summarised in an entry. It defaults to 10 seconds and
+	// is checked as events are sent.
+	BatchEvery time.Duration
+
+	logger  *Logger
+	scope   *RequestScope
+	id      string
+	r       *http.Request
+	w       http.ResponseWriter
+	flusher http.Flusher
+
+	mu         sync.Mutex
+	batchStart time.Time
+	events     int
+	bytes      int
+	names      map[string]int
+	total      int
+	gone       bool
+	closed     bool
+}
+
+/*
+Stream starts an event stream response to r. It logs to the
+request thread if r came through Middleware and otherwise
+opens one with BeginRequest, which Close ends. It returns an
+error if w can't be flushed, since events would otherwise be
+buffered rather than sent.
+
+The thread's duration is that of the stream. If SetMaxAge is
+shorter than streams may last they will be timed out.
+*/
+func (l *Logger) Stream(w http.ResponseWriter, r *http.Request) (*EventStream, error) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("logger: response writer doesn't support flushing")
+	}
+
+	s := &EventStream{
+		logger:     l,
+		id:         RequestId(r.Context()),
+		r:          r,
+		w:          w,
+		flusher:    flusher,
+		batchStart: time.Now(),
+	}
+	if s.id == "" {
+		s.scope = l.BeginRequest(r)
+		s.id = s.scope.Id
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	l.Info(s.id, "event stream opened")
+	return s, nil
+}
+
+/*
+Send writes an event named event, or an unnamed one if event
+is empty, whose data is data. Data with newlines is split
+over several data fields as the format requires. An error
+means the client has gone and the stream should be closed.
+*/
+func (s *EventStream) Send(event, data string) error {
+
+	var b strings.Builder
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.gone {
+		return ErrStreamClosed
+	}
+	if err := s.r.Context().Err(); err != nil {
+		s.gone = true
+		return err
+	}
+	n, err := s.w.Write([]byte(b.String()))
+	if err != nil {
+		s.gone = true
+		return err
+	}
+	s.flusher.Flush()
+
+	s.events++
+	s.bytes += n
+	if s.names == nil {
+		s.names = map[string]int{}
+	}
+	s.names[event]++
+
+	every := s.BatchEvery
+	if every <= 0 {
+		every = 10 * time.Second
+	}
+	if time.Since(s.batchStart) >= every {
+		s.logBatch()
+	}
+	return nil
+}
+
+/*
+Done is closed when the client disconnects.
+*/
+func (s *EventStream) Done() <-chan struct{} {
+	return s.r.Context().Done()
+}
+
+/*
+Close logs the last batch of events and how the stream
+ended. If the client disconnected the request's status is
+StatusClientClosed. If Stream opened the thread, Close ends
+it and returns it as Logger.End would.
+*/
+func (s *EventStream) Close() (Thread, bool) {
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return Thread{}, false
+	}
+	s.closed = true
+	if s.r.Context().Err() != nil {
+		s.gone = true
+	}
+	s.logBatch()
+	gone, total := s.gone, s.total
+	s.mu.Unlock()
+
+	msg := "event stream closed"
+	if gone {
+		msg = "client disconnected from event stream"
+		s.logger.setStatus(s.id, StatusClientClosed, false)
+	}
+	s.logger.Info(s.id, msg).Data("events", total)
+
+	if s.scope == nil {
+		return Thread{}, false
+	}
+	return s.scope.End("", 0)
+}
+
+/*
+logBatch summarises the events sent since the last batch. It
+must be called with s.mu held.
+*/
+func (s *EventStream) logBatch() {
+	now := time.Now()
+	if s.events > 0 {
+		e := s.logger.Info(s.id, "sent events").
+			Data("events", s.events).
+			Data("bytes", s.bytes).
+			Data("over", now.Sub(s.batchStart))
+		names := make([]string, 0, len(s.names))
+		for name := range s.names {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key := name
+			if key == "" {
+				key = "message"
+			}
+			e.Data("event."+key, s.names[name])
+		}
+	}
+	s.total += s.events
+	s.events, s.bytes, s.names = 0, 0, nil
+	s.batchStart = now
+}
@@ -0,0 +1,125 @@
+/*
+Package statsd sends request and session metrics for each
+ended thread to a StatsD or DogStatsD server over UDP, for
+basic dashboards without a metrics library.
+*/
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+Emitter sends, for every request, the counter requests and
+the timer request.duration in milliseconds, and for every
+session the counter sessions. Threads with errors also
+increment errors by the number of them. Use logger.Tee to
+run it alongside another OnLog hook.
+*/
+type Emitter struct {
+
+	// Addr is the server's host:port, typically
+	// localhost:8125.
+	Addr string
+
+	// Prefix is prepended to metric names, e.g. "api.".
+	Prefix string
+
+	// DogStatsD tags metrics with the kind, and for
+	// requests the method, status and route, as well as
+	// Tags. Plain StatsD has no tags. Routes are logged
+	// paths so should be low cardinality to be tagged.
+	DogStatsD bool
+
+	// Tags are added to every metric, e.g. "env:prod".
+	Tags []string
+
+	// NoRoute omits the route tag.
+	NoRoute bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+/*
+Hook sends t's metrics. It has the signature of
+Logger.OnLog so it can be assigned to it or passed to Tee.
+*/
+func (e *Emitter) Hook(t logger.Thread) error {
+
+	var tags []string
+	if e.DogStatsD {
+		tags = append(tags, e.Tags...)
+		tags = append(tags, "kind:"+t.Kind.String())
+		if t.Kind == logger.KindRequest {
+			tags = append(tags, "method:"+t.Method, "status:"+strconv.Itoa(t.Status))
+			if !e.NoRoute {
+				tags = append(tags, "route:"+t.Route)
+			}
+		}
+	}
+
+	var lines []string
+	if t.Kind == logger.KindRequest {
+		lines = append(lines,
+			e.metric("requests", "1", "c", tags),
+			e.metric("request.duration", strconv.FormatFloat(float64(t.Duration)/1e6, 'f', -1, 64), "ms", tags))
+	} else {
+		lines = append(lines, e.metric("sessions", "1", "c", tags))
+	}
+
+	errs := 0
+	for _, en := range t.Entries {
+		if en.Level == logger.LevelError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		lines = append(lines, e.metric("errors", strconv.Itoa(errs), "c", tags))
+	}
+
+	return e.send(strings.Join(lines, "\n"))
+}
+
+func (e *Emitter) metric(name, value, typ string, tags []string) string {
+	s := e.Prefix + name + ":" + value + "|" + typ
+	if len(tags) > 0 {
+		s += "|#" + strings.Join(tags, ",")
+	}
+	return s
+}
+
+func (e *Emitter) send(packet string) error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		conn, err := net.Dial("udp", e.Addr)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+	_, err := e.conn.Write([]byte(packet))
+	return err
+}
+
+/*
+Close closes the connection to the server.
+*/
+func (e *Emitter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
@@ -0,0 +1,108 @@
+package statsd
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+/*
+listen returns a UDP server's address and a function that
+reads the lines of the next packet it receives.
+*/
+func listen(t *testing.T) (addr string, next func() []string, done func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next = func() []string {
+		buf := make([]byte, 1500)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+	return pc.LocalAddr().String(), next, func() { pc.Close() }
+}
+
+func request() logger.Thread {
+	return logger.Thread{
+		Kind:     logger.KindRequest,
+		Method:   "GET",
+		Route:    "/users/:id",
+		Status:   500,
+		Duration: int64(1500 * time.Microsecond),
+		Entries: []*logger.Entry{
+			{Level: logger.LevelInfo}, {Level: logger.LevelError}, {Level: logger.LevelError},
+		},
+	}
+}
+
+func TestEmitterStatsD(t *testing.T) {
+
+	addr, next, done := listen(t)
+	defer done()
+	e := &Emitter{Addr: addr, Prefix: "api.", Tags: []string{"env:prod"}}
+	defer e.Close()
+
+	if err := e.Hook(request()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"api.requests:1|c",
+		"api.request.duration:1.5|ms",
+		"api.errors:2|c",
+	}
+	if got := next(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("server received %q, want %q", got, want)
+	}
+
+	if err := e.Hook(logger.Thread{Kind: logger.KindSession}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := next(), []string{"api.sessions:1|c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("server received %q, want %q", got, want)
+	}
+}
+
+func TestEmitterDogStatsD(t *testing.T) {
+
+	addr, next, done := listen(t)
+	defer done()
+	e := &Emitter{Addr: addr, DogStatsD: true, Tags: []string{"env:prod"}}
+	defer e.Close()
+
+	if err := e.Hook(request()); err != nil {
+		t.Fatal(err)
+	}
+	tags := "|#env:prod,kind:request,method:GET,status:500,route:/users/:id"
+	want := []string{
+		"requests:1|c" + tags,
+		"request.duration:1.5|ms" + tags,
+		"errors:2|c" + tags,
+	}
+	if got := next(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("server received %q, want %q", got, want)
+	}
+
+	e.NoRoute = true
+	if err := e.Hook(logger.Thread{Kind: logger.KindRequest, Method: "POST", Status: 201}); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); strings.Contains(got[0], "route:") {
+		t.Fatalf("server received %q with NoRoute set", got)
+	}
+
+	if err := e.Hook(logger.Thread{Kind: logger.KindSession}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := next(), []string{"sessions:1|c|#env:prod,kind:session"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("server received %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+const shardCount = 32
+
+/*
+buffer holds everything recorded against a thread that
+hasn't ended yet. Once closed it no longer accepts entries.
+*/
+type buffer struct {
+	mu      sync.Mutex
+	entries []*Entry
+	meta    meta
+	data    []kv
+	tags    []string
+	debug   bool
+	closed  bool
+
+	// reported is the number of entries as of the last
+	// progress snapshot.
+	reported int
+
+	// base is the number of entries emitted in chunks.
+	base int
+
+	// counts is the number of entries logged at each level,
+	// including any emitted in chunks.
+	counts [LevelError + 1]int
+
+	// lastError is the most recent error entry.
+	lastError *Entry
+
+	// opened is when the thread was first seen by the
+	// max age check. See SetMaxAge.
+	opened time.Time
+
+	// started is when the buffer was created. It carries a
+	// monotonic reading so durations measured from it aren't
+	// thrown off by the wall clock being stepped.
+	started time.Time
+
+	// begun is whether the thread was opened with Begin, in
+	// which case its duration is always measured.
+	begun bool
+}
+
+type shard struct {
+	mu      sync.Mutex
+	buffers map[string]*buffer
+}
+
+/*
+store maps thread ids to their buffers. It is split into
+shards, each with their own lock, so that unrelated threads
+rarely contend with each other.
+*/
+type store struct {
+	shards [shardCount]shard
+}
+
+func (s *store) shard(id string) *shard {
+
+	// FNV-1a, inlined to avoid allocating a hash.Hash.
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+
+	return &s.shards[h%shardCount]
+}
+
+/*
+buffer returns the buffer for id, creating it if necessary.
+*/
+func (s *store) buffer(id string) *buffer {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	b, ok := sh.buffers[id]
+	if !ok {
+		if sh.buffers == nil {
+			sh.buffers = map[string]*buffer{}
+		}
+		b = &buffer{started: time.Now()}
+		sh.buffers[id] = b
+	}
+	return b
+}
+
+/*
+lookup returns the buffer for id or nil if there isn't one.
+*/
+func (s *store) lookup(id string) *buffer {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	b := sh.buffers[id]
+	sh.mu.Unlock()
+	return b
+}
+
+/*
+remove deletes the buffer for id and returns it, or nil if
+there wasn't one.
+*/
+func (s *store) remove(id string) *buffer {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	b := sh.buffers[id]
+	delete(sh.buffers, id)
+	sh.mu.Unlock()
+	return b
+}
+
+/*
+each calls fn for every buffer in s. A shard's lock isn't
+held while calling fn.
+*/
+func (s *store) each(fn func(id string, b *buffer)) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		ids := make([]string, 0, len(sh.buffers))
+		bufs := make([]*buffer, 0, len(sh.buffers))
+		for id, b := range sh.buffers {
+			ids = append(ids, id)
+			bufs = append(bufs, b)
+		}
+		sh.mu.Unlock()
+		for j := range ids {
+			fn(ids[j], bufs[j])
+		}
+	}
+}
+
+/*
+append adds e to b, reporting false if b was closed by
+the thread ending after b was looked up. Once e has its
+place, and before it is visible to anything else, it is
+passed to wal, so its record is written before any other
+record about it and before its thread's end.
+*/
+func (b *buffer) append(e *Entry, seq func() uint64, wal func(*Entry)) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	e.Seq = seq()
+	e.buf = b
+	e.index = b.base + len(b.entries)
+	wal(e)
+	b.entries = append(b.entries, e)
+	if e.Level > 0 && e.Level <= LevelError {
+		b.counts[e.Level]++
+	}
+	if e.Level == LevelError {
+		b.lastError = e
+	}
+	return true
+}
+
+/*
+close stops b accepting entries and returns the ones it has.
+*/
+func (b *buffer) close() (ee []*Entry, m meta, data []kv, tags []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return b.entries, b.meta, b.data, b.tags
+}
+
+/*
+setStatus sets b's status, unless onlyIfUnset is true and
+it already has one. It reports whether the status was set.
+*/
+func (b *buffer) setStatus(code int, onlyIfUnset bool, wal func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if onlyIfUnset && b.meta.status != 0 {
+		return
+	}
+	b.meta.status = code
+	wal()
+}
@@ -0,0 +1,22 @@
+package logger
+
+/*
+Tee returns a hook, such as for OnLog, that passes each
+thread to every one of hooks in order. Every hook is called
+even if one fails and the first error is returned, so if the
+logger retries the thread the others receive it again.
+*/
+func Tee(hooks ...func(Thread) error) func(Thread) error {
+	if len(hooks) == 1 {
+		return hooks[0]
+	}
+	return func(t Thread) error {
+		var first error
+		for _, hook := range hooks {
+			if err := hook(t); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Fields holds the values referenced by a message template.
+*/
+type Fields map[string]interface{}
+
+/*
+interpolate replaces each {key} in tmpl with the matching
+value in fields. Keys without a value are left as they are.
+It returns the fields as key/vals, ordered first by their
+appearance in tmpl and then alphabetically.
+*/
+func interpolate(tmpl string, fields Fields) (string, []kv) {
+
+	var b strings.Builder
+	var kvs []kv
+	seen := map[string]bool{}
+
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end == -1 {
+			break
+		}
+		end += start
+
+		key := tmpl[start+1 : end]
+		val, ok := fields[key]
+		if !ok {
+			b.WriteString(tmpl[:end+1])
+			tmpl = tmpl[end+1:]
+			continue
+		}
+
+		b.WriteString(tmpl[:start])
+		b.WriteString(fmt.Sprint(val))
+		tmpl = tmpl[end+1:]
+
+		if !seen[key] {
+			seen[key] = true
+			kvs = append(kvs, kv{key, val})
+		}
+	}
+	b.WriteString(tmpl)
+
+	var rest []string
+	for k := range fields {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		kvs = append(kvs, kv{k, fields[k]})
+	}
+
+	return b.String(), kvs
+}
+
+/*
+Catalog maps the message key of an entry to a localised
+or canonical message. The key is the message exactly as it
+was logged, or the template for templated messages. Any
+{key} in the returned message is filled in from the entry's
+data. If ok is false the entry's message is used instead.
+*/
+type Catalog func(key string) (msg string, ok bool)
+
+func (e *Entry) template(tmpl string, kvs []kv) *Entry {
+	e.modify(func() {
+		e.Key = tmpl
+		e.KeyVals = append(e.KeyVals, kvs...)
+		e.logger.walData(e, tmpl, kvs...)
+	})
+	return e
+}
+
+func (t Thread) message(e *Entry) string {
+	if t.catalog == nil || e.Key == "" {
+		return e.Message
+	}
+	msg, ok := t.catalog(e.Key)
+	if !ok {
+		return e.Message
+	}
+	fields := Fields{}
+	for _, kv := range e.KeyVals {
+		fields[kv.Key] = kv.Val
+	}
+	msg, _ = interpolate(msg, fields)
+	return msg
+}
@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+type ThrottleOptions struct {
+
+	// Limit is the number of request threads emitted per
+	// client in each Window. Further threads are suppressed.
+	// If it is zero throttling is disabled.
+	Limit int
+
+	// Window defaults to a minute.
+	Window time.Duration
+
+	// Key identifies the client a thread belongs to. It
+	// defaults to the host part of Thread.Ip. Threads for
+	// which it returns an empty string aren't throttled.
+	Key func(Thread) string
+
+	// KeepErrors exempts threads with errors from throttling
+	// but they still count towards the limit.
+	KeepErrors bool
+}
+
+/*
+SetThrottle limits how many request threads each client can
+have emitted per window so one client hammering an endpoint
+can't flood storage. Once a client's window has passed a
+session is emitted in place of its suppressed threads, with
+an entry like "12 suppressed threads from 192.0.2.1" and the
+tag "throttled". Windows are checked periodically, so a
+summary may be emitted up to a tenth of Window late even if
+the client sends nothing more. Sessions aren't throttled
+and suppressed threads are counted by Stats.Throttled.
+*/
+func (l *Logger) SetThrottle(opts ThrottleOptions) {
+
+	var t *throttle
+	if opts.Limit > 0 {
+		if opts.Window <= 0 {
+			opts.Window = time.Minute
+		}
+		if opts.Key == nil {
+			opts.Key = clientIp
+		}
+		t = &throttle{
+			opts:    opts,
+			clients: map[string]*client{},
+			stop:    make(chan struct{}),
+		}
+	}
+
+	l.throttleMu.Lock()
+	old := l.throttle
+	l.throttle = t
+	l.throttleMu.Unlock()
+
+	if old != nil {
+		close(old.stop)
+		l.summarise(old.sweep(time.Time{}))
+	}
+	if t != nil {
+		go l.throttleLoop(t)
+	}
+}
+
+/*
+throttleLoop emits the summaries of clients whose window has
+passed, which allow would otherwise only notice when another
+request thread arrives.
+*/
+func (l *Logger) throttleLoop(th *throttle) {
+	every := th.opts.Window / 10
+	if every <= 0 {
+		every = th.opts.Window
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-th.stop:
+			return
+		case <-ticker.C:
+			l.summarise(th.sweep(l.now()))
+		}
+	}
+}
+
+type throttle struct {
+	opts      ThrottleOptions
+	mu        sync.Mutex
+	clients   map[string]*client
+	lastSweep time.Time
+	stop      chan struct{}
+}
+
+type client struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+type suppression struct {
+	key   string
+	count int
+}
+
+/*
+allow reports whether t may be emitted at now. It also
+returns the suppressions of clients whose window has passed
+since they were last checked.
+*/
+func (th *throttle) allow(t Thread, now time.Time) (bool, []suppression) {
+
+	key := th.opts.Key(t)
+	if key == "" {
+		return true, nil
+	}
+
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	var expired []suppression
+	if now.Sub(th.lastSweep) >= th.opts.Window {
+		expired = th.sweepLocked(now)
+	}
+
+	c := th.clients[key]
+	if c == nil {
+		c = &client{start: now}
+		th.clients[key] = c
+	}
+	c.count++
+	if c.count <= th.opts.Limit || th.opts.KeepErrors && t.hasError() {
+		return true, expired
+	}
+	c.suppressed++
+	return false, expired
+}
+
+/*
+sweep forgets clients whose window has passed at now, or all
+of them if now is zero, and returns their suppressions.
+*/
+func (th *throttle) sweep(now time.Time) []suppression {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	return th.sweepLocked(now)
+}
+
+func (th *throttle) sweepLocked(now time.Time) []suppression {
+	var expired []suppression
+	for key, c := range th.clients {
+		if !now.IsZero() && now.Sub(c.start) < th.opts.Window {
+			continue
+		}
+		if c.suppressed > 0 {
+			expired = append(expired, suppression{key, c.suppressed})
+		}
+		delete(th.clients, key)
+	}
+	th.lastSweep = now
+	return expired
+}
+
+/*
+throttled reports whether t should be suppressed, emitting
+summaries for any clients whose window has passed.
+*/
+func (l *Logger) throttled(t Thread) bool {
+
+	if t.Kind != KindRequest {
+		return false
+	}
+
+	l.throttleMu.Lock()
+	th := l.throttle
+	l.throttleMu.Unlock()
+	if th == nil {
+		return false
+	}
+
+	ok, expired := th.allow(t, t.Date)
+	l.summarise(expired)
+	return !ok
+}
+
+/*
+suppress discards t because its client is being throttled.
+*/
+func (l *Logger) suppress(t Thread) {
+	l.statsMu.Lock()
+	l.suppressed++
+	l.statsMu.Unlock()
+	if t.pooled {
+		recycle(t.Entries)
+	}
+}
+
+func (l *Logger) summarise(expired []suppression) {
+	settings := l.settings()
+	for _, s := range expired {
+		msg := fmt.Sprintf("%d suppressed threads from %s", s.count, s.key)
+		if !settings.noNormalise {
+			msg = settings.normalise(msg)
+		}
+		e := &Entry{
+			Level:   LevelInfo,
+			Message: msg,
+			Key:     "suppressed threads",
+			KeyVals: []kv{{"client", s.key}, {"suppressed", s.count}},
+		}
+		id := l.NewId()
+		e.ThreadId = id
+		l.dispatch(Thread{
+			Date:    l.now(),
+			Kind:    KindSession,
+			Id:      id,
+			Entries: []*Entry{e},
+			Tags:    []string{"throttled"},
+			catalog: settings.catalog,
+		})
+	}
+}
+
+func (t Thread) hasError() bool {
+	for _, e := range t.Entries {
+		if e.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+func clientIp(t Thread) string {
+	host, _, err := net.SplitHostPort(t.Ip)
+	if err != nil {
+		return t.Ip
+	}
+	return host
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+/*
+TestThrottleSummaryTicker checks a client's summary is
+emitted once its window passes even though it sends nothing
+more to trigger it.
+*/
+func TestThrottleSummaryTicker(t *testing.T) {
+
+	threads := make(chan Thread, 10)
+	l := &Logger{OnLog: func(t Thread) error {
+		threads <- t
+		return nil
+	}}
+	defer l.Close()
+	l.SetThrottle(ThrottleOptions{Limit: 1, Window: 100 * time.Millisecond})
+
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		l.BeginRequest(r).End("/", 200)
+	}
+
+	if th := <-threads; th.Kind != KindRequest {
+		t.Fatalf("got a %s thread, want the first request", th.Kind)
+	}
+	select {
+	case th := <-threads:
+		if len(th.Tags) != 1 || th.Tags[0] != "throttled" {
+			t.Fatalf("got thread with tags %v, want the summary", th.Tags)
+		}
+		if v, _ := th.Entries[0].KeyVals[1].Val.(int); v != 2 {
+			t.Fatalf("summary counts %d suppressed threads, want 2", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no summary was emitted after the window passed")
+	}
+}
@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"time"
+)
+
+/*
+Timer measures an operation within a thread. See Start.
+*/
+type Timer struct {
+	logger   *Logger
+	threadId string
+	name     string
+	start    time.Time
+	stopped  bool
+}
+
+/*
+Start starts timing name within the thread reqId. Calling
+Stop on the returned Timer logs name with the time elapsed
+as "elapsed".
+*/
+func (l *Logger) Start(reqId, name string) *Timer {
+	return &Timer{
+		logger:   l,
+		threadId: reqId,
+		name:     name,
+		start:    l.now(),
+	}
+}
+
+/*
+Start is like Logger.Start but for s.
+*/
+func (s *Session) Start(name string) *Timer {
+	if s.ended {
+		return &Timer{stopped: true}
+	}
+	return s.logger.Start(s.id, name)
+}
+
+/*
+Stop logs t's name as info with the time elapsed since Start
+attached as "elapsed". Only the first call logs anything.
+*/
+func (t *Timer) Stop() *Entry {
+	if t.stopped {
+		return discard
+	}
+	t.stopped = true
+	return t.logger.logEntry(LevelInfo, t.threadId, t.name).
+		Data("elapsed", t.logger.now().Sub(t.start))
+}
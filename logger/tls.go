@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+/*
+TLSOptions configures how network sinks verify the servers
+they connect to and, for mutual TLS, identify themselves.
+*/
+type TLSOptions struct {
+
+	// CAFile is a PEM bundle of the certificate authorities
+	// to trust instead of the system's.
+	CAFile string `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+
+	// ServerName is checked against the server's certificate
+	// instead of the host being dialled.
+	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+
+	// CertFile and KeyFile are the PEM encoded certificate
+	// and private key presented to servers that require
+	// client certificates. Both or neither must be set.
+	CertFile string `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+
+	// InsecureSkipVerify disables verification entirely. It
+	// should only be used for testing.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+}
+
+/*
+Config returns the tls.Config described by o.
+*/
+func (o TLSOptions) Config() (*tls.Config, error) {
+
+	c := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("logger: no certificates found in %s", o.CAFile)
+		}
+		c.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return nil, fmt.Errorf("logger: certFile and keyFile must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+/*
+Dial connects to addr on the named network within timeout,
+over TLS configured by o unless o is nil. The server's
+certificate is checked against the host in addr unless
+ServerName is set.
+*/
+func (o *TLSOptions) Dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+	if o == nil {
+		return net.DialTimeout(network, addr, timeout)
+	}
+	c, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, addr, c)
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger/internal/testcert"
+)
+
+/*
+TestSocketTLS writes to a tcp Socket over mutual TLS and
+checks the server receives the bytes and the client's
+certificate.
+*/
+func TestSocketTLS(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, cert, err := testcert.Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(mustParse(t, cert))
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		b := make([]byte, 5)
+		if _, err := conn.Read(b); err != nil {
+			received <- err.Error()
+			return
+		}
+		received <- string(b)
+	}()
+
+	s := &Socket{
+		Network: "tcp",
+		Addr:    ln.Addr().String(),
+		TLS:     &TLSOptions{CAFile: certFile, CertFile: certFile, KeyFile: keyFile},
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "hello" {
+		t.Fatalf("server received %q", got)
+	}
+}
+
+func TestSocketTLSUntrusted(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, _, cert, err := testcert.Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	s := &Socket{Network: "tcp", Addr: ln.Addr().String(), TLS: &TLSOptions{}}
+	defer s.Close()
+	if _, err := s.Write([]byte("hello")); err == nil {
+		t.Fatal("wrote to a server whose certificate isn't trusted")
+	}
+}
+
+func mustParse(t *testing.T, cert tls.Certificate) *x509.Certificate {
+	c, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,206 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+/*
+TraceContext identifies the distributed trace a request
+belongs to. Middleware extracts it from incoming headers,
+derives a span for the request as a child of the caller's
+and stores that against the request thread under MetaTrace.
+Transport propagates it on outgoing requests, each of which
+is a child of the request's span.
+*/
+type TraceContext struct {
+	TraceId      string
+	SpanId       string
+	ParentSpanId string
+
+	// Sampled is nil if the caller deferred the sampling
+	// decision.
+	Sampled *bool
+}
+
+const traceKey ctxKey = 1
+
+/*
+Trace returns the trace context of the request that ctx
+belongs to, if Middleware found one in its headers. Its span
+is the request's own, with the caller's as the parent.
+*/
+func Trace(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceKey).(TraceContext)
+	return tc, ok
+}
+
+/*
+ExtractB3 reads a trace context from Zipkin's B3 headers,
+accepting both the single b3 header and the multi-header
+X-B3-* form. It returns false if neither is present or the
+ids are malformed.
+*/
+func ExtractB3(h http.Header) (TraceContext, bool) {
+
+	if single := h.Get("b3"); single != "" {
+		return parseB3(single)
+	}
+
+	tc := TraceContext{
+		TraceId:      strings.ToLower(h.Get("X-B3-TraceId")),
+		SpanId:       strings.ToLower(h.Get("X-B3-SpanId")),
+		ParentSpanId: strings.ToLower(h.Get("X-B3-ParentSpanId")),
+	}
+	if !validTraceId(tc.TraceId) || !validSpanId(tc.SpanId) {
+		return TraceContext{}, false
+	}
+	if tc.ParentSpanId != "" && !validSpanId(tc.ParentSpanId) {
+		return TraceContext{}, false
+	}
+	if h.Get("X-B3-Flags") == "1" {
+		tc.Sampled = sampled(true)
+	} else if s := h.Get("X-B3-Sampled"); s != "" {
+		tc.Sampled = sampled(s == "1" || s == "true")
+	}
+	return tc, true
+}
+
+func parseB3(s string) (TraceContext, bool) {
+
+	// A lone sampling decision carries no ids to propagate.
+	parts := strings.Split(strings.ToLower(s), "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		return TraceContext{}, false
+	}
+
+	tc := TraceContext{TraceId: parts[0], SpanId: parts[1]}
+	if !validTraceId(tc.TraceId) || !validSpanId(tc.SpanId) {
+		return TraceContext{}, false
+	}
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "1", "d":
+			tc.Sampled = sampled(true)
+		case "0":
+			tc.Sampled = sampled(false)
+		default:
+			return TraceContext{}, false
+		}
+	}
+	if len(parts) > 3 {
+		if !validSpanId(parts[3]) {
+			return TraceContext{}, false
+		}
+		tc.ParentSpanId = parts[3]
+	}
+	return tc, true
+}
+
+/*
+InjectB3 writes tc to h as B3 multi-headers.
+*/
+func InjectB3(h http.Header, tc TraceContext) {
+	h.Set("X-B3-TraceId", tc.TraceId)
+	h.Set("X-B3-SpanId", tc.SpanId)
+	if tc.ParentSpanId != "" {
+		h.Set("X-B3-ParentSpanId", tc.ParentSpanId)
+	}
+	if tc.Sampled != nil {
+		s := "0"
+		if *tc.Sampled {
+			s = "1"
+		}
+		h.Set("X-B3-Sampled", s)
+	}
+}
+
+/*
+Child returns the context of a span made on behalf of tc,
+which has its own span id with tc's as the parent.
+*/
+func (tc TraceContext) Child() TraceContext {
+	return TraceContext{
+		TraceId:      tc.TraceId,
+		SpanId:       newSpanId(),
+		ParentSpanId: tc.SpanId,
+		Sampled:      tc.Sampled,
+	}
+}
+
+/*
+Transport returns a RoundTripper that propagates the trace
+context of each request's context as B3 headers and logs the
+outgoing call on the request thread, if there is one. A nil
+base uses http.DefaultTransport.
+*/
+func (l *Logger) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{logger: l, base: base}
+}
+
+type transport struct {
+	logger *Logger
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	ctx := r.Context()
+	if tc, ok := Trace(ctx); ok {
+		// RoundTrippers mustn't modify the caller's request.
+		r = r.Clone(ctx)
+		InjectB3(r.Header, tc.Child())
+	}
+
+	resp, err := t.base.RoundTrip(r)
+
+	reqId := RequestId(ctx)
+	if reqId == "" {
+		return resp, err
+	}
+	if err != nil {
+		t.logger.Error(reqId, "outgoing request failed").
+			Data("method", r.Method).
+			Data("url", r.URL.String()).
+			Data("err", err)
+		return resp, err
+	}
+	t.logger.Debug(reqId, "outgoing request").
+		Data("method", r.Method).
+		Data("url", r.URL.String()).
+		Data("status", resp.StatusCode)
+	return resp, err
+}
+
+func sampled(b bool) *bool {
+	return &b
+}
+
+func newSpanId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validTraceId(id string) bool {
+	return (len(id) == 16 || len(id) == 32) && isHex(id)
+}
+
+func validSpanId(id string) bool {
+	return len(id) == 16 && isHex(id)
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
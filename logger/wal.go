@@ -0,0 +1,398 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+walRecord is a single line of the write-ahead log.
+*/
+type walRecord struct {
+	Op       string      `json:"op"`
+	Thread   string      `json:"thread"`
+	Time     int64       `json:"time"`
+	Entry    int         `json:"entry,omitempty"`
+	Seq      uint64      `json:"seq,omitempty"`
+	Level    Level       `json:"level,omitempty"`
+	Message  string      `json:"msg,omitempty"`
+	Key      string      `json:"key,omitempty"`
+	Function string      `json:"func,omitempty"`
+	File     string      `json:"file,omitempty"`
+	Line     int         `json:"line,omitempty"`
+	Data     [][2]string `json:"data,omitempty"`
+	Status   int         `json:"status,omitempty"`
+}
+
+const (
+	walOpEntry  = "entry"
+	walOpData   = "data"
+	walOpStatus = "status"
+	walOpEnd    = "end"
+)
+
+/*
+walCompactSize is the least size the WAL is compacted at.
+*/
+const walCompactSize = 1 << 20
+
+type wal struct {
+	mu   sync.Mutex
+	f    *os.File
+	path string
+	open map[string]bool
+
+	// size is the size of the file and compactAt the size it
+	// is next compacted at.
+	size      int64
+	compactAt int64
+}
+
+/*
+SetWAL makes the logger append everything it records to
+the file at path as it happens. If the process crashes the
+threads that were open can be recovered: SetWAL first emits
+any threads left open in an existing file at path, marked
+Unterminated, before truncating it. Since a thread's kind
+isn't known until it ends they are emitted as sessions.
+
+The file is truncated whenever no threads are open. So that
+long-lived threads don't keep it growing while others end,
+once it has doubled in size since it was last compacted,
+and is at least 1MB, it is rewritten with only the records
+of the threads still open. An empty path disables the WAL.
+*/
+func (l *Logger) SetWAL(path string) error {
+
+	var w *wal
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		w = &wal{
+			f:         f,
+			path:      path,
+			open:      map[string]bool{},
+			compactAt: walCompactSize,
+		}
+		if err := l.recoverWAL(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := w.truncate(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	l.walMu.Lock()
+	old := l.wal
+	l.wal = w
+	l.walMu.Unlock()
+
+	if old != nil {
+		old.mu.Lock()
+		old.f.Close()
+		old.mu.Unlock()
+	}
+	return nil
+}
+
+func (l *Logger) recoverWAL(r io.Reader) error {
+
+	type pending struct {
+		thread  Thread
+		entries map[int]*Entry
+	}
+	var order []string
+	threads := map[string]*pending{}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+
+		var rec walRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+
+			// The last line may have been cut short by the
+			// crash, so ignore anything unreadable.
+			continue
+		}
+
+		if rec.Op == walOpEnd {
+			delete(threads, rec.Thread)
+			continue
+		}
+
+		p, ok := threads[rec.Thread]
+		if !ok {
+			p = &pending{
+				thread: Thread{
+					Id:           rec.Thread,
+					Kind:         KindSession,
+					Unterminated: true,
+					catalog:      l.settings().catalog,
+				},
+				entries: map[int]*Entry{},
+			}
+			threads[rec.Thread] = p
+			order = append(order, rec.Thread)
+		}
+		p.thread.Date = time.Unix(0, rec.Time)
+
+		switch rec.Op {
+		case walOpEntry:
+			e := &Entry{
+				ThreadId: rec.Thread,
+				Level:    rec.Level,
+				Message:  rec.Message,
+				Key:      rec.Key,
+				Function: rec.Function,
+				File:     rec.File,
+				Line:     rec.Line,
+				Seq:      rec.Seq,
+				Time:     time.Unix(0, rec.Time),
+				index:    rec.Entry,
+			}
+			for _, d := range rec.Data {
+				e.KeyVals = append(e.KeyVals, kv{d[0], d[1]})
+			}
+			p.entries[rec.Entry] = e
+			p.thread.Entries = append(p.thread.Entries, e)
+		case walOpData:
+			e, ok := p.entries[rec.Entry]
+			if !ok {
+				continue
+			}
+			if rec.Key != "" {
+				e.Key = rec.Key
+			}
+			for _, d := range rec.Data {
+				e.KeyVals = append(e.KeyVals, kv{d[0], d[1]})
+			}
+		case walOpStatus:
+			p.thread.Status = rec.Status
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	// An id appears in order twice if its thread ended and
+	// was logged to again, so delete threads as they're
+	// emitted to avoid emitting one twice.
+	for _, id := range order {
+		p, ok := threads[id]
+		if !ok {
+			continue
+		}
+		delete(threads, id)
+
+		// Entries logged concurrently may have been written
+		// out of order.
+		ee := p.thread.Entries
+		sort.SliceStable(ee, func(i, j int) bool {
+			return ee[i].index < ee[j].index
+		})
+		l.dispatch(p.thread)
+	}
+
+	return nil
+}
+
+/*
+walEntry writes e and the data it was logged with. It is
+called by buffer.append so the record precedes anything
+else written about e or its thread's end.
+*/
+func (l *Logger) walEntry(e *Entry) {
+	l.walWrite(walRecord{
+		Op:       walOpEntry,
+		Thread:   e.ThreadId,
+		Entry:    e.index,
+		Seq:      e.Seq,
+		Level:    e.Level,
+		Message:  e.Message,
+		Key:      e.Key,
+		Function: e.Function,
+		File:     e.File,
+		Line:     e.Line,
+		Data:     walKeyVals(e.KeyVals),
+	})
+}
+
+func (l *Logger) walData(e *Entry, key string, kvs ...kv) {
+
+	// Entries without a buffer are copies, e.g. from Open,
+	// and changing them doesn't change their thread.
+	if l == nil || e.buf == nil {
+		return
+	}
+	l.walWrite(walRecord{
+		Op:     walOpData,
+		Thread: e.ThreadId,
+		Entry:  e.index,
+		Key:    key,
+		Data:   walKeyVals(kvs),
+	})
+}
+
+func walKeyVals(kvs []kv) [][2]string {
+	var data [][2]string
+	for _, kv := range kvs {
+		data = append(data, [2]string{kv.Key, fmt.Sprint(kv.Val)})
+	}
+	return data
+}
+
+func (l *Logger) walStatus(threadId string, code int) {
+	l.walWrite(walRecord{
+		Op:     walOpStatus,
+		Thread: threadId,
+		Status: code,
+	})
+}
+
+func (l *Logger) walEnd(threadId string) {
+	l.walWrite(walRecord{
+		Op:     walOpEnd,
+		Thread: threadId,
+	})
+}
+
+func (l *Logger) walWrite(rec walRecord) {
+
+	l.walMu.Lock()
+	w := l.wal
+	l.walMu.Unlock()
+	if w == nil {
+		return
+	}
+
+	rec.Time = time.Now().UnixNano()
+	b, err := json.Marshal(rec)
+	if err != nil {
+		l.internalError(fmt.Errorf("logger: encoding WAL record: %s", err))
+		return
+	}
+	b = append(b, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if rec.Op == walOpEnd {
+		if !w.open[rec.Thread] {
+			return
+		}
+		delete(w.open, rec.Thread)
+		if len(w.open) == 0 {
+			if err := w.truncate(); err != nil {
+				l.internalError(fmt.Errorf("logger: truncating WAL: %s", err))
+			}
+			return
+		}
+	} else {
+		w.open[rec.Thread] = true
+	}
+
+	n, err := w.f.Write(b)
+	w.size += int64(n)
+	if err != nil {
+		l.internalError(fmt.Errorf("logger: writing WAL: %s", err))
+		return
+	}
+	if w.size >= w.compactAt {
+		if err := w.compact(); err != nil {
+			l.internalError(fmt.Errorf("logger: compacting WAL: %s", err))
+		}
+	}
+}
+
+func (w *wal) truncate() error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	w.size = 0
+	_, err := w.f.Seek(0, io.SeekStart)
+	return err
+}
+
+/*
+compact replaces the file with one holding only the records
+of open threads, in the same order. The new file is written
+alongside and renamed over the old so a crash part way
+through leaves one or the other intact.
+*/
+func (w *wal) compact() error {
+
+	type line struct {
+		thread string
+		ends   int
+		b      []byte
+	}
+	var lines []line
+	ends := map[string]int{}
+
+	// Read without moving the file's offset so that if
+	// compacting fails writes carry on at the end.
+	sc := bufio.NewScanner(io.NewSectionReader(w.f, 0, w.size))
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var rec walRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Op == walOpEnd {
+			ends[rec.Thread]++
+			continue
+		}
+		b := append([]byte(nil), sc.Bytes()...)
+		lines = append(lines, line{rec.Thread, ends[rec.Thread], append(b, '\n')})
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	tmp := w.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, ln := range lines {
+
+		// Records from before a thread's id was last ended
+		// belong to an earlier thread.
+		if !w.open[ln.thread] || ln.ends != ends[ln.thread] {
+			continue
+		}
+		n, err := f.Write(ln.b)
+		size += int64(n)
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	w.f.Close()
+	w.f = f
+	w.size = size
+	w.compactAt = 2 * size
+	if w.compactAt < walCompactSize {
+		w.compactAt = walCompactSize
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func tempWAL(t *testing.T) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "wal"), func() { os.RemoveAll(dir) }
+}
+
+/*
+TestWALEndOrder logs to threads while they are being ended.
+Every record about a thread must precede its end, or the WAL
+would consider the thread open forever and never truncate.
+*/
+func TestWALEndOrder(t *testing.T) {
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	path, cleanup := tempWAL(t)
+	defer cleanup()
+
+	l := &Logger{
+		OnLog: func(Thread) error { return nil },
+
+		// Data on entries whose thread has just ended is
+		// reported here.
+		OnInternalError: func(error) {},
+	}
+	if err := l.SetWAL(path); err != nil {
+		t.Fatal(err)
+	}
+	defer l.SetWAL("")
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				id := l.NewId()
+				logged := make(chan struct{})
+				go func() {
+					defer close(logged)
+					for j := 0; j < 4; j++ {
+						l.Info(id, "entry").Data("k", j)
+						l.setStatus(id, 200, false)
+					}
+				}()
+				l.End(id, "", "GET", "/", 0)
+				<-logged
+
+				// End the thread again in case it was logged
+				// to after ending.
+				l.End(id, "", "GET", "/", 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("WAL is %d bytes with no threads open", fi.Size())
+	}
+}
+
+/*
+TestWALCompact keeps one thread open while many others come
+and go. The WAL must be compacted rather than grow, and still
+recover the open thread.
+*/
+func TestWALCompact(t *testing.T) {
+
+	path, cleanup := tempWAL(t)
+	defer cleanup()
+
+	l := &Logger{OnLog: func(Thread) error { return nil }}
+	if err := l.SetWAL(path); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("open", "first").Data("k", "v")
+
+	for i := 0; i < 20000; i++ {
+		id := l.NewId()
+		l.Info(id, "short lived").Data("i", i)
+		l.End(id, "", "GET", "/", 0)
+	}
+	l.Info("open", "second")
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > walCompactSize {
+		t.Fatalf("WAL grew to %d bytes", fi.Size())
+	}
+
+	// Recover as if the process had crashed.
+	var recovered []Thread
+	r := &Logger{OnLog: func(t Thread) error {
+		recovered = append(recovered, t)
+		return nil
+	}}
+	if err := r.SetWAL(path); err != nil {
+		t.Fatal(err)
+	}
+	r.SetWAL("")
+	l.SetWAL("")
+
+	if len(recovered) != 1 || recovered[0].Id != "open" {
+		t.Fatalf("recovered %d threads, want only the open one", len(recovered))
+	}
+	ee := recovered[0].Entries
+	if len(ee) != 2 || ee[0].Message != "First." || ee[1].Message != "Second." {
+		t.Fatalf("recovered entries %v", ee)
+	}
+	if len(ee[0].KeyVals) != 1 || ee[0].KeyVals[0] != (kv{"k", "v"}) {
+		t.Fatalf("recovered data %v", ee[0].KeyVals)
+	}
+}
@@ -0,0 +1,187 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by WorkerPool.Submit once the
+// pool has been closed.
+var ErrPoolClosed = errors.New("logger: worker pool closed")
+
+/*
+WorkerPool runs tasks on a fixed number of goroutines, each
+task in a session of its own with how long it waited in the
+queue and how long it ran attached. The pool's queue depth
+and busy workers are reported by Logger.Stats.
+*/
+type WorkerPool struct {
+	name   string
+	logger *Logger
+	queue  chan poolTask
+	wg     sync.WaitGroup
+
+	// closeMu is held for reading while tasks are queued so
+	// the queue isn't closed under a blocked Submit.
+	closeMu sync.RWMutex
+	closed  bool
+
+	mu        sync.Mutex
+	workers   int
+	busy      int
+	completed uint64
+	failed    uint64
+}
+
+type poolTask struct {
+	name   string
+	fn     func(s *Session) error
+	queued time.Time
+}
+
+/*
+PoolStats describes a WorkerPool at the time Stats was
+called.
+*/
+type PoolStats struct {
+	Name      string `json:"name"`
+	Workers   int    `json:"workers"`
+	Busy      int    `json:"busy"`
+	Queued    int    `json:"queued"`
+	QueueSize int    `json:"queueSize"`
+	Completed uint64 `json:"completed"`
+	Failed    uint64 `json:"failed"`
+}
+
+/*
+NewWorkerPool starts a pool named name of workers goroutines
+with room for queueSize tasks waiting for one. It is included
+in l's Stats until it is closed.
+*/
+func (l *Logger) NewWorkerPool(name string, workers, queueSize int) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &WorkerPool{
+		name:    name,
+		logger:  l,
+		queue:   make(chan poolTask, queueSize),
+		workers: workers,
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	l.workersMu.Lock()
+	l.workers = append(l.workers, p)
+	l.workersMu.Unlock()
+	return p
+}
+
+/*
+Submit queues fn to be run in a session named name, waiting
+for room in the queue if it is full. The time spent queued
+is attached to the session as "queue_wait" and the time fn
+ran for as "execution". If fn returns an error the session
+fails, otherwise it succeeds; a panic is logged and fails
+the session before fn panics again.
+*/
+func (p *WorkerPool) Submit(name string, fn func(s *Session) error) error {
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.queue <- poolTask{name: name, fn: fn, queued: time.Now()}
+	return nil
+}
+
+/*
+Close stops p accepting tasks and waits for those queued to
+finish.
+*/
+func (p *WorkerPool) Close() {
+
+	p.closeMu.Lock()
+	if p.closed {
+		p.closeMu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.closeMu.Unlock()
+	p.wg.Wait()
+
+	l := p.logger
+	l.workersMu.Lock()
+	defer l.workersMu.Unlock()
+	for i, w := range l.workers {
+		if w == p {
+			l.workers = append(l.workers[:i:i], l.workers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *WorkerPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Name:      p.name,
+		Workers:   p.workers,
+		Busy:      p.busy,
+		Queued:    len(p.queue),
+		QueueSize: cap(p.queue),
+		Completed: p.completed,
+		Failed:    p.failed,
+	}
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		p.run(t)
+	}
+}
+
+func (p *WorkerPool) run(t poolTask) {
+
+	start := time.Now()
+	s := p.logger.Sess(t.name)
+	s.ThreadData("pool", p.name)
+	s.ThreadData("queue_wait", start.Sub(t.queued))
+
+	p.mu.Lock()
+	p.busy++
+	p.mu.Unlock()
+
+	failed := true
+	defer func() {
+		p.mu.Lock()
+		p.busy--
+		p.completed++
+		if failed {
+			p.failed++
+		}
+		p.mu.Unlock()
+
+		r := recover()
+		if r != nil {
+			s.Fail(fmt.Errorf("panic: %v", r))
+		}
+		s.ThreadData("execution", time.Since(start))
+		s.End()
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	if err := t.fn(s); err != nil {
+		s.Fail(err)
+		return
+	}
+	failed = false
+	s.Succeed()
+}
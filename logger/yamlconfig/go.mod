@@ -0,0 +1,10 @@
+module github.com/jakebowkett/go-logger/logger/yamlconfig
+
+go 1.20
+
+require (
+	github.com/jakebowkett/go-logger/logger v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/jakebowkett/go-logger/logger => ../
@@ -0,0 +1,48 @@
+/*
+Package yamlconfig lets logger.FromConfig read YAML files,
+those ending in .yaml or .yml. Import it for its side effect:
+
+	import _ "github.com/jakebowkett/go-logger/logger/yamlconfig"
+
+	l, err := logger.FromConfig("logger.yaml")
+
+Fields have the same names as in JSON. It is a module of its
+own so the logger doesn't depend on a YAML package.
+*/
+package yamlconfig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	logger.RegisterConfigFormat(".yaml", Decode)
+	logger.RegisterConfigFormat(".yml", Decode)
+}
+
+/*
+Decode decodes the YAML in data into c. Like FromConfig's
+JSON decoding unknown fields are errors, and errors give the
+line they occurred on.
+*/
+func Decode(data []byte, c *logger.Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		if err == io.EOF {
+			return errors.New("config is empty")
+		}
+		return errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	var extra interface{}
+	if err := dec.Decode(&extra); err != io.EOF {
+		return errors.New("unexpected document after config")
+	}
+	return nil
+}
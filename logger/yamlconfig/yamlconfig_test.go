@@ -0,0 +1,72 @@
+package yamlconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+)
+
+func writeConfig(t *testing.T, name, data string) (path string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "yamlconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestFromConfig(t *testing.T) {
+
+	path, cleanup := writeConfig(t, "logger.yaml", `
+level: info
+redact: [password]
+sinks:
+  - type: file
+    path: `+filepath.Join(os.TempDir(), "yamlconfig-test.log")+`
+    format: json
+sampling:
+  every: 2
+  keepTags: [critical]
+async:
+  queueSize: 8
+  policy: dropOldest
+`)
+	defer cleanup()
+	defer os.Remove(filepath.Join(os.TempDir(), "yamlconfig-test.log"))
+
+	l, err := logger.FromConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if s := l.Stats(); s.QueueSize != 8 {
+		t.Fatalf("queue size is %d, want 8", s.QueueSize)
+	}
+}
+
+func TestFromConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"unknown.yml", "level: info\nsinkz: []\n", "line 2: field sinkz not found"},
+		{"invalid.yaml", "level: loud\n", "level: \"loud\" is not debug, info or error"},
+		{"syntax.yaml", "level: [info\n", "line 1"},
+		{"empty.yaml", "", "config is empty"},
+	}
+	for _, tt := range tests {
+		path, cleanup := writeConfig(t, tt.name, tt.data)
+		_, err := logger.FromConfig(path)
+		cleanup()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/jakebowkett/go-logger/logger/zstd
+
+go 1.20
+
+require (
+	github.com/jakebowkett/go-logger/logger v0.0.0
+	github.com/klauspost/compress v1.17.0
+)
+
+replace github.com/jakebowkett/go-logger/logger => ../
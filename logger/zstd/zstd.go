@@ -0,0 +1,25 @@
+/*
+Package zstd registers zstd with logger.RegisterCompression
+so sinks can compress with it. Import it for its side effect:
+
+	import _ "github.com/jakebowkett/go-logger/logger/zstd"
+
+	sink := &honeycomb.Sink{Compression: "zstd"}
+
+It is a module of its own so the logger doesn't depend on a
+zstd package.
+*/
+package zstd
+
+import (
+	"io"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	logger.RegisterCompression("zstd", func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+}
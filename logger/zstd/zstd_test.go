@@ -0,0 +1,35 @@
+package zstd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jakebowkett/go-logger/logger"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompress(t *testing.T) {
+
+	want := []byte(strings.Repeat(`{"level":"Info","message":"Hello."}`, 100))
+	b, err := logger.Compress("zstd", want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) >= len(want) {
+		t.Fatalf("compressed %d bytes to %d", len(want), len(b))
+	}
+
+	r, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	got, err := r.DecodeAll(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("decompressed data differs")
+	}
+}